@@ -1,12 +1,15 @@
 package main
 
 import (
-	"chatServer/dbaccess"
+	"chatServer/avatars"
+	"chatServer/config"
+	"chatServer/server"
 	"chatServer/utils"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"runtime/debug"
 	"strings"
@@ -28,19 +31,24 @@ type signupResponse struct {
 	ErrorCode int  `json:"errorCode"`
 }
 
-// RunWebServer opens a HTTP server port for serving HTTPS/API requests.
-func RunWebServer() {
-	log.Println("Starting web server on :80 and :443...")
+// RunWebServer opens a HTTP server port for serving HTTPS/API requests, and WebSocket connections to the chat server.
+func RunWebServer(chatServer *server.TCPServer, avatarStore *avatars.Store, cfg *config.Config) {
+	log.Printf("Starting web server on %v and %v...", cfg.HTTPAddress, cfg.HTTPSAddress)
 
 	go func() {
-		if err := http.ListenAndServe(":80", http.HandlerFunc(redirectTLS)); err != nil {
+		if err := http.ListenAndServe(cfg.HTTPAddress, http.HandlerFunc(redirectTLS)); err != nil {
 			log.Fatalf("ListenAndServe error: %v", err)
 		}
 	}()
 
 	http.Handle("/", http.FileServer(http.Dir("./static")))
 	http.HandleFunc("/dosignup", signupRequest)
-	if err := http.ListenAndServeTLS(":443", "domain.crt", "domain.key", nil); err != nil {
+	http.Handle("/ws", chatServer.WebSocketHandler())
+
+	// The config is validated at startup, so the avatar URL is known to parse.
+	avatarURL, _ := url.Parse(cfg.AvatarURL)
+	http.Handle(avatarURL.Path, http.StripPrefix(avatarURL.Path, avatarStore.Handler()))
+	if err := http.ListenAndServeTLS(cfg.HTTPSAddress, cfg.CertFile, cfg.KeyFile, nil); err != nil {
 		log.Fatalf("ListenAndServeTLS error: %v", err)
 	}
 }
@@ -89,7 +97,7 @@ func signupRequest(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	u, err := dbaccess.GetUserByUsername(username)
+	u, err := store.GetUserByUsername(username)
 	if err != nil {
 		signupFailed(w, signupResponseCodeUnknownError)
 		return
@@ -115,13 +123,13 @@ func signupRequest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Validate display name.
-	if len(displayName) < 1 || len(displayName) > 20 {
+	if !utils.IsValidDisplayName(displayName) {
 		signupFailed(w, signupResponseCodeBadRequest)
 		return
 	}
 
 	// Now we can save, but the username could still clash after this point.
-	err = dbaccess.CreateAccount(username, utils.HashPassword(password), email, displayName, utils.GenerateGUID())
+	err = store.CreateAccount(username, utils.HashPassword(password), email, displayName, utils.GenerateGUID())
 	if err != nil {
 		signupFailed(w, signupResponseCodeUnknownError)
 		return
@@ -0,0 +1,91 @@
+// Package avatars stores user avatar images as files in a directory that is served by the web server.
+package avatars
+
+import (
+	"chatServer/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Errors returned when an avatar can't be saved.
+var (
+	ErrTooLarge        = errors.New("avatar image is too large")
+	ErrUnsupportedType = errors.New("avatar image type is not supported")
+)
+
+// Image types that can be uploaded and the file extension they are saved with.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// Store saves avatar images to a directory and builds the URLs they are served from.
+type Store struct {
+	dir     string
+	baseURL string
+	maxSize int
+}
+
+// NewStore creates a new Store instance. Images are saved in dir and served under baseURL, which must end with a
+// slash.
+func NewStore(dir string, baseURL string, maxSize int) *Store {
+	return &Store{
+		dir:     dir,
+		baseURL: baseURL,
+		maxSize: maxSize,
+	}
+}
+
+// Save writes a users avatar image and returns the URL it is served from. Each upload gets a new file name so
+// clients don't show a cached copy of the old image.
+func (s *Store) Save(userID int, image []byte) (string, error) {
+	if len(image) > s.maxSize {
+		return "", ErrTooLarge
+	}
+
+	ext, ok := imageExtensions[http.DetectContentType(image)]
+	if !ok {
+		return "", ErrUnsupportedType
+	}
+
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%v-%v%v", userID, utils.GenerateGUID(), ext)
+
+	err = os.WriteFile(filepath.Join(s.dir, name), image, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return s.baseURL + name, nil
+}
+
+// Remove deletes an avatar image previously saved by the store. URLs the store didn't create are ignored.
+func (s *Store) Remove(imageURL string) error {
+	if !strings.HasPrefix(imageURL, s.baseURL) {
+		return nil
+	}
+
+	name := filepath.Base(strings.TrimPrefix(imageURL, s.baseURL))
+
+	err := os.Remove(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Handler returns a HTTP handler that serves the avatar images. It expects the request path with the base URL path
+// already stripped.
+func (s *Store) Handler() http.Handler {
+	return http.FileServer(http.Dir(s.dir))
+}
@@ -5,6 +5,7 @@ import (
 	"chatServer/models"
 	"chatServer/server"
 	"encoding/binary"
+	"time"
 )
 
 // Packet types supported in our client/server protocol.
@@ -31,6 +32,62 @@ const (
 	PacketIDAddContactAccepted     = 19
 	PacketIDImage                  = 20
 	PacketIDImageFrom              = 21
+	PacketIDGetHistory             = 22
+	PacketIDHistoryResult          = 23
+	PacketIDDeliveryFailed         = 24
+	PacketIDCreateRoom             = 25
+	PacketIDCreateRoomResponse     = 26
+	PacketIDInviteToRoom           = 27
+	PacketIDInviteToRoomResponse   = 28
+	PacketIDAddedToRoom            = 29
+	PacketIDLeaveRoom              = 30
+	PacketIDLeaveRoomResponse      = 31
+	PacketIDRenameRoom             = 32
+	PacketIDRenameRoomResponse     = 33
+	PacketIDRoomRenamed            = 34
+	PacketIDGetRoomMembers         = 35
+	PacketIDRoomMembers            = 36
+	PacketIDRoomMemberChange       = 37
+	PacketIDRoomMessage            = 38
+	PacketIDRoomMessageFrom        = 39
+	PacketIDGetRooms               = 40
+	PacketIDRooms                  = 41
+	PacketIDCallInvite             = 42
+	PacketIDCallInviteResponse     = 43
+	PacketIDCallIncoming           = 44
+	PacketIDCallAccept             = 45
+	PacketIDCallDecline            = 46
+	PacketIDCallHangup             = 47
+	PacketIDCallStateChange        = 48
+	PacketIDTokenLogin             = 49
+	PacketIDLogoutEverywhere       = 50
+	PacketIDLogoutEverywhereResult = 51
+	PacketIDSessionRevoked         = 52
+	PacketIDServerShutdown         = 53
+	PacketIDRemoveContact          = 54
+	PacketIDRemoveContactResponse  = 55
+	PacketIDContactRemoved         = 56
+	PacketIDBlockUser              = 57
+	PacketIDBlockUserResponse      = 58
+	PacketIDUnblockUser            = 59
+	PacketIDUnblockUserResponse    = 60
+	PacketIDGetBlockedUsers        = 61
+	PacketIDBlockedUsers           = 62
+	PacketIDSetDisplayNameResponse = 63
+	PacketIDProfileChanged         = 64
+	PacketIDSetStatusText          = 65
+	PacketIDSetStatusTextResponse  = 66
+	PacketIDSetAvatar              = 67
+	PacketIDSetAvatarResponse      = 68
+	PacketIDMessageDelivered       = 69
+	PacketIDReadReceipt            = 70
+	PacketIDReadReceiptFrom        = 71
+	PacketIDTypingStarted          = 72
+	PacketIDTypingStopped          = 73
+	PacketIDTypingStartedFrom      = 74
+	PacketIDTypingStoppedFrom      = 75
+	PacketIDPong                   = 76
+	PacketIDProtocolError          = 77
 )
 
 // Result codes for an add contact request.
@@ -40,6 +97,7 @@ const (
 	AddContactResultUserNotFound       = 2
 	AddContactResultUserAlreadyContact = 3
 	AddContactResultUserAlreadyPending = 4
+	AddContactResultUserBlocked        = 5
 )
 
 // Result codes for a confirm contact request.
@@ -56,6 +114,83 @@ const (
 	RejectContactResultFailed     = 2
 )
 
+// Result codes for a remove contact request.
+const (
+	RemoveContactResultSuccess    = 0
+	RemoveContactResultNotContact = 1
+	RemoveContactResultFailed     = 2
+)
+
+// Result codes for a block or unblock user request.
+const (
+	BlockResultSuccess        = 0
+	BlockResultFailed         = 1
+	BlockResultUserNotFound   = 2
+	BlockResultAlreadyBlocked = 3
+	BlockResultNotBlocked     = 4
+)
+
+// Result codes for a profile update request.
+const (
+	ProfileResultSuccess  = 0
+	ProfileResultInvalid  = 1
+	ProfileResultFailed   = 2
+	ProfileResultTooLarge = 3
+)
+
+// Reasons a direct packet could not be delivered.
+const (
+	DeliveryFailedReasonNotContact = 0
+	DeliveryFailedReasonError      = 1
+	DeliveryFailedReasonBlocked    = 2
+)
+
+// Result codes for a room request.
+const (
+	RoomResultSuccess       = 0
+	RoomResultFailed        = 1
+	RoomResultNotMember     = 2
+	RoomResultInvalidName   = 3
+	RoomResultNotContact    = 4
+	RoomResultAlreadyMember = 5
+)
+
+// Types of room membership change.
+const (
+	RoomMemberJoined = 0
+	RoomMemberLeft   = 1
+)
+
+// Result codes for a call invite request.
+const (
+	CallResultSuccess       = 0
+	CallResultFailed        = 1
+	CallResultNotContact    = 2
+	CallResultUserOffline   = 3
+	CallResultAlreadyInCall = 4
+)
+
+// States a call can change to.
+const (
+	CallStateAccepted = 0
+	CallStateDeclined = 1
+	CallStateEnded    = 2
+)
+
+// Result codes for a logout everywhere request.
+const (
+	LogoutEverywhereResultSuccess = 0
+	LogoutEverywhereResultFailed  = 1
+)
+
+// Reasons a packet from a client was refused.
+const (
+	ProtocolErrorUnknownPacket = 0
+	ProtocolErrorTruncated     = 1
+	ProtocolErrorTooLong       = 2
+	ProtocolErrorInvalid       = 3
+)
+
 // Writes a string to the specified buffer prefixed by its length. If the string is nill, a length of 0 is written and no string data.
 func writeString(buf *bytes.Buffer, str *string) {
 	if str != nil {
@@ -75,6 +210,10 @@ func writeInt32(buf *bytes.Buffer, val int) {
 	binary.Write(buf, binary.LittleEndian, int32(val))
 }
 
+func writeInt64(buf *bytes.Buffer, val int64) {
+	binary.Write(buf, binary.LittleEndian, val)
+}
+
 // NewLoginResultPacket creates a new login result packet.
 func NewLoginResultPacket(
 	success bool,
@@ -82,7 +221,8 @@ func NewLoginResultPacket(
 	displayName *string,
 	statusText *string,
 	friends []models.FriendModel,
-	pendingContacts []models.PendingContactModel) *server.Packet {
+	pendingContacts []models.PendingContactModel,
+	sessionToken *string) *server.Packet {
 	/*
 		Success (byte)
 		UserId (int32)
@@ -117,6 +257,9 @@ func NewLoginResultPacket(
 		ImageURL (string)
 		MessageLength (int32)
 		Message (string)
+
+		SessionTokenLength (int32)
+		SessionToken (string)
 	*/
 
 	buf := new(bytes.Buffer)
@@ -154,6 +297,8 @@ func NewLoginResultPacket(
 		} else {
 			writeInt32(buf, len(pendingContacts))
 		}
+
+		writeString(buf, sessionToken)
 	}
 
 	bytes := buf.Bytes()
@@ -188,17 +333,23 @@ func NewUserStatusChangePacket(userID int, status int) *server.Packet {
 	return &packet
 }
 
-// NewChatFromPacket creates a new chat from user packet.
-func NewChatFromPacket(fromUserID int, message string) *server.Packet {
+// NewChatFromPacket creates a new chat from user packet. The sent time is when the server originally received the
+// message, which can be in the past for messages queued while the user was offline. The message id is assigned by
+// the senders client, and is used to send a read receipt back to them.
+func NewChatFromPacket(fromUserID int, message string, sentAt time.Time, messageID int) *server.Packet {
 	/*
 		FromUserId (int32)
 		MessageLen (int32)
 		Message (string)
+		SentAt (int64 - unix seconds)
+		MessageId (int32)
 	*/
 	buf := new(bytes.Buffer)
 
 	writeInt32(buf, fromUserID)
 	writeString(buf, &message)
+	writeInt64(buf, sentAt.Unix())
+	writeInt32(buf, messageID)
 
 	bytes := buf.Bytes()
 
@@ -397,3 +548,769 @@ func NewImageFromPacket(fromUserID int, imageData *string) *server.Packet {
 
 	return &packet
 }
+
+// NewHistoryResultPacket creates a new history result packet holding a page of a conversation, newest message first.
+func NewHistoryResultPacket(contactUserID int, messages []models.MessageModel) *server.Packet {
+	/*
+		ContactUserID (int32)
+		MessageCount (int32)
+
+		(For each message...)
+		MessageID (int32)
+		FromUserID (int32)
+		MessageType (int32)
+		ContentLen (int32)
+		Content (string)
+		SentAt (int64 - unix seconds)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, contactUserID)
+	writeInt32(buf, len(messages))
+
+	for _, m := range messages {
+		writeInt32(buf, m.ID)
+		writeInt32(buf, m.FromUserID)
+		writeInt32(buf, m.MessageType)
+		writeString(buf, m.Content)
+		writeInt64(buf, m.SentAt.Unix())
+	}
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDHistoryResult,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewDeliveryFailedPacket creates a new delivery failed packet, telling the sender why a direct packet to a user was
+// not routed.
+func NewDeliveryFailedPacket(toUserID int, packetID int, reason int) *server.Packet {
+	/*
+		ToUserID (int32)
+		PacketID (int32)
+		Reason (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, toUserID)
+	writeInt32(buf, packetID)
+	writeInt32(buf, reason)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDDeliveryFailed,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewCreateRoomResponsePacket creates a new create room response packet.
+func NewCreateRoomResponsePacket(resultCode int, roomID int, name *string) *server.Packet {
+	/*
+		ResultCode (int32)
+		RoomID (int32)
+		NameLen (int32)
+		Name (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, roomID)
+	writeString(buf, name)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDCreateRoomResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewInviteToRoomResponsePacket creates a new invite to room response packet.
+func NewInviteToRoomResponsePacket(resultCode int, roomID int, userID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		RoomID (int32)
+		UserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, roomID)
+	writeInt32(buf, userID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDInviteToRoomResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewAddedToRoomPacket creates a new packet telling a user they were added to a room.
+func NewAddedToRoomPacket(roomID int, name *string, invitedByUserID int) *server.Packet {
+	/*
+		RoomID (int32)
+		NameLen (int32)
+		Name (string)
+		InvitedByUserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, roomID)
+	writeString(buf, name)
+	writeInt32(buf, invitedByUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDAddedToRoom,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewLeaveRoomResponsePacket creates a new leave room response packet.
+func NewLeaveRoomResponsePacket(resultCode int, roomID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		RoomID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, roomID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDLeaveRoomResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRenameRoomResponsePacket creates a new rename room response packet.
+func NewRenameRoomResponsePacket(resultCode int, roomID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		RoomID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, roomID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRenameRoomResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRoomRenamedPacket creates a new packet telling room members the room was renamed.
+func NewRoomRenamedPacket(roomID int, byUserID int, name *string) *server.Packet {
+	/*
+		RoomID (int32)
+		ByUserID (int32)
+		NameLen (int32)
+		Name (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, roomID)
+	writeInt32(buf, byUserID)
+	writeString(buf, name)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRoomRenamed,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRoomMembersPacket creates a new room members packet.
+func NewRoomMembersPacket(resultCode int, roomID int, members []models.RoomMemberModel) *server.Packet {
+	/*
+		ResultCode (int32)
+		RoomID (int32)
+		MemberCount (int32)
+
+		(For each member...)
+		UserID (int32)
+		UsernameLen (int32)
+		Username (string)
+		DisplayNameLen (int32)
+		DisplayName (string)
+		Status (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, roomID)
+	writeInt32(buf, len(members))
+
+	for _, m := range members {
+		writeInt32(buf, m.ID)
+		writeString(buf, &m.Username)
+		writeString(buf, m.DisplayName)
+		writeInt32(buf, m.Status)
+	}
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRoomMembers,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRoomMemberChangePacket creates a new packet telling room members that a user joined or left.
+func NewRoomMemberChangePacket(roomID int, userID int, change int) *server.Packet {
+	/*
+		RoomID (int32)
+		UserID (int32)
+		Change (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, roomID)
+	writeInt32(buf, userID)
+	writeInt32(buf, change)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRoomMemberChange,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRoomMessageFromPacket creates a new room message from user packet.
+func NewRoomMessageFromPacket(roomID int, fromUserID int, message string, sentAt time.Time) *server.Packet {
+	/*
+		RoomID (int32)
+		FromUserId (int32)
+		MessageLen (int32)
+		Message (string)
+		SentAt (int64 - unix seconds)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, roomID)
+	writeInt32(buf, fromUserID)
+	writeString(buf, &message)
+	writeInt64(buf, sentAt.Unix())
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRoomMessageFrom,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewRoomsPacket creates a new packet listing the rooms a user is a member of.
+func NewRoomsPacket(rooms []models.RoomModel) *server.Packet {
+	/*
+		RoomCount (int32)
+
+		(For each room...)
+		RoomID (int32)
+		NameLen (int32)
+		Name (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, len(rooms))
+
+	for _, r := range rooms {
+		writeInt32(buf, r.ID)
+		writeString(buf, &r.Name)
+	}
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRooms,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewCallInviteResponsePacket creates a new call invite response packet.
+func NewCallInviteResponsePacket(resultCode int, callID int, calleeUserID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		CallID (int32)
+		CalleeUserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, callID)
+	writeInt32(buf, calleeUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDCallInviteResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewCallIncomingPacket creates a new incoming call packet.
+func NewCallIncomingPacket(callID int, callerUserID int) *server.Packet {
+	/*
+		CallID (int32)
+		CallerUserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, callID)
+	writeInt32(buf, callerUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDCallIncoming,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewCallStateChangePacket creates a new call state change packet.
+func NewCallStateChangePacket(callID int, byUserID int, state int) *server.Packet {
+	/*
+		CallID (int32)
+		ByUserID (int32)
+		State (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, callID)
+	writeInt32(buf, byUserID)
+	writeInt32(buf, state)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDCallStateChange,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewLogoutEverywhereResponsePacket creates a new logout everywhere response packet.
+func NewLogoutEverywhereResponsePacket(resultCode int) *server.Packet {
+	/*
+		ResultCode (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDLogoutEverywhereResult,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewSessionRevokedPacket creates a new packet telling a client its session was revoked and it is being disconnected.
+func NewSessionRevokedPacket() *server.Packet {
+	return &server.Packet{
+		ID: PacketIDSessionRevoked,
+	}
+}
+
+// NewServerShutdownPacket creates a new packet telling a client the server is shutting down and it will be
+// disconnected.
+func NewServerShutdownPacket() *server.Packet {
+	return &server.Packet{
+		ID: PacketIDServerShutdown,
+	}
+}
+
+// NewRemoveContactResponsePacket creates a new remove contact response packet.
+func NewRemoveContactResponsePacket(resultCode int, contactUserID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		ContactUserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, contactUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDRemoveContactResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewContactRemovedPacket creates a new packet telling a user they were removed from a contacts list.
+func NewContactRemovedPacket(userID int) *server.Packet {
+	/*
+		UserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, userID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDContactRemoved,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewBlockUserResponsePacket creates a new block user response packet.
+func NewBlockUserResponsePacket(resultCode int, userID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		UserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, userID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDBlockUserResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewUnblockUserResponsePacket creates a new unblock user response packet.
+func NewUnblockUserResponsePacket(resultCode int, userID int) *server.Packet {
+	/*
+		ResultCode (int32)
+		UserID (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeInt32(buf, userID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDUnblockUserResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewBlockedUsersPacket creates a new packet listing the users a user has blocked.
+func NewBlockedUsersPacket(users []models.BlockedUserModel) *server.Packet {
+	/*
+		UserCount (int32)
+
+		(For each user...)
+		UserID (int32)
+		UsernameLen (int32)
+		Username (string)
+		DisplayNameLen (int32)
+		DisplayName (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, len(users))
+
+	for _, u := range users {
+		writeInt32(buf, u.ID)
+		writeString(buf, &u.Username)
+		writeString(buf, u.DisplayName)
+	}
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDBlockedUsers,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewSetDisplayNameResponsePacket creates a new set display name response packet.
+func NewSetDisplayNameResponsePacket(resultCode int, displayName *string) *server.Packet {
+	/*
+		ResultCode (int32)
+		DisplayNameLen (int32)
+		DisplayName (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeString(buf, displayName)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDSetDisplayNameResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewProfileChangedPacket creates a new packet telling contacts a users profile has changed.
+func NewProfileChangedPacket(userID int, displayName *string, statusText *string, imageURL *string) *server.Packet {
+	/*
+		UserID (int32)
+		DisplayNameLen (int32)
+		DisplayName (string)
+		StatusTextLen (int32)
+		StatusText (string)
+		ImageURLLen (int32)
+		ImageURL (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, userID)
+	writeString(buf, displayName)
+	writeString(buf, statusText)
+	writeString(buf, imageURL)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDProfileChanged,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewSetStatusTextResponsePacket creates a new set status text response packet.
+func NewSetStatusTextResponsePacket(resultCode int, statusText *string) *server.Packet {
+	/*
+		ResultCode (int32)
+		StatusTextLen (int32)
+		StatusText (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeString(buf, statusText)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDSetStatusTextResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewSetAvatarResponsePacket creates a new set avatar response packet.
+func NewSetAvatarResponsePacket(resultCode int, imageURL *string) *server.Packet {
+	/*
+		ResultCode (int32)
+		ImageURLLen (int32)
+		ImageURL (string)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, resultCode)
+	writeString(buf, imageURL)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDSetAvatarResponse,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewMessageDeliveredPacket creates a new message delivered packet, telling the sender a chat message reached the
+// recipient. Queued is true if the recipient was offline and will receive it on their next login.
+func NewMessageDeliveredPacket(toUserID int, messageID int, queued bool) *server.Packet {
+	/*
+		ToUserId (int32)
+		MessageId (int32)
+		Queued (bool)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, toUserID)
+	writeInt32(buf, messageID)
+	writeBool(buf, queued)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDMessageDelivered,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewReadReceiptFromPacket creates a new read receipt from user packet, telling the sender a chat message was read.
+func NewReadReceiptFromPacket(readerUserID int, messageID int) *server.Packet {
+	/*
+		ReaderUserId (int32)
+		MessageId (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, readerUserID)
+	writeInt32(buf, messageID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDReadReceiptFrom,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewTypingStartedFromPacket creates a new typing started from user packet.
+func NewTypingStartedFromPacket(fromUserID int) *server.Packet {
+	/*
+		FromUserId (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, fromUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDTypingStartedFrom,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewTypingStoppedFromPacket creates a new typing stopped from user packet.
+func NewTypingStoppedFromPacket(fromUserID int) *server.Packet {
+	/*
+		FromUserId (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, fromUserID)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDTypingStoppedFrom,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewPingPacket creates a new ping packet. The receiver replies with a pong packet carrying the same sequence.
+func NewPingPacket(sequence int) *server.Packet {
+	/*
+		Sequence (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, sequence)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDPing,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewPongPacket creates a new pong packet in reply to a ping.
+func NewPongPacket(sequence int) *server.Packet {
+	/*
+		Sequence (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, sequence)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDPong,
+		Data: &bytes,
+	}
+
+	return &packet
+}
+
+// NewProtocolErrorPacket creates a new protocol error packet, telling a client a packet it sent was refused because
+// it couldn't be decoded.
+func NewProtocolErrorPacket(packetID int, reason int) *server.Packet {
+	/*
+		PacketId (int32)
+		Reason (int32)
+	*/
+	buf := new(bytes.Buffer)
+
+	writeInt32(buf, packetID)
+	writeInt32(buf, reason)
+
+	bytes := buf.Bytes()
+
+	packet := server.Packet{
+		ID:   PacketIDProtocolError,
+		Data: &bytes,
+	}
+
+	return &packet
+}
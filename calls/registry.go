@@ -0,0 +1,208 @@
+// Package calls keeps track of the voice calls between connected clients, so audio can be forwarded only to the
+// other participant of a call.
+package calls
+
+import (
+	"chatServer/server"
+	"errors"
+	"sync"
+)
+
+// Errors returned when a call can't be started or changed.
+var (
+	ErrAlreadyInCall = errors.New("client is already in a call")
+	ErrCallNotFound  = errors.New("call not found")
+	ErrNotInCall     = errors.New("client is not a participant of the call")
+)
+
+// Call is a voice call between the calling client and one session of the called user. Until the call is accepted,
+// every session of the called user is ringing and Callee is nil.
+type Call struct {
+	ID           int
+	Caller       *server.Client
+	CalleeUserID int
+	Callee       *server.Client
+}
+
+// Accepted returns true if the called user has answered.
+func (c *Call) Accepted() bool {
+	return c.Callee != nil
+}
+
+// Peer returns the other participant of the call for the specified client, or nil if the call hasn't been accepted.
+func (c *Call) Peer(client *server.Client) *server.Client {
+	if c.Callee == nil {
+		return nil
+	}
+
+	if client == c.Caller {
+		return c.Callee
+	}
+
+	if client == c.Callee {
+		return c.Caller
+	}
+
+	return nil
+}
+
+// Registry holds all active calls. It is safe to use from multiple goroutines.
+type Registry struct {
+	mutex    *sync.Mutex
+	nextID   int
+	calls    map[int]*Call
+	byClient map[*server.Client]*Call
+}
+
+// NewRegistry creates a new Registry instance.
+func NewRegistry() *Registry {
+	return &Registry{
+		mutex:    &sync.Mutex{},
+		calls:    map[int]*Call{},
+		byClient: map[*server.Client]*Call{},
+	}
+}
+
+// Start creates a ringing call from the caller client to the specified user.
+func (r *Registry) Start(caller *server.Client, calleeUserID int) (*Call, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.byClient[caller]; ok {
+		return nil, ErrAlreadyInCall
+	}
+
+	r.nextID++
+
+	call := &Call{
+		ID:           r.nextID,
+		Caller:       caller,
+		CalleeUserID: calleeUserID,
+	}
+
+	r.calls[call.ID] = call
+	r.byClient[caller] = call
+
+	return call, nil
+}
+
+// Accept answers a ringing call on the specified client, which must be a session of the called user.
+func (r *Registry) Accept(callID int, callee *server.Client) (*Call, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	call, ok := r.calls[callID]
+	if !ok || call.Accepted() {
+		return nil, ErrCallNotFound
+	}
+
+	if callee.UserID != call.CalleeUserID {
+		return nil, ErrNotInCall
+	}
+
+	if _, ok := r.byClient[callee]; ok {
+		return nil, ErrAlreadyInCall
+	}
+
+	call.Callee = callee
+	r.byClient[callee] = call
+
+	return call, nil
+}
+
+// End removes a call. The client must be the caller, the session that accepted, or any session of the called user
+// while the call is still ringing.
+func (r *Registry) End(callID int, client *server.Client) (*Call, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	call, ok := r.calls[callID]
+	if !ok {
+		return nil, ErrCallNotFound
+	}
+
+	isRingingCallee := !call.Accepted() && client.UserID == call.CalleeUserID
+
+	if client != call.Caller && client != call.Callee && !isRingingCallee {
+		return nil, ErrNotInCall
+	}
+
+	r.remove(call)
+
+	return call, nil
+}
+
+// EndClientCall removes the call the client is in, if any, such as when it disconnects.
+func (r *Registry) EndClientCall(client *server.Client) *Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	call, ok := r.byClient[client]
+	if !ok {
+		return nil
+	}
+
+	r.remove(call)
+
+	return call
+}
+
+// EndRinging removes the calls ringing for the specified user, such as when their last session disconnects.
+func (r *Registry) EndRinging(userID int) []*Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ended := []*Call{}
+
+	for _, call := range r.calls {
+		if !call.Accepted() && call.CalleeUserID == userID {
+			r.remove(call)
+			ended = append(ended, call)
+		}
+	}
+
+	return ended
+}
+
+// EndBetween removes the calls between the two users in either direction, ringing or accepted, such as when one
+// removes the other as a contact.
+func (r *Registry) EndBetween(userID int, otherUserID int) []*Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ended := []*Call{}
+
+	for _, call := range r.calls {
+		callerUserID := call.Caller.UserID
+
+		if (callerUserID == userID && call.CalleeUserID == otherUserID) ||
+			(callerUserID == otherUserID && call.CalleeUserID == userID) {
+			r.remove(call)
+			ended = append(ended, call)
+		}
+	}
+
+	return ended
+}
+
+// Peer returns the client at the other end of the clients accepted call, or nil if it isn't in one.
+func (r *Registry) Peer(client *server.Client) *server.Client {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	call, ok := r.byClient[client]
+	if !ok {
+		return nil
+	}
+
+	return call.Peer(client)
+}
+
+func (r *Registry) remove(call *Call) {
+	delete(r.calls, call.ID)
+	delete(r.byClient, call.Caller)
+
+	if call.Callee != nil {
+		delete(r.byClient, call.Callee)
+	}
+}
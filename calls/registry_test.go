@@ -0,0 +1,117 @@
+package calls
+
+import (
+	"chatServer/server"
+	"testing"
+)
+
+func TestAudioPeerOnlyAfterAccept(t *testing.T) {
+	r := NewRegistry()
+	caller := &server.Client{UserID: 1}
+	callee := &server.Client{UserID: 2}
+	stranger := &server.Client{UserID: 3}
+
+	call, err := r.Start(caller, callee.UserID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if r.Peer(caller) != nil {
+		t.Errorf("Expected no peer before the call is accepted.")
+	}
+
+	if _, err := r.Accept(call.ID, stranger); err != ErrNotInCall {
+		t.Errorf("Expected %v, got %v.", ErrNotInCall, err)
+	}
+
+	if _, err := r.Accept(call.ID, callee); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if r.Peer(caller) != callee || r.Peer(callee) != caller {
+		t.Errorf("Expected caller and callee to be peers.")
+	}
+
+	if r.Peer(stranger) != nil {
+		t.Errorf("Expected no peer for a client outside the call.")
+	}
+}
+
+func TestEndCall(t *testing.T) {
+	r := NewRegistry()
+	caller := &server.Client{UserID: 1}
+	callee := &server.Client{UserID: 2}
+	stranger := &server.Client{UserID: 3}
+
+	call, _ := r.Start(caller, callee.UserID)
+
+	if _, err := r.Start(caller, 3); err != ErrAlreadyInCall {
+		t.Errorf("Expected %v, got %v.", ErrAlreadyInCall, err)
+	}
+
+	if _, err := r.End(call.ID, stranger); err != ErrNotInCall {
+		t.Errorf("Expected %v, got %v.", ErrNotInCall, err)
+	}
+
+	// Any session of the called user can decline while ringing.
+	if _, err := r.End(call.ID, &server.Client{UserID: 2}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if r.EndClientCall(caller) != nil {
+		t.Errorf("Expected the call to already be removed.")
+	}
+
+	if _, err := r.Start(caller, callee.UserID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEndRinging(t *testing.T) {
+	r := NewRegistry()
+	caller := &server.Client{UserID: 1}
+	callee := &server.Client{UserID: 2}
+	other := &server.Client{UserID: 3}
+
+	ringing, _ := r.Start(caller, callee.UserID)
+	accepted, _ := r.Start(other, callee.UserID)
+	r.Accept(accepted.ID, callee)
+
+	ended := r.EndRinging(callee.UserID)
+	if len(ended) != 1 || ended[0] != ringing {
+		t.Errorf("Expected only the ringing call to end, got %v.", ended)
+	}
+
+	// The caller is free to call again, and the accepted call carries on.
+	if _, err := r.Start(caller, callee.UserID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if r.Peer(other) != callee {
+		t.Errorf("Expected the accepted call to carry on.")
+	}
+}
+
+func TestEndBetween(t *testing.T) {
+	r := NewRegistry()
+	alice := &server.Client{UserID: 1}
+	bob := &server.Client{UserID: 2}
+	carol := &server.Client{UserID: 3}
+
+	accepted, _ := r.Start(bob, alice.UserID)
+	r.Accept(accepted.ID, alice)
+	other, _ := r.Start(carol, bob.UserID)
+
+	ended := r.EndBetween(alice.UserID, bob.UserID)
+	if len(ended) != 1 || ended[0] != accepted {
+		t.Errorf("Expected only the call between alice and bob to end, got %v.", ended)
+	}
+
+	if r.Peer(alice) != nil || r.Peer(bob) != nil {
+		t.Errorf("Expected alice and bob to have left the call.")
+	}
+
+	if _, err := r.Accept(other.ID, bob); err != nil {
+		t.Errorf("Expected the call from carol to carry on, got %v.", err)
+	}
+}
@@ -0,0 +1,639 @@
+// Package client connects to the chat server, encoding requests and decoding the packets the server sends into
+// events. A client logs in when it connects, and reconnects and logs in again if the connection is lost.
+package client
+
+import (
+	"chatServer/server"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLoginDenied is returned when the server denies a login.
+var ErrLoginDenied = errors.New("login denied")
+
+// ErrSessionRevoked is returned once the session is revoked by logging out everywhere from another client.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// ErrClosed is returned when using a client after it was closed.
+var ErrClosed = errors.New("client closed")
+
+// ErrNotConnected is returned when sending while the client is reconnecting.
+var ErrNotConnected = errors.New("not connected")
+
+// Config is the server to connect to and how to log in.
+type Config struct {
+	// The address of the chat server, as host:port.
+	Address string
+
+	// Connects with TLS when set.
+	TLSConfig *tls.Config
+
+	// The credentials to log in with. A session token logs in without a password.
+	Username     string
+	Password     string
+	SessionToken string
+
+	// Reconnects when the connection is lost, waiting ReconnectDelay before the first attempt and doubling it up to
+	// MaxReconnectDelay after each failure.
+	Reconnect         bool
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+
+	// Limits for connecting and sending, and the time without any packet from the server before the connection is
+	// considered lost. The server pings clients more often than this.
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	ReadTimeout  time.Duration
+
+	// The longest packet accepted from the server.
+	MaxPacketLength int64
+}
+
+// Sets the defaults of any config values that are not set.
+func (cfg *Config) setDefaults() {
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = time.Second
+	}
+
+	if cfg.MaxReconnectDelay < cfg.ReconnectDelay {
+		cfg.MaxReconnectDelay = 30 * time.Second
+	}
+
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 10 * time.Second
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = 60 * time.Second
+	}
+
+	if cfg.MaxPacketLength <= 0 {
+		cfg.MaxPacketLength = server.DefaultSettings().MaxPacketLength
+	}
+}
+
+// Handlers are called with the events received from the server. They are called one at a time from the goroutine
+// reading the connection, so they must not block for long. Any handler can be left nil.
+type Handlers struct {
+	// Called when the connection is lost, before reconnecting.
+	OnDisconnect func(err error)
+
+	// Called after reconnecting and logging in again.
+	OnReconnect func(result *LoginResult)
+
+	// Called with packets that could not be decoded.
+	OnDecodeError func(p *server.Packet, err error)
+
+	OnUserStatusChange       func(e *UserStatusChange)
+	OnChatFrom               func(e *ChatFrom)
+	OnActionFrom             func(e *ActionFrom)
+	OnNudgeFrom              func(e *NudgeFrom)
+	OnAudio                  func(e *Audio)
+	OnAddContactResponse     func(e *AddContactResponse)
+	OnNotifyAddRequest       func(e *NotifyAddRequest)
+	OnConfirmContactResponse func(e *ConfirmContactResponse)
+	OnRejectContactResponse  func(e *RejectContactResponse)
+	OnAddContactAccepted     func(e *AddContactAccepted)
+	OnImageFrom              func(e *ImageFrom)
+	OnHistoryResult          func(e *HistoryResult)
+	OnDeliveryFailed         func(e *DeliveryFailed)
+	OnCreateRoomResponse     func(e *CreateRoomResponse)
+	OnInviteToRoomResponse   func(e *InviteToRoomResponse)
+	OnAddedToRoom            func(e *AddedToRoom)
+	OnLeaveRoomResponse      func(e *LeaveRoomResponse)
+	OnRenameRoomResponse     func(e *RenameRoomResponse)
+	OnRoomRenamed            func(e *RoomRenamed)
+	OnRoomMembers            func(e *RoomMembers)
+	OnRoomMemberChange       func(e *RoomMemberChange)
+	OnRoomMessageFrom        func(e *RoomMessageFrom)
+	OnRooms                  func(e *Rooms)
+	OnCallInviteResponse     func(e *CallInviteResponse)
+	OnCallIncoming           func(e *CallIncoming)
+	OnCallStateChange        func(e *CallStateChange)
+	OnLogoutEverywhereResult func(e *LogoutEverywhereResult)
+	OnSessionRevoked         func(e *SessionRevoked)
+	OnServerShutdown         func(e *ServerShutdown)
+	OnRemoveContactResponse  func(e *RemoveContactResponse)
+	OnContactRemoved         func(e *ContactRemoved)
+	OnBlockUserResponse      func(e *BlockUserResponse)
+	OnUnblockUserResponse    func(e *UnblockUserResponse)
+	OnBlockedUsers           func(e *BlockedUsers)
+	OnSetDisplayNameResponse func(e *SetDisplayNameResponse)
+	OnProfileChanged         func(e *ProfileChanged)
+	OnSetStatusTextResponse  func(e *SetStatusTextResponse)
+	OnSetAvatarResponse      func(e *SetAvatarResponse)
+	OnMessageDelivered       func(e *MessageDelivered)
+	OnReadReceiptFrom        func(e *ReadReceiptFrom)
+	OnTypingStartedFrom      func(e *TypingStartedFrom)
+	OnTypingStoppedFrom      func(e *TypingStoppedFrom)
+	OnPong                   func(e *Pong)
+	OnProtocolError          func(e *ProtocolError)
+}
+
+// Client is a logged in connection to the chat server.
+type Client struct {
+	config   Config
+	handlers Handlers
+
+	mutex  *sync.Mutex
+	conn   net.Conn
+	token  string
+	userID int
+	err    error
+
+	done      chan struct{}
+	closeOnce *sync.Once
+
+	lastMessageID int32
+}
+
+// Dial connects to the server and logs in. Events are passed to the handlers until the client is closed, or the
+// connection is lost and can't be reconnected.
+func Dial(config Config, handlers Handlers) (*Client, *LoginResult, error) {
+	config.setDefaults()
+
+	c := &Client{
+		config:    config,
+		handlers:  handlers,
+		mutex:     &sync.Mutex{},
+		token:     config.SessionToken,
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+
+	conn, result, err := c.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.conn = conn
+
+	go c.run(conn)
+
+	return c, result, nil
+}
+
+// UserID returns the id of the logged in user.
+func (c *Client) UserID() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.userID
+}
+
+// SessionToken returns the token of the current session, which can be used to log in without a password.
+func (c *Client) SessionToken() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.token
+}
+
+// Send sends a request to the server. ErrNotConnected is returned while reconnecting.
+func (c *Client) Send(r Request) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		select {
+		case <-c.done:
+			return ErrClosed
+		default:
+			return ErrNotConnected
+		}
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+
+	return writePacket(c.conn, r.Packet())
+}
+
+// SendChat sends a chat message to a contact with a new message id, which is returned so delivered and read
+// receipts can be matched to it.
+func (c *Client) SendChat(toUserID int, message string) (int, error) {
+	messageID := int(atomic.AddInt32(&c.lastMessageID, 1))
+
+	return messageID, c.Send(ChatRequest{ToUserID: toUserID, Message: message, MessageID: messageID})
+}
+
+// Close disconnects from the server and stops reconnecting.
+func (c *Client) Close() error {
+	c.stop(ErrClosed)
+
+	return nil
+}
+
+// Done returns a channel that is closed once the client stops, either by being closed or because the connection was
+// lost and could not be reconnected.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client stopped, once Done is closed.
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+// Stops the client for the specified reason, if it hasn't already stopped.
+func (c *Client) stop(err error) {
+	c.closeOnce.Do(func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.err = err
+		if c.conn != nil {
+			c.conn.Close()
+			c.conn = nil
+		}
+
+		close(c.done)
+	})
+}
+
+// Connects and logs in, with the session token if there is one, or else the username and password.
+func (c *Client) connect() (net.Conn, *LoginResult, error) {
+	dialer := &net.Dialer{Timeout: c.config.DialTimeout}
+
+	var conn net.Conn
+	var err error
+
+	if c.config.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.config.Address, c.config.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.config.Address)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mutex.Lock()
+	token := c.token
+	c.mutex.Unlock()
+
+	var request Request = LoginRequest{Username: c.config.Username, Password: c.config.Password}
+	if token != "" {
+		request = TokenLoginRequest{Token: token}
+	}
+
+	result, err := c.login(conn, request)
+
+	// An expired session falls back to the password, if there is one.
+	if err == ErrLoginDenied && token != "" && c.config.Password != "" {
+		result, err = c.login(conn, LoginRequest{Username: c.config.Username, Password: c.config.Password})
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	c.mutex.Lock()
+	c.userID = result.UserID
+	if result.SessionToken != nil {
+		c.token = *result.SessionToken
+	}
+	c.mutex.Unlock()
+
+	return conn, result, nil
+}
+
+// Sends a login request and waits for the result, answering any pings meanwhile.
+func (c *Client) login(conn net.Conn, request Request) (*LoginResult, error) {
+	conn.SetDeadline(time.Now().Add(c.config.DialTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if err := writePacket(conn, request.Packet()); err != nil {
+		return nil, err
+	}
+
+	for {
+		p, err := readPacket(conn, c.config.MaxPacketLength)
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := Decode(p)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e := event.(type) {
+		case *Ping:
+			if err := writePacket(conn, PongRequest{Sequence: e.Sequence}.Packet()); err != nil {
+				return nil, err
+			}
+
+		case *LoginResult:
+			if !e.Success {
+				return nil, ErrLoginDenied
+			}
+
+			return e, nil
+		}
+	}
+}
+
+// Reads packets and passes them to the handlers until the client stops, reconnecting when the connection is lost.
+func (c *Client) run(conn net.Conn) {
+	for {
+		err := c.read(conn)
+		conn.Close()
+
+		c.mutex.Lock()
+		select {
+		case <-c.done:
+			c.mutex.Unlock()
+			return
+		default:
+			c.conn = nil
+		}
+		c.mutex.Unlock()
+
+		if c.handlers.OnDisconnect != nil {
+			c.handlers.OnDisconnect(err)
+		}
+
+		if err == ErrSessionRevoked || !c.config.Reconnect {
+			c.stop(err)
+			return
+		}
+
+		conn = c.reconnect()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// Reads packets until the connection is lost, returning why.
+func (c *Client) read(conn net.Conn) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
+
+		p, err := readPacket(conn, c.config.MaxPacketLength)
+		if err != nil {
+			return err
+		}
+
+		event, err := Decode(p)
+		if err != nil {
+			if c.handlers.OnDecodeError != nil {
+				c.handlers.OnDecodeError(p, err)
+			}
+			continue
+		}
+
+		switch e := event.(type) {
+		case *Ping:
+			c.Send(PongRequest{Sequence: e.Sequence})
+
+		case *SessionRevoked:
+			c.dispatch(event)
+			return ErrSessionRevoked
+
+		default:
+			c.dispatch(event)
+		}
+	}
+}
+
+// Reconnects with a growing delay between attempts, until logged in again or the client stops. Returns nil if the
+// client stopped.
+func (c *Client) reconnect() net.Conn {
+	delay := c.config.ReconnectDelay
+
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, result, err := c.connect()
+		if err == ErrLoginDenied {
+			c.stop(err)
+			return nil
+		}
+
+		if err == nil {
+			c.mutex.Lock()
+			select {
+			case <-c.done:
+				// Closed while logging in.
+				c.mutex.Unlock()
+				conn.Close()
+				return nil
+			default:
+				c.conn = conn
+			}
+			c.mutex.Unlock()
+
+			if c.handlers.OnReconnect != nil {
+				c.handlers.OnReconnect(result)
+			}
+
+			return conn
+		}
+
+		delay *= 2
+		if delay > c.config.MaxReconnectDelay {
+			delay = c.config.MaxReconnectDelay
+		}
+	}
+}
+
+// Passes an event to its handler.
+func (c *Client) dispatch(event interface{}) {
+	h := &c.handlers
+
+	switch e := event.(type) {
+	case *UserStatusChange:
+		if h.OnUserStatusChange != nil {
+			h.OnUserStatusChange(e)
+		}
+	case *ChatFrom:
+		if h.OnChatFrom != nil {
+			h.OnChatFrom(e)
+		}
+	case *ActionFrom:
+		if h.OnActionFrom != nil {
+			h.OnActionFrom(e)
+		}
+	case *NudgeFrom:
+		if h.OnNudgeFrom != nil {
+			h.OnNudgeFrom(e)
+		}
+	case *Audio:
+		if h.OnAudio != nil {
+			h.OnAudio(e)
+		}
+	case *AddContactResponse:
+		if h.OnAddContactResponse != nil {
+			h.OnAddContactResponse(e)
+		}
+	case *NotifyAddRequest:
+		if h.OnNotifyAddRequest != nil {
+			h.OnNotifyAddRequest(e)
+		}
+	case *ConfirmContactResponse:
+		if h.OnConfirmContactResponse != nil {
+			h.OnConfirmContactResponse(e)
+		}
+	case *RejectContactResponse:
+		if h.OnRejectContactResponse != nil {
+			h.OnRejectContactResponse(e)
+		}
+	case *AddContactAccepted:
+		if h.OnAddContactAccepted != nil {
+			h.OnAddContactAccepted(e)
+		}
+	case *ImageFrom:
+		if h.OnImageFrom != nil {
+			h.OnImageFrom(e)
+		}
+	case *HistoryResult:
+		if h.OnHistoryResult != nil {
+			h.OnHistoryResult(e)
+		}
+	case *DeliveryFailed:
+		if h.OnDeliveryFailed != nil {
+			h.OnDeliveryFailed(e)
+		}
+	case *CreateRoomResponse:
+		if h.OnCreateRoomResponse != nil {
+			h.OnCreateRoomResponse(e)
+		}
+	case *InviteToRoomResponse:
+		if h.OnInviteToRoomResponse != nil {
+			h.OnInviteToRoomResponse(e)
+		}
+	case *AddedToRoom:
+		if h.OnAddedToRoom != nil {
+			h.OnAddedToRoom(e)
+		}
+	case *LeaveRoomResponse:
+		if h.OnLeaveRoomResponse != nil {
+			h.OnLeaveRoomResponse(e)
+		}
+	case *RenameRoomResponse:
+		if h.OnRenameRoomResponse != nil {
+			h.OnRenameRoomResponse(e)
+		}
+	case *RoomRenamed:
+		if h.OnRoomRenamed != nil {
+			h.OnRoomRenamed(e)
+		}
+	case *RoomMembers:
+		if h.OnRoomMembers != nil {
+			h.OnRoomMembers(e)
+		}
+	case *RoomMemberChange:
+		if h.OnRoomMemberChange != nil {
+			h.OnRoomMemberChange(e)
+		}
+	case *RoomMessageFrom:
+		if h.OnRoomMessageFrom != nil {
+			h.OnRoomMessageFrom(e)
+		}
+	case *Rooms:
+		if h.OnRooms != nil {
+			h.OnRooms(e)
+		}
+	case *CallInviteResponse:
+		if h.OnCallInviteResponse != nil {
+			h.OnCallInviteResponse(e)
+		}
+	case *CallIncoming:
+		if h.OnCallIncoming != nil {
+			h.OnCallIncoming(e)
+		}
+	case *CallStateChange:
+		if h.OnCallStateChange != nil {
+			h.OnCallStateChange(e)
+		}
+	case *LogoutEverywhereResult:
+		if h.OnLogoutEverywhereResult != nil {
+			h.OnLogoutEverywhereResult(e)
+		}
+	case *SessionRevoked:
+		if h.OnSessionRevoked != nil {
+			h.OnSessionRevoked(e)
+		}
+	case *ServerShutdown:
+		if h.OnServerShutdown != nil {
+			h.OnServerShutdown(e)
+		}
+	case *RemoveContactResponse:
+		if h.OnRemoveContactResponse != nil {
+			h.OnRemoveContactResponse(e)
+		}
+	case *ContactRemoved:
+		if h.OnContactRemoved != nil {
+			h.OnContactRemoved(e)
+		}
+	case *BlockUserResponse:
+		if h.OnBlockUserResponse != nil {
+			h.OnBlockUserResponse(e)
+		}
+	case *UnblockUserResponse:
+		if h.OnUnblockUserResponse != nil {
+			h.OnUnblockUserResponse(e)
+		}
+	case *BlockedUsers:
+		if h.OnBlockedUsers != nil {
+			h.OnBlockedUsers(e)
+		}
+	case *SetDisplayNameResponse:
+		if h.OnSetDisplayNameResponse != nil {
+			h.OnSetDisplayNameResponse(e)
+		}
+	case *ProfileChanged:
+		if h.OnProfileChanged != nil {
+			h.OnProfileChanged(e)
+		}
+	case *SetStatusTextResponse:
+		if h.OnSetStatusTextResponse != nil {
+			h.OnSetStatusTextResponse(e)
+		}
+	case *SetAvatarResponse:
+		if h.OnSetAvatarResponse != nil {
+			h.OnSetAvatarResponse(e)
+		}
+	case *MessageDelivered:
+		if h.OnMessageDelivered != nil {
+			h.OnMessageDelivered(e)
+		}
+	case *ReadReceiptFrom:
+		if h.OnReadReceiptFrom != nil {
+			h.OnReadReceiptFrom(e)
+		}
+	case *TypingStartedFrom:
+		if h.OnTypingStartedFrom != nil {
+			h.OnTypingStartedFrom(e)
+		}
+	case *TypingStoppedFrom:
+		if h.OnTypingStoppedFrom != nil {
+			h.OnTypingStoppedFrom(e)
+		}
+	case *Pong:
+		if h.OnPong != nil {
+			h.OnPong(e)
+		}
+	case *ProtocolError:
+		if h.OnProtocolError != nil {
+			h.OnProtocolError(e)
+		}
+	}
+}
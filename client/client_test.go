@@ -0,0 +1,313 @@
+package client
+
+import (
+	"chatServer/builders"
+	"chatServer/models"
+	"chatServer/server"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func TestDecode(t *testing.T) {
+	sentAt := time.Unix(1600000000, 0)
+	friend := models.FriendModel{ID: 2, Username: "bob", DisplayName: str("Bob"), Status: 1, StatusText: str("hi")}
+	user := &models.UserModel{ID: 2, Username: "bob", DisplayName: str("Bob"), Status: 1, StatusText: str("hi")}
+
+	tests := []struct {
+		packet *server.Packet
+		event  interface{}
+	}{
+		{
+			builders.NewLoginResultPacket(true, 1, str("Alice"), nil, []models.FriendModel{friend},
+				[]models.PendingContactModel{{ID: 3, Username: "carol", Message: str("add me")}}, str("token")),
+			&LoginResult{
+				Success:         true,
+				UserID:          1,
+				DisplayName:     str("Alice"),
+				Friends:         []models.FriendModel{friend},
+				PendingContacts: []models.PendingContactModel{{ID: 3, Username: "carol", Message: str("add me")}},
+				SessionToken:    str("token"),
+			},
+		},
+		{
+			builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil),
+			&LoginResult{},
+		},
+		{
+			builders.NewChatFromPacket(2, "hello", sentAt, 7),
+			&ChatFrom{FromUserID: 2, Message: "hello", SentAt: sentAt, MessageID: 7},
+		},
+		{
+			builders.NewConfirmContactResponsePacket(builders.ConfirmContactResultSuccess, 2, user),
+			&ConfirmContactResponse{ResultCode: builders.ConfirmContactResultSuccess, UserID: 2, Contact: &friend},
+		},
+		{
+			builders.NewConfirmContactResponsePacket(builders.ConfirmContactResultNotPending, 2, nil),
+			&ConfirmContactResponse{ResultCode: builders.ConfirmContactResultNotPending, UserID: 2},
+		},
+		{
+			builders.NewHistoryResultPacket(2, []models.MessageModel{{ID: 5, FromUserID: 2, Content: str("hi"), SentAt: sentAt}}),
+			&HistoryResult{ContactUserID: 2, Messages: []models.MessageModel{{ID: 5, FromUserID: 2, Content: str("hi"), SentAt: sentAt}}},
+		},
+		{
+			builders.NewRoomsPacket(nil),
+			&Rooms{Rooms: []models.RoomModel{}},
+		},
+		{
+			builders.NewMessageDeliveredPacket(2, 7, true),
+			&MessageDelivered{ToUserID: 2, MessageID: 7, Queued: true},
+		},
+		{
+			builders.NewServerShutdownPacket(),
+			&ServerShutdown{},
+		},
+	}
+
+	for _, test := range tests {
+		event, err := Decode(test.packet)
+		if err != nil {
+			t.Errorf("Failed to decode packet %v: %v", test.packet.ID, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(event, test.event) {
+			t.Errorf("Packet %v decoded as %+v, expected %+v.", test.packet.ID, event, test.event)
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	valid := *builders.NewChatFromPacket(2, "hello", time.Now(), 7).Data
+
+	truncated := valid[:len(valid)-1]
+	if _, err := Decode(&server.Packet{ID: builders.PacketIDChatFrom, Data: &truncated}); err == nil {
+		t.Errorf("Expected truncated data to fail.")
+	}
+
+	extra := append(append([]byte{}, valid...), 0)
+	if _, err := Decode(&server.Packet{ID: builders.PacketIDChatFrom, Data: &extra}); err == nil {
+		t.Errorf("Expected extra data to fail.")
+	}
+
+	// A string length larger than the packet.
+	long := []byte{2, 0, 0, 0, 0xff, 0xff, 0xff, 0x7f}
+	if _, err := Decode(&server.Packet{ID: builders.PacketIDChatFrom, Data: &long}); err == nil {
+		t.Errorf("Expected a string longer than the packet to fail.")
+	}
+
+	// A list count larger than the packet.
+	count := []byte{0xff, 0xff, 0xff, 0x7f}
+	if _, err := Decode(&server.Packet{ID: builders.PacketIDRooms, Data: &count}); err == nil {
+		t.Errorf("Expected a list longer than the packet to fail.")
+	}
+
+	if _, err := Decode(&server.Packet{ID: builders.PacketIDChat}); err == nil {
+		t.Errorf("Expected a packet the server doesn't send to fail.")
+	}
+}
+
+func TestRequestEncoding(t *testing.T) {
+	p := ChatRequest{ToUserID: 2, Message: "hi", MessageID: 7}.Packet()
+
+	expected := []byte{2, 0, 0, 0, 2, 0, 0, 0, 'h', 'i', 7, 0, 0, 0}
+	if p.ID != builders.PacketIDChat || !reflect.DeepEqual(*p.Data, expected) {
+		t.Errorf("Unexpected chat packet %v %v.", p.ID, *p.Data)
+	}
+
+	p = LoginRequest{Username: "alice", Password: "secret"}.Packet()
+	if p.ID != builders.PacketIDLogin || string(*p.Data) != "alice\nsecret" {
+		t.Errorf("Unexpected login packet %v %q.", p.ID, *p.Data)
+	}
+}
+
+// A server that accepts any password login and any token it issued, recording the logins.
+type testServer struct {
+	server *server.TCPServer
+	mutex  sync.Mutex
+	logins []string
+	pongs  []int
+}
+
+func startTestServer(t *testing.T) *testServer {
+	ts := &testServer{}
+
+	ts.server = server.NewTCPServer(ts.handlePacket,
+		func(s *server.TCPServer, c *server.Client, addr string) {},
+		func(s *server.TCPServer, c *server.Client, addr string) {})
+
+	if err := ts.server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ts.server.Run(ctx)
+
+	t.Cleanup(func() {
+		cancel()
+		ts.server.Shutdown(context.Background(), nil)
+	})
+
+	return ts
+}
+
+func (ts *testServer) handlePacket(s *server.TCPServer, c *server.Client, p *server.Packet) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	switch p.ID {
+	case builders.PacketIDLogin:
+		ts.logins = append(ts.logins, "password")
+	case builders.PacketIDTokenLogin:
+		if string(*p.Data) != "token" {
+			c.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+			return
+		}
+		ts.logins = append(ts.logins, "token")
+	case builders.PacketIDPong:
+		ts.pongs = append(ts.pongs, newReader(p).int32())
+		return
+	default:
+		return
+	}
+
+	c.UserID = 1
+	c.LoggedIn = true
+	c.SendPacket(builders.NewPingPacket(len(ts.logins)))
+	c.SendPacket(builders.NewLoginResultPacket(true, 1, nil, nil, nil, nil, str("token")))
+}
+
+// Disconnects the logged in clients.
+func (ts *testServer) disconnect() {
+	for _, c := range ts.server.GetClientsByID(1) {
+		c.CloseNow()
+	}
+}
+
+func (ts *testServer) waitForPongs(t *testing.T, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+
+	for {
+		ts.mutex.Lock()
+		pongs := len(ts.pongs)
+		ts.mutex.Unlock()
+
+		if pongs >= count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v pongs, got %v.", count, pongs)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ts := startTestServer(t)
+
+	reconnected := make(chan *LoginResult, 1)
+	disconnected := make(chan error, 1)
+
+	c, result, err := Dial(Config{
+		Address:        ts.server.Addr().String(),
+		Username:       "alice",
+		Password:       "secret",
+		Reconnect:      true,
+		ReconnectDelay: 10 * time.Millisecond,
+	}, Handlers{
+		OnDisconnect: func(err error) { disconnected <- err },
+		OnReconnect:  func(result *LoginResult) { reconnected <- result },
+	})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	if result.UserID != 1 || c.SessionToken() != "token" {
+		t.Fatalf("Unexpected login result %+v.", result)
+	}
+
+	// Pings sent while logging in are answered.
+	ts.waitForPongs(t, 1)
+
+	ts.disconnect()
+
+	select {
+	case <-disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected a disconnect.")
+	}
+
+	select {
+	case <-reconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected to reconnect.")
+	}
+
+	ts.waitForPongs(t, 2)
+
+	ts.mutex.Lock()
+	if logins := strings.Join(ts.logins, ","); logins != "password,token" {
+		t.Errorf("Expected a password then a token login, got %v.", logins)
+	}
+	ts.mutex.Unlock()
+
+	if err := c.Send(NudgeRequest{ToUserID: 2}); err != nil {
+		t.Errorf("Failed to send after reconnecting: %v", err)
+	}
+}
+
+func TestSessionRevoked(t *testing.T) {
+	ts := startTestServer(t)
+
+	c, _, err := Dial(Config{
+		Address:        ts.server.Addr().String(),
+		Username:       "alice",
+		Password:       "secret",
+		Reconnect:      true,
+		ReconnectDelay: 10 * time.Millisecond,
+	}, Handlers{})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	for _, sc := range ts.server.GetClientsByID(1) {
+		sc.SendPacket(builders.NewSessionRevokedPacket())
+		sc.Close()
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the client to stop.")
+	}
+
+	if !errors.Is(c.Err(), ErrSessionRevoked) {
+		t.Errorf("Expected %v, got %v.", ErrSessionRevoked, c.Err())
+	}
+
+	if err := c.Send(NudgeRequest{ToUserID: 2}); err != ErrClosed {
+		t.Errorf("Expected %v, got %v.", ErrClosed, err)
+	}
+}
+
+func TestLoginDenied(t *testing.T) {
+	ts := startTestServer(t)
+
+	_, _, err := Dial(Config{Address: ts.server.Addr().String(), SessionToken: "expired"}, Handlers{})
+	if err != ErrLoginDenied {
+		t.Errorf("Expected %v, got %v.", ErrLoginDenied, err)
+	}
+}
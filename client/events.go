@@ -0,0 +1,538 @@
+package client
+
+import (
+	"chatServer/builders"
+	"chatServer/models"
+	"chatServer/server"
+	"fmt"
+	"time"
+)
+
+// LoginResult is the result of a login. Only Success is set if the login was denied.
+type LoginResult struct {
+	Success         bool
+	UserID          int
+	DisplayName     *string
+	StatusText      *string
+	Friends         []models.FriendModel
+	PendingContacts []models.PendingContactModel
+	SessionToken    *string
+}
+
+// UserStatusChange tells that a contacts presence status changed.
+type UserStatusChange struct {
+	UserID int
+	Status int
+}
+
+// ChatFrom is a chat message from a contact.
+type ChatFrom struct {
+	FromUserID int
+	Message    string
+	SentAt     time.Time
+	MessageID  int
+}
+
+// ActionFrom is an action from a contact.
+type ActionFrom struct {
+	FromUserID int
+	Action     string
+}
+
+// NudgeFrom is a nudge from a contact.
+type NudgeFrom struct {
+	FromUserID int
+}
+
+// Audio is audio data from the other participant of a call.
+type Audio struct {
+	Data []byte
+}
+
+// AddContactResponse is the result of an add contact request.
+type AddContactResponse struct {
+	ResultCode int
+}
+
+// NotifyAddRequest tells that a user asked to become a contact.
+type NotifyAddRequest struct {
+	UserID      int
+	Username    *string
+	DisplayName *string
+	Message     *string
+}
+
+// ConfirmContactResponse is the result of a confirm contact request. Contact is only set on success.
+type ConfirmContactResponse struct {
+	ResultCode int
+	UserID     int
+	Contact    *models.FriendModel
+}
+
+// RejectContactResponse is the result of a reject contact request.
+type RejectContactResponse struct {
+	ResultCode int
+	UserID     int
+}
+
+// AddContactAccepted tells that a user accepted a contact request.
+type AddContactAccepted struct {
+	Contact models.FriendModel
+}
+
+// ImageFrom is an image from a contact.
+type ImageFrom struct {
+	FromUserID int
+	ImageData  *string
+}
+
+// HistoryResult is a page of the conversation with a contact, newest message first.
+type HistoryResult struct {
+	ContactUserID int
+	Messages      []models.MessageModel
+}
+
+// DeliveryFailed tells that a direct packet to a user was not delivered.
+type DeliveryFailed struct {
+	ToUserID int
+	PacketID int
+	Reason   int
+}
+
+// CreateRoomResponse is the result of a create room request.
+type CreateRoomResponse struct {
+	ResultCode int
+	RoomID     int
+	Name       *string
+}
+
+// InviteToRoomResponse is the result of an invite to room request.
+type InviteToRoomResponse struct {
+	ResultCode int
+	RoomID     int
+	UserID     int
+}
+
+// AddedToRoom tells that the user was added to a room.
+type AddedToRoom struct {
+	RoomID          int
+	Name            *string
+	InvitedByUserID int
+}
+
+// LeaveRoomResponse is the result of a leave room request.
+type LeaveRoomResponse struct {
+	ResultCode int
+	RoomID     int
+}
+
+// RenameRoomResponse is the result of a rename room request.
+type RenameRoomResponse struct {
+	ResultCode int
+	RoomID     int
+}
+
+// RoomRenamed tells that a room was renamed.
+type RoomRenamed struct {
+	RoomID   int
+	ByUserID int
+	Name     *string
+}
+
+// RoomMembers lists the members of a room.
+type RoomMembers struct {
+	ResultCode int
+	RoomID     int
+	Members    []models.RoomMemberModel
+}
+
+// RoomMemberChange tells that a user joined or left a room.
+type RoomMemberChange struct {
+	RoomID int
+	UserID int
+	Change int
+}
+
+// RoomMessageFrom is a message sent to a room.
+type RoomMessageFrom struct {
+	RoomID     int
+	FromUserID int
+	Message    string
+	SentAt     time.Time
+}
+
+// Rooms lists the rooms the user is a member of.
+type Rooms struct {
+	Rooms []models.RoomModel
+}
+
+// CallInviteResponse is the result of a call invite request.
+type CallInviteResponse struct {
+	ResultCode   int
+	CallID       int
+	CalleeUserID int
+}
+
+// CallIncoming tells that a contact is calling.
+type CallIncoming struct {
+	CallID       int
+	CallerUserID int
+}
+
+// CallStateChange tells that a call was accepted, declined or ended.
+type CallStateChange struct {
+	CallID   int
+	ByUserID int
+	State    int
+}
+
+// LogoutEverywhereResult is the result of a logout everywhere request.
+type LogoutEverywhereResult struct {
+	ResultCode int
+}
+
+// SessionRevoked tells that the session was revoked and the client is being disconnected.
+type SessionRevoked struct{}
+
+// ServerShutdown tells that the server is shutting down and the client is being disconnected.
+type ServerShutdown struct{}
+
+// RemoveContactResponse is the result of a remove contact request.
+type RemoveContactResponse struct {
+	ResultCode int
+	UserID     int
+}
+
+// ContactRemoved tells that a user removed the user from their contacts.
+type ContactRemoved struct {
+	UserID int
+}
+
+// BlockUserResponse is the result of a block user request.
+type BlockUserResponse struct {
+	ResultCode int
+	UserID     int
+}
+
+// UnblockUserResponse is the result of an unblock user request.
+type UnblockUserResponse struct {
+	ResultCode int
+	UserID     int
+}
+
+// BlockedUsers lists the users the user has blocked.
+type BlockedUsers struct {
+	Users []models.BlockedUserModel
+}
+
+// SetDisplayNameResponse is the result of a set display name request.
+type SetDisplayNameResponse struct {
+	ResultCode  int
+	DisplayName *string
+}
+
+// ProfileChanged tells that a contact, or the user on another client, changed their profile.
+type ProfileChanged struct {
+	UserID      int
+	DisplayName *string
+	StatusText  *string
+	ImageURL    *string
+}
+
+// SetStatusTextResponse is the result of a set status text request.
+type SetStatusTextResponse struct {
+	ResultCode int
+	StatusText *string
+}
+
+// SetAvatarResponse is the result of a set avatar request.
+type SetAvatarResponse struct {
+	ResultCode int
+	ImageURL   *string
+}
+
+// MessageDelivered tells that a chat message reached the recipient, or was queued for their next login.
+type MessageDelivered struct {
+	ToUserID  int
+	MessageID int
+	Queued    bool
+}
+
+// ReadReceiptFrom tells that a contact read a chat message.
+type ReadReceiptFrom struct {
+	ReaderUserID int
+	MessageID    int
+}
+
+// TypingStartedFrom tells that a contact started typing.
+type TypingStartedFrom struct {
+	FromUserID int
+}
+
+// TypingStoppedFrom tells that a contact stopped typing.
+type TypingStoppedFrom struct {
+	FromUserID int
+}
+
+// Ping asks the client for a pong with the same sequence.
+type Ping struct {
+	Sequence int
+}
+
+// Pong answers a ping from the client.
+type Pong struct {
+	Sequence int
+}
+
+// ProtocolError tells that the server refused a packet it couldn't decode. Reason is a builders.ProtocolError code.
+type ProtocolError struct {
+	PacketID int
+	Reason   int
+}
+
+// Decode decodes a packet sent by the server into its event type, such as *ChatFrom for a chat from packet. An error
+// is returned for unknown packet ids, and for data that is truncated or longer than the packet layout.
+func Decode(p *server.Packet) (interface{}, error) {
+	r := newReader(p)
+
+	var event interface{}
+
+	switch p.ID {
+	case builders.PacketIDLoginResult:
+		result := &LoginResult{Success: r.bool()}
+
+		if result.Success {
+			result.UserID = r.int32()
+			result.DisplayName = r.string()
+			result.StatusText = r.string()
+
+			result.Friends = make([]models.FriendModel, r.count())
+			for i := range result.Friends {
+				result.Friends[i] = models.FriendModel{
+					ID:          r.int32(),
+					Username:    r.text(),
+					DisplayName: r.string(),
+					Status:      r.int32(),
+					ImageURL:    r.string(),
+					StatusText:  r.string(),
+				}
+			}
+
+			result.PendingContacts = make([]models.PendingContactModel, r.count())
+			for i := range result.PendingContacts {
+				result.PendingContacts[i] = models.PendingContactModel{
+					ID:          r.int32(),
+					Username:    r.text(),
+					DisplayName: r.string(),
+					ImageURL:    r.string(),
+					Message:     r.string(),
+				}
+			}
+
+			result.SessionToken = r.string()
+		}
+		event = result
+
+	case builders.PacketIDUserStatusChange:
+		event = &UserStatusChange{UserID: r.int32(), Status: r.int32()}
+
+	case builders.PacketIDChatFrom:
+		event = &ChatFrom{FromUserID: r.int32(), Message: r.text(), SentAt: r.time(), MessageID: r.int32()}
+
+	case builders.PacketIDActionFrom:
+		event = &ActionFrom{FromUserID: r.int32(), Action: r.text()}
+
+	case builders.PacketIDNudgeFrom:
+		event = &NudgeFrom{FromUserID: r.int32()}
+
+	case builders.PacketIDAudio:
+		event = &Audio{Data: r.rest()}
+
+	case builders.PacketIDAddContactResponse:
+		event = &AddContactResponse{ResultCode: r.int32()}
+
+	case builders.PacketIDNotifyAddRequest:
+		event = &NotifyAddRequest{UserID: r.int32(), Username: r.string(), DisplayName: r.string(), Message: r.string()}
+
+	case builders.PacketIDConfirmContactResponse:
+		response := &ConfirmContactResponse{ResultCode: r.int32(), UserID: r.int32()}
+
+		if response.ResultCode == builders.ConfirmContactResultSuccess {
+			response.Contact = &models.FriendModel{
+				ID:          response.UserID,
+				Username:    r.text(),
+				DisplayName: r.string(),
+				Status:      r.int32(),
+				ImageURL:    r.string(),
+				StatusText:  r.string(),
+			}
+		}
+		event = response
+
+	case builders.PacketIDRejectContactResponse:
+		event = &RejectContactResponse{ResultCode: r.int32(), UserID: r.int32()}
+
+	case builders.PacketIDAddContactAccepted:
+		event = &AddContactAccepted{Contact: models.FriendModel{
+			ID:          r.int32(),
+			Username:    r.text(),
+			DisplayName: r.string(),
+			Status:      r.int32(),
+			ImageURL:    r.string(),
+			StatusText:  r.string(),
+		}}
+
+	case builders.PacketIDImageFrom:
+		event = &ImageFrom{FromUserID: r.int32(), ImageData: r.string()}
+
+	case builders.PacketIDHistoryResult:
+		result := &HistoryResult{ContactUserID: r.int32()}
+
+		result.Messages = make([]models.MessageModel, r.count())
+		for i := range result.Messages {
+			result.Messages[i] = models.MessageModel{
+				ID:          r.int32(),
+				FromUserID:  r.int32(),
+				MessageType: r.int32(),
+				Content:     r.string(),
+				SentAt:      r.time(),
+			}
+		}
+		event = result
+
+	case builders.PacketIDDeliveryFailed:
+		event = &DeliveryFailed{ToUserID: r.int32(), PacketID: r.int32(), Reason: r.int32()}
+
+	case builders.PacketIDCreateRoomResponse:
+		event = &CreateRoomResponse{ResultCode: r.int32(), RoomID: r.int32(), Name: r.string()}
+
+	case builders.PacketIDInviteToRoomResponse:
+		event = &InviteToRoomResponse{ResultCode: r.int32(), RoomID: r.int32(), UserID: r.int32()}
+
+	case builders.PacketIDAddedToRoom:
+		event = &AddedToRoom{RoomID: r.int32(), Name: r.string(), InvitedByUserID: r.int32()}
+
+	case builders.PacketIDLeaveRoomResponse:
+		event = &LeaveRoomResponse{ResultCode: r.int32(), RoomID: r.int32()}
+
+	case builders.PacketIDRenameRoomResponse:
+		event = &RenameRoomResponse{ResultCode: r.int32(), RoomID: r.int32()}
+
+	case builders.PacketIDRoomRenamed:
+		event = &RoomRenamed{RoomID: r.int32(), ByUserID: r.int32(), Name: r.string()}
+
+	case builders.PacketIDRoomMembers:
+		members := &RoomMembers{ResultCode: r.int32(), RoomID: r.int32()}
+
+		members.Members = make([]models.RoomMemberModel, r.count())
+		for i := range members.Members {
+			members.Members[i] = models.RoomMemberModel{
+				ID:          r.int32(),
+				Username:    r.text(),
+				DisplayName: r.string(),
+				Status:      r.int32(),
+			}
+		}
+		event = members
+
+	case builders.PacketIDRoomMemberChange:
+		event = &RoomMemberChange{RoomID: r.int32(), UserID: r.int32(), Change: r.int32()}
+
+	case builders.PacketIDRoomMessageFrom:
+		event = &RoomMessageFrom{RoomID: r.int32(), FromUserID: r.int32(), Message: r.text(), SentAt: r.time()}
+
+	case builders.PacketIDRooms:
+		rooms := &Rooms{}
+
+		rooms.Rooms = make([]models.RoomModel, r.count())
+		for i := range rooms.Rooms {
+			rooms.Rooms[i] = models.RoomModel{ID: r.int32(), Name: r.text()}
+		}
+		event = rooms
+
+	case builders.PacketIDCallInviteResponse:
+		event = &CallInviteResponse{ResultCode: r.int32(), CallID: r.int32(), CalleeUserID: r.int32()}
+
+	case builders.PacketIDCallIncoming:
+		event = &CallIncoming{CallID: r.int32(), CallerUserID: r.int32()}
+
+	case builders.PacketIDCallStateChange:
+		event = &CallStateChange{CallID: r.int32(), ByUserID: r.int32(), State: r.int32()}
+
+	case builders.PacketIDLogoutEverywhereResult:
+		event = &LogoutEverywhereResult{ResultCode: r.int32()}
+
+	case builders.PacketIDSessionRevoked:
+		event = &SessionRevoked{}
+
+	case builders.PacketIDServerShutdown:
+		event = &ServerShutdown{}
+
+	case builders.PacketIDRemoveContactResponse:
+		event = &RemoveContactResponse{ResultCode: r.int32(), UserID: r.int32()}
+
+	case builders.PacketIDContactRemoved:
+		event = &ContactRemoved{UserID: r.int32()}
+
+	case builders.PacketIDBlockUserResponse:
+		event = &BlockUserResponse{ResultCode: r.int32(), UserID: r.int32()}
+
+	case builders.PacketIDUnblockUserResponse:
+		event = &UnblockUserResponse{ResultCode: r.int32(), UserID: r.int32()}
+
+	case builders.PacketIDBlockedUsers:
+		users := &BlockedUsers{}
+
+		users.Users = make([]models.BlockedUserModel, r.count())
+		for i := range users.Users {
+			users.Users[i] = models.BlockedUserModel{ID: r.int32(), Username: r.text(), DisplayName: r.string()}
+		}
+		event = users
+
+	case builders.PacketIDSetDisplayNameResponse:
+		event = &SetDisplayNameResponse{ResultCode: r.int32(), DisplayName: r.string()}
+
+	case builders.PacketIDProfileChanged:
+		event = &ProfileChanged{UserID: r.int32(), DisplayName: r.string(), StatusText: r.string(), ImageURL: r.string()}
+
+	case builders.PacketIDSetStatusTextResponse:
+		event = &SetStatusTextResponse{ResultCode: r.int32(), StatusText: r.string()}
+
+	case builders.PacketIDSetAvatarResponse:
+		event = &SetAvatarResponse{ResultCode: r.int32(), ImageURL: r.string()}
+
+	case builders.PacketIDMessageDelivered:
+		event = &MessageDelivered{ToUserID: r.int32(), MessageID: r.int32(), Queued: r.bool()}
+
+	case builders.PacketIDReadReceiptFrom:
+		event = &ReadReceiptFrom{ReaderUserID: r.int32(), MessageID: r.int32()}
+
+	case builders.PacketIDTypingStartedFrom:
+		event = &TypingStartedFrom{FromUserID: r.int32()}
+
+	case builders.PacketIDTypingStoppedFrom:
+		event = &TypingStoppedFrom{FromUserID: r.int32()}
+
+	case builders.PacketIDPing:
+		event = &Ping{Sequence: r.int32()}
+
+	case builders.PacketIDPong:
+		event = &Pong{Sequence: r.int32()}
+
+	case builders.PacketIDProtocolError:
+		event = &ProtocolError{PacketID: r.int32(), Reason: r.int32()}
+
+	default:
+		return nil, fmt.Errorf("unknown packet id %v", p.ID)
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("packet id %v: %v", p.ID, r.err)
+	}
+
+	if r.more() {
+		return nil, fmt.Errorf("packet id %v: %v bytes left after decoding", p.ID, len(r.data))
+	}
+
+	return event, nil
+}
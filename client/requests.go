@@ -0,0 +1,497 @@
+package client
+
+import (
+	"chatServer/builders"
+	"chatServer/server"
+)
+
+// Request is a packet sent from a client to the server.
+type Request interface {
+	// Packet encodes the request.
+	Packet() *server.Packet
+}
+
+// LoginRequest logs in with a username and password.
+type LoginRequest struct {
+	Username string
+	Password string
+}
+
+// Packet encodes the request.
+func (r LoginRequest) Packet() *server.Packet {
+	/*
+		Username (string)
+		\n
+		Password (string)
+	*/
+	return rawPacket(builders.PacketIDLogin, []byte(r.Username+"\n"+r.Password))
+}
+
+// TokenLoginRequest resumes a session with the token from an earlier login.
+type TokenLoginRequest struct {
+	Token string
+}
+
+// Packet encodes the request.
+func (r TokenLoginRequest) Packet() *server.Packet {
+	/*
+		Token (string)
+	*/
+	return rawPacket(builders.PacketIDTokenLogin, []byte(r.Token))
+}
+
+// ChatRequest sends a chat message to a contact. A message id of 0 asks for no delivered or read receipts.
+type ChatRequest struct {
+	ToUserID  int
+	Message   string
+	MessageID int
+}
+
+// Packet encodes the request.
+func (r ChatRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+		MessageLen (int32)
+		Message (string)
+		MessageId (int32)
+	*/
+	return new(writer).int32(r.ToUserID).string(r.Message).int32(r.MessageID).packet(builders.PacketIDChat)
+}
+
+// NudgeRequest nudges a contact.
+type NudgeRequest struct {
+	ToUserID int
+}
+
+// Packet encodes the request.
+func (r NudgeRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+	*/
+	return new(writer).int32(r.ToUserID).packet(builders.PacketIDNudge)
+}
+
+// AudioRequest sends audio data to the other participant of a call.
+type AudioRequest struct {
+	Data []byte
+}
+
+// Packet encodes the request.
+func (r AudioRequest) Packet() *server.Packet {
+	/*
+		Data (bytes)
+	*/
+	return rawPacket(builders.PacketIDAudio, r.Data)
+}
+
+// PingRequest asks the server for a pong with the same sequence.
+type PingRequest struct {
+	Sequence int
+}
+
+// Packet encodes the request.
+func (r PingRequest) Packet() *server.Packet {
+	/*
+		Sequence (int32)
+	*/
+	return new(writer).int32(r.Sequence).packet(builders.PacketIDPing)
+}
+
+// PongRequest answers a ping from the server.
+type PongRequest struct {
+	Sequence int
+}
+
+// Packet encodes the request.
+func (r PongRequest) Packet() *server.Packet {
+	/*
+		Sequence (int32)
+	*/
+	return new(writer).int32(r.Sequence).packet(builders.PacketIDPong)
+}
+
+// ActionRequest sends an action, such as "waves", to a contact.
+type ActionRequest struct {
+	ToUserID int
+	Action   string
+}
+
+// Packet encodes the request.
+func (r ActionRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+		ActionLen (int32)
+		Action (string)
+	*/
+	return new(writer).int32(r.ToUserID).string(r.Action).packet(builders.PacketIDAction)
+}
+
+// SetDisplayNameRequest changes the users display name.
+type SetDisplayNameRequest struct {
+	DisplayName string
+}
+
+// Packet encodes the request.
+func (r SetDisplayNameRequest) Packet() *server.Packet {
+	/*
+		DisplayName (string)
+	*/
+	return rawPacket(builders.PacketIDSetDisplayName, []byte(r.DisplayName))
+}
+
+// UserStatusChangeRequest changes the users presence status. Status 0 is treated as invisible.
+type UserStatusChangeRequest struct {
+	Status int
+}
+
+// Packet encodes the request.
+func (r UserStatusChangeRequest) Packet() *server.Packet {
+	/*
+		Status (int32)
+	*/
+	return new(writer).int32(r.Status).packet(builders.PacketIDUserStatusChange)
+}
+
+// AddContactRequest asks a user to become a contact.
+type AddContactRequest struct {
+	Username string
+	Message  string
+}
+
+// Packet encodes the request.
+func (r AddContactRequest) Packet() *server.Packet {
+	/*
+		UsernameLen (int32)
+		Username (string)
+		MessageLen (int32)
+		Message (string)
+	*/
+	return new(writer).string(r.Username).string(r.Message).packet(builders.PacketIDAddContact)
+}
+
+// ConfirmContactRequest accepts the contact request of a user.
+type ConfirmContactRequest struct {
+	UserID int
+}
+
+// Packet encodes the request.
+func (r ConfirmContactRequest) Packet() *server.Packet {
+	/*
+		RequestedUserId (int32)
+	*/
+	return new(writer).int32(r.UserID).packet(builders.PacketIDConfirmContact)
+}
+
+// RejectContactRequest rejects the contact request of a user.
+type RejectContactRequest struct {
+	UserID int
+}
+
+// Packet encodes the request.
+func (r RejectContactRequest) Packet() *server.Packet {
+	/*
+		RequestedUserId (int32)
+	*/
+	return new(writer).int32(r.UserID).packet(builders.PacketIDRejectContact)
+}
+
+// ImageRequest sends an image to a contact.
+type ImageRequest struct {
+	ToUserID  int
+	ImageData string
+}
+
+// Packet encodes the request.
+func (r ImageRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+		ImageDataLen (int32)
+		ImageData (string)
+	*/
+	return new(writer).int32(r.ToUserID).string(r.ImageData).packet(builders.PacketIDImage)
+}
+
+// GetHistoryRequest fetches a page of the conversation with a contact, older than a message. A message id of 0
+// fetches the newest page.
+type GetHistoryRequest struct {
+	ContactUserID   int
+	BeforeMessageID int
+}
+
+// Packet encodes the request.
+func (r GetHistoryRequest) Packet() *server.Packet {
+	/*
+		ContactUserId (int32)
+		BeforeMessageId (int32)
+	*/
+	return new(writer).int32(r.ContactUserID).int32(r.BeforeMessageID).packet(builders.PacketIDGetHistory)
+}
+
+// CreateRoomRequest creates a room.
+type CreateRoomRequest struct {
+	Name string
+}
+
+// Packet encodes the request.
+func (r CreateRoomRequest) Packet() *server.Packet {
+	/*
+		NameLen (int32)
+		Name (string)
+	*/
+	return new(writer).string(r.Name).packet(builders.PacketIDCreateRoom)
+}
+
+// InviteToRoomRequest adds a contact to a room.
+type InviteToRoomRequest struct {
+	RoomID int
+	UserID int
+}
+
+// Packet encodes the request.
+func (r InviteToRoomRequest) Packet() *server.Packet {
+	/*
+		RoomId (int32)
+		UserId (int32)
+	*/
+	return new(writer).int32(r.RoomID).int32(r.UserID).packet(builders.PacketIDInviteToRoom)
+}
+
+// LeaveRoomRequest leaves a room.
+type LeaveRoomRequest struct {
+	RoomID int
+}
+
+// Packet encodes the request.
+func (r LeaveRoomRequest) Packet() *server.Packet {
+	/*
+		RoomId (int32)
+	*/
+	return new(writer).int32(r.RoomID).packet(builders.PacketIDLeaveRoom)
+}
+
+// RenameRoomRequest renames a room.
+type RenameRoomRequest struct {
+	RoomID int
+	Name   string
+}
+
+// Packet encodes the request.
+func (r RenameRoomRequest) Packet() *server.Packet {
+	/*
+		RoomId (int32)
+		NameLen (int32)
+		Name (string)
+	*/
+	return new(writer).int32(r.RoomID).string(r.Name).packet(builders.PacketIDRenameRoom)
+}
+
+// GetRoomMembersRequest fetches the members of a room.
+type GetRoomMembersRequest struct {
+	RoomID int
+}
+
+// Packet encodes the request.
+func (r GetRoomMembersRequest) Packet() *server.Packet {
+	/*
+		RoomId (int32)
+	*/
+	return new(writer).int32(r.RoomID).packet(builders.PacketIDGetRoomMembers)
+}
+
+// RoomMessageRequest sends a message to every member of a room.
+type RoomMessageRequest struct {
+	RoomID  int
+	Message string
+}
+
+// Packet encodes the request.
+func (r RoomMessageRequest) Packet() *server.Packet {
+	/*
+		RoomId (int32)
+		MessageLen (int32)
+		Message (string)
+	*/
+	return new(writer).int32(r.RoomID).string(r.Message).packet(builders.PacketIDRoomMessage)
+}
+
+// GetRoomsRequest fetches the rooms the user is a member of.
+type GetRoomsRequest struct{}
+
+// Packet encodes the request.
+func (r GetRoomsRequest) Packet() *server.Packet {
+	return &server.Packet{ID: builders.PacketIDGetRooms}
+}
+
+// CallInviteRequest calls a contact.
+type CallInviteRequest struct {
+	CalleeUserID int
+}
+
+// Packet encodes the request.
+func (r CallInviteRequest) Packet() *server.Packet {
+	/*
+		CalleeUserId (int32)
+	*/
+	return new(writer).int32(r.CalleeUserID).packet(builders.PacketIDCallInvite)
+}
+
+// CallAcceptRequest accepts an incoming call.
+type CallAcceptRequest struct {
+	CallID int
+}
+
+// Packet encodes the request.
+func (r CallAcceptRequest) Packet() *server.Packet {
+	/*
+		CallId (int32)
+	*/
+	return new(writer).int32(r.CallID).packet(builders.PacketIDCallAccept)
+}
+
+// CallDeclineRequest declines an incoming call.
+type CallDeclineRequest struct {
+	CallID int
+}
+
+// Packet encodes the request.
+func (r CallDeclineRequest) Packet() *server.Packet {
+	/*
+		CallId (int32)
+	*/
+	return new(writer).int32(r.CallID).packet(builders.PacketIDCallDecline)
+}
+
+// CallHangupRequest ends a call.
+type CallHangupRequest struct {
+	CallID int
+}
+
+// Packet encodes the request.
+func (r CallHangupRequest) Packet() *server.Packet {
+	/*
+		CallId (int32)
+	*/
+	return new(writer).int32(r.CallID).packet(builders.PacketIDCallHangup)
+}
+
+// LogoutEverywhereRequest revokes every session of the user and disconnects their other clients.
+type LogoutEverywhereRequest struct{}
+
+// Packet encodes the request.
+func (r LogoutEverywhereRequest) Packet() *server.Packet {
+	return &server.Packet{ID: builders.PacketIDLogoutEverywhere}
+}
+
+// RemoveContactRequest removes a contact.
+type RemoveContactRequest struct {
+	UserID int
+}
+
+// Packet encodes the request.
+func (r RemoveContactRequest) Packet() *server.Packet {
+	/*
+		ContactUserId (int32)
+	*/
+	return new(writer).int32(r.UserID).packet(builders.PacketIDRemoveContact)
+}
+
+// BlockUserRequest blocks a user.
+type BlockUserRequest struct {
+	UserID int
+}
+
+// Packet encodes the request.
+func (r BlockUserRequest) Packet() *server.Packet {
+	/*
+		UserId (int32)
+	*/
+	return new(writer).int32(r.UserID).packet(builders.PacketIDBlockUser)
+}
+
+// UnblockUserRequest unblocks a user.
+type UnblockUserRequest struct {
+	UserID int
+}
+
+// Packet encodes the request.
+func (r UnblockUserRequest) Packet() *server.Packet {
+	/*
+		UserId (int32)
+	*/
+	return new(writer).int32(r.UserID).packet(builders.PacketIDUnblockUser)
+}
+
+// GetBlockedUsersRequest fetches the users the user has blocked.
+type GetBlockedUsersRequest struct{}
+
+// Packet encodes the request.
+func (r GetBlockedUsersRequest) Packet() *server.Packet {
+	return &server.Packet{ID: builders.PacketIDGetBlockedUsers}
+}
+
+// SetStatusTextRequest changes the users status text. An empty text clears it.
+type SetStatusTextRequest struct {
+	StatusText string
+}
+
+// Packet encodes the request.
+func (r SetStatusTextRequest) Packet() *server.Packet {
+	/*
+		StatusText (string)
+	*/
+	return rawPacket(builders.PacketIDSetStatusText, []byte(r.StatusText))
+}
+
+// SetAvatarRequest uploads a new avatar image.
+type SetAvatarRequest struct {
+	Image []byte
+}
+
+// Packet encodes the request.
+func (r SetAvatarRequest) Packet() *server.Packet {
+	/*
+		Image (bytes)
+	*/
+	return rawPacket(builders.PacketIDSetAvatar, r.Image)
+}
+
+// ReadReceiptRequest tells the sender of a chat message that it was read.
+type ReadReceiptRequest struct {
+	FromUserID int
+	MessageID  int
+}
+
+// Packet encodes the request.
+func (r ReadReceiptRequest) Packet() *server.Packet {
+	/*
+		FromUserId (int32)
+		MessageId (int32)
+	*/
+	return new(writer).int32(r.FromUserID).int32(r.MessageID).packet(builders.PacketIDReadReceipt)
+}
+
+// TypingStartedRequest tells a contact the user started typing to them.
+type TypingStartedRequest struct {
+	ToUserID int
+}
+
+// Packet encodes the request.
+func (r TypingStartedRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+	*/
+	return new(writer).int32(r.ToUserID).packet(builders.PacketIDTypingStarted)
+}
+
+// TypingStoppedRequest tells a contact the user stopped typing to them.
+type TypingStoppedRequest struct {
+	ToUserID int
+}
+
+// Packet encodes the request.
+func (r TypingStoppedRequest) Packet() *server.Packet {
+	/*
+		ToUserId (int32)
+	*/
+	return new(writer).int32(r.ToUserID).packet(builders.PacketIDTypingStopped)
+}
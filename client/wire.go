@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"chatServer/server"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrTruncated is returned when packet data ends in the middle of a field.
+var ErrTruncated = errors.New("packet data truncated")
+
+// Reads the fields of packet data. The first error is kept and every read after it returns a zero value, so a
+// packet can be read in full and checked once at the end.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func newReader(p *server.Packet) *reader {
+	r := &reader{}
+	if p.Data != nil {
+		r.data = *p.Data
+	}
+
+	return r
+}
+
+// Returns the next n bytes, or nil if there aren't enough left.
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(r.data) {
+		r.err = ErrTruncated
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+// Returns true if there is any data left.
+func (r *reader) more() bool {
+	return r.err == nil && len(r.data) > 0
+}
+
+func (r *reader) int32() int {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+
+	return int(int32(binary.LittleEndian.Uint32(b)))
+}
+
+func (r *reader) int64() int64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (r *reader) bool() bool {
+	b := r.next(1)
+	return b != nil && b[0] != 0
+}
+
+// Reads a length prefixed string. An empty string is returned as nil.
+func (r *reader) string() *string {
+	length := r.int32()
+	if length == 0 {
+		return nil
+	}
+
+	b := r.next(length)
+	if b == nil {
+		return nil
+	}
+
+	str := string(b)
+
+	return &str
+}
+
+// Reads a length prefixed string, where an empty string is not nil.
+func (r *reader) text() string {
+	if str := r.string(); str != nil {
+		return *str
+	}
+
+	return ""
+}
+
+// Reads a time sent as unix seconds.
+func (r *reader) time() time.Time {
+	return time.Unix(r.int64(), 0)
+}
+
+// Reads a list count. Every item takes at least one byte, so a count larger than the remaining data is truncated.
+func (r *reader) count() int {
+	count := r.int32()
+	if r.err == nil && (count < 0 || count > len(r.data)) {
+		r.err = ErrTruncated
+		return 0
+	}
+
+	return count
+}
+
+// Returns the rest of the data.
+func (r *reader) rest() []byte {
+	return r.next(len(r.data))
+}
+
+// Writes the fields of packet data, in the same format as the builders package.
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) int32(val int) *writer {
+	binary.Write(&w.buf, binary.LittleEndian, int32(val))
+	return w
+}
+
+func (w *writer) string(str string) *writer {
+	w.int32(len(str))
+	w.buf.WriteString(str)
+	return w
+}
+
+func (w *writer) packet(id int64) *server.Packet {
+	data := w.buf.Bytes()
+
+	return &server.Packet{
+		ID:   id,
+		Data: &data,
+	}
+}
+
+// Creates a packet holding raw data.
+func rawPacket(id int64, data []byte) *server.Packet {
+	return &server.Packet{
+		ID:   id,
+		Data: &data,
+	}
+}
+
+// Writes a packet in TLV format: the packet id and data length as little endian int32s, followed by the data.
+func writePacket(w io.Writer, p *server.Packet) error {
+	var data []byte
+	if p.Data != nil {
+		data = *p.Data
+	}
+
+	buf := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint32(buf[:4], uint32(p.ID))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(data)))
+	buf = append(buf, data...)
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+// Reads a packet in TLV format. Packets with data longer than the maximum length are refused.
+func readPacket(r io.Reader, maxLength int64) (*server.Packet, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	id := int64(binary.LittleEndian.Uint32(header[:4]))
+	length := int64(binary.LittleEndian.Uint32(header[4:]))
+
+	if length > maxLength {
+		return nil, fmt.Errorf("packet %v length %v above maximum allowed", id, length)
+	}
+
+	p := &server.Packet{ID: id}
+
+	if length > 0 {
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		p.Data = &data
+	}
+
+	return p, nil
+}
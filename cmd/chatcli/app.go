@@ -0,0 +1,652 @@
+package main
+
+import (
+	"chatServer/builders"
+	"chatServer/client"
+	"chatServer/dbaccess"
+	"chatServer/server"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The statuses a user can choose, by the name used in the /status command.
+var statusNames = map[string]int{
+	"online":    dbaccess.StatusOnline,
+	"away":      dbaccess.StatusAway,
+	"busy":      dbaccess.StatusBusy,
+	"invisible": dbaccess.StatusInvisible,
+}
+
+// Returns the name of a status shown in the roster.
+func statusName(status int) string {
+	switch status {
+	case dbaccess.StatusOnline:
+		return "online"
+	case dbaccess.StatusAway:
+		return "away"
+	case dbaccess.StatusBusy:
+		return "busy"
+	case dbaccess.StatusInvisible:
+		return "invisible"
+	}
+
+	return "offline"
+}
+
+// A user on the roster.
+type contact struct {
+	id          int
+	username    string
+	displayName string
+	status      int
+	statusText  string
+}
+
+// The conversation with a contact. Lines are kept while the window is in the background, and shown when it is opened.
+type window struct {
+	userID int
+	lines  []string
+	unread int
+
+	// Messages to send a read receipt for once they are shown.
+	unreadMessageIDs []int
+}
+
+// app is the state of the chat client: the roster, pending contact requests and chat windows. Commands typed by the
+// user and events from the server are both handled under its mutex, and everything is written to out.
+type app struct {
+	mutex *sync.Mutex
+	out   io.Writer
+	send  func(r client.Request) error
+	chat  func(toUserID int, message string) (int, error)
+
+	userID      int
+	displayName string
+	contacts    map[int]*contact
+	pending     map[int]*contact
+	windows     map[int]*window
+	current     *window
+}
+
+func newApp(out io.Writer) *app {
+	return &app{
+		mutex:    &sync.Mutex{},
+		out:      out,
+		contacts: map[int]*contact{},
+		pending:  map[int]*contact{},
+		windows:  map[int]*window{},
+	}
+}
+
+// Uses the client to send requests.
+func (a *app) setClient(c *client.Client) {
+	a.send = c.Send
+	a.chat = c.SendChat
+}
+
+// Sends a request, telling the user if it couldn't be sent. Returns true if it was sent.
+func (a *app) request(r client.Request) bool {
+	if err := a.send(r); err != nil {
+		a.printf("Failed to send: %v", err)
+		return false
+	}
+
+	return true
+}
+
+func (a *app) printf(format string, args ...interface{}) {
+	fmt.Fprintf(a.out, format+"\n", args...)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+// Returns the name a user is shown with.
+func (a *app) nameOf(userID int) string {
+	if c, ok := a.contacts[userID]; ok {
+		if c.displayName != "" {
+			return c.displayName
+		}
+		return c.username
+	}
+
+	if c, ok := a.pending[userID]; ok {
+		return c.username
+	}
+
+	return fmt.Sprintf("user %v", userID)
+}
+
+// Finds a user by username or id in the specified list.
+func find(users map[int]*contact, name string) *contact {
+	if id, err := strconv.Atoi(name); err == nil {
+		return users[id]
+	}
+
+	for _, c := range users {
+		if strings.EqualFold(c.username, name) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Replaces the roster and pending requests with a login result.
+func (a *app) loggedIn(result *client.LoginResult) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.userID = result.UserID
+	a.displayName = deref(result.DisplayName)
+	a.contacts = map[int]*contact{}
+	a.pending = map[int]*contact{}
+
+	for _, f := range result.Friends {
+		a.contacts[f.ID] = &contact{
+			id:          f.ID,
+			username:    f.Username,
+			displayName: deref(f.DisplayName),
+			status:      f.Status,
+			statusText:  deref(f.StatusText),
+		}
+	}
+
+	for _, p := range result.PendingContacts {
+		a.pending[p.ID] = &contact{id: p.ID, username: p.Username, displayName: deref(p.DisplayName)}
+	}
+
+	a.printf("Logged in as %v.", a.displayName)
+	a.printRoster()
+}
+
+// Prints the contacts, online first, and any contact requests waiting for an answer.
+func (a *app) printRoster() {
+	contacts := make([]*contact, 0, len(a.contacts))
+	for _, c := range a.contacts {
+		contacts = append(contacts, c)
+	}
+
+	sort.Slice(contacts, func(i, j int) bool {
+		online, otherOnline := contacts[i].status != dbaccess.StatusOffline, contacts[j].status != dbaccess.StatusOffline
+		if online != otherOnline {
+			return online
+		}
+		return strings.ToLower(contacts[i].username) < strings.ToLower(contacts[j].username)
+	})
+
+	a.printf("Contacts (%v):", len(contacts))
+	for _, c := range contacts {
+		line := fmt.Sprintf("  %-10v %v (%v)", statusName(c.status), a.nameOf(c.id), c.username)
+		if c.statusText != "" {
+			line += " - " + c.statusText
+		}
+		if w := a.windows[c.id]; w != nil && w.unread > 0 {
+			line += fmt.Sprintf(" [%v unread]", w.unread)
+		}
+		a.printf("%v", line)
+	}
+
+	for _, p := range a.pending {
+		a.printf("  Request from %v - /accept %v or /reject %v", p.username, p.username, p.username)
+	}
+}
+
+// Adds a line to a contacts window, showing it if the window is open.
+func (a *app) addLine(userID int, line string, messageID int) {
+	w := a.windows[userID]
+	if w == nil {
+		w = &window{userID: userID}
+		a.windows[userID] = w
+	}
+
+	w.lines = append(w.lines, line)
+
+	if w == a.current {
+		a.printf("%v", line)
+		if messageID != 0 {
+			a.send(client.ReadReceiptRequest{FromUserID: userID, MessageID: messageID})
+		}
+		return
+	}
+
+	w.unread++
+	if messageID != 0 {
+		w.unreadMessageIDs = append(w.unreadMessageIDs, messageID)
+	}
+
+	if w.unread == 1 {
+		a.printf("* New message from %v - /chat %v", a.nameOf(userID), a.usernameOf(userID))
+	}
+}
+
+func (a *app) usernameOf(userID int) string {
+	if c, ok := a.contacts[userID]; ok {
+		return c.username
+	}
+
+	return strconv.Itoa(userID)
+}
+
+// Formats the time a message was sent, with the date if it wasn't today.
+func timestamp(t time.Time) string {
+	if y, m, d := t.Date(); y == time.Now().Year() && m == time.Now().Month() && d == time.Now().Day() {
+		return t.Format("15:04")
+	}
+
+	return t.Format("Jan 2 15:04")
+}
+
+// Handles a line typed by the user. Returns false when the user quits.
+func (a *app) handleLine(line string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+
+	if !strings.HasPrefix(line, "/") {
+		a.sendChat(line)
+		return true
+	}
+
+	fields := strings.Fields(line)
+	command, args := fields[0], fields[1:]
+
+	// The text after the command and the specified number of arguments, keeping its spacing.
+	rest := func(skip int) string {
+		text := strings.TrimSpace(strings.TrimPrefix(line, command))
+		for i := 0; i < skip && i < len(args); i++ {
+			text = strings.TrimSpace(strings.TrimPrefix(text, args[i]))
+		}
+		return text
+	}
+
+	switch command {
+	case "/help":
+		a.printf("%v", helpText)
+
+	case "/quit":
+		return false
+
+	case "/roster", "/contacts":
+		a.printRoster()
+
+	case "/chat", "/w":
+		if len(args) != 1 {
+			a.printf("Usage: /chat <contact>")
+			break
+		}
+		a.openWindow(args[0])
+
+	case "/close":
+		if a.current != nil {
+			a.printf("Closed the conversation with %v.", a.nameOf(a.current.userID))
+			a.current = nil
+		}
+
+	case "/windows":
+		a.printWindows()
+
+	case "/add":
+		if len(args) < 1 {
+			a.printf("Usage: /add <username> [message]")
+			break
+		}
+		a.request(client.AddContactRequest{Username: args[0], Message: rest(1)})
+
+	case "/accept", "/reject":
+		if len(args) != 1 {
+			a.printf("Usage: %v <username>", command)
+			break
+		}
+
+		p := find(a.pending, args[0])
+		if p == nil {
+			a.printf("No contact request from %v.", args[0])
+			break
+		}
+
+		if command == "/accept" {
+			a.request(client.ConfirmContactRequest{UserID: p.id})
+		} else {
+			a.request(client.RejectContactRequest{UserID: p.id})
+		}
+
+	case "/remove":
+		if c := a.contactArg(args, command); c != nil {
+			a.request(client.RemoveContactRequest{UserID: c.id})
+		}
+
+	case "/nudge":
+		if c := a.contactArg(args, command); c != nil {
+			if a.request(client.NudgeRequest{ToUserID: c.id}) {
+				a.addLine(c.id, fmt.Sprintf("%v You nudged %v.", timestamp(time.Now()), a.nameOf(c.id)), 0)
+			}
+		}
+
+	case "/me":
+		if a.current == nil {
+			a.printf("Open a conversation with /chat first.")
+			break
+		}
+
+		action := rest(0)
+		if action == "" {
+			a.printf("Usage: /me <action>")
+			break
+		}
+
+		if a.request(client.ActionRequest{ToUserID: a.current.userID, Action: action}) {
+			a.addLine(a.current.userID, fmt.Sprintf("%v * %v %v", timestamp(time.Now()), a.displayName, action), 0)
+		}
+
+	case "/status":
+		status, ok := statusNames[strings.Join(args, "")]
+		if !ok {
+			a.printf("Usage: /status online|away|busy|invisible")
+			break
+		}
+		if a.request(client.UserStatusChangeRequest{Status: status}) {
+			a.printf("You are now %v.", statusName(status))
+		}
+
+	default:
+		a.printf("Unknown command %v, try /help.", command)
+	}
+
+	return true
+}
+
+// Returns the contact named in the arguments, or the contact of the open window if there are none.
+func (a *app) contactArg(args []string, command string) *contact {
+	if len(args) == 0 && a.current != nil {
+		return a.contacts[a.current.userID]
+	}
+
+	if len(args) != 1 {
+		a.printf("Usage: %v <contact>", command)
+		return nil
+	}
+
+	c := find(a.contacts, args[0])
+	if c == nil {
+		a.printf("%v is not a contact.", args[0])
+	}
+
+	return c
+}
+
+// Opens the window of a contact, showing what was said while it was in the background.
+func (a *app) openWindow(name string) {
+	c := find(a.contacts, name)
+	if c == nil {
+		a.printf("%v is not a contact.", name)
+		return
+	}
+
+	w := a.windows[c.id]
+	if w == nil {
+		w = &window{userID: c.id}
+		a.windows[c.id] = w
+	}
+
+	a.current = w
+
+	a.printf("--- %v (%v) ---", a.nameOf(c.id), statusName(c.status))
+	for _, line := range w.lines {
+		a.printf("%v", line)
+	}
+
+	for _, messageID := range w.unreadMessageIDs {
+		a.send(client.ReadReceiptRequest{FromUserID: c.id, MessageID: messageID})
+	}
+
+	w.unread = 0
+	w.unreadMessageIDs = nil
+}
+
+func (a *app) printWindows() {
+	if len(a.windows) == 0 {
+		a.printf("No conversations.")
+		return
+	}
+
+	for id, w := range a.windows {
+		marker := " "
+		if w == a.current {
+			marker = "*"
+		}
+		a.printf("%v %v (%v unread)", marker, a.nameOf(id), w.unread)
+	}
+}
+
+// Sends a chat message to the contact of the open window.
+func (a *app) sendChat(message string) {
+	if a.current == nil {
+		a.printf("Open a conversation with /chat first.")
+		return
+	}
+
+	if _, err := a.chat(a.current.userID, message); err != nil {
+		a.printf("Failed to send: %v", err)
+		return
+	}
+
+	a.addLine(a.current.userID, fmt.Sprintf("%v <You> %v", timestamp(time.Now()), message), 0)
+}
+
+const helpText = `Commands:
+  /chat <contact>      open the conversation with a contact (also /w)
+  /close               close the open conversation
+  /windows             list conversations and unread messages
+  /roster              list contacts and contact requests
+  /add <user> [msg]    ask a user to become a contact
+  /accept <user>       accept a contact request
+  /reject <user>       reject a contact request
+  /remove [contact]    remove a contact
+  /nudge [contact]     nudge a contact
+  /me <action>         send an action to the open conversation
+  /status <status>     change status to online, away, busy or invisible
+  /quit                disconnect
+Anything else is sent to the open conversation.`
+
+// Returns the handlers that update the app with events from the server.
+func (a *app) handlers() client.Handlers {
+	// Runs an event handler under the app mutex.
+	locked := func(f func()) {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		f()
+	}
+
+	return client.Handlers{
+		OnDisconnect: func(err error) {
+			locked(func() { a.printf("Disconnected (%v), reconnecting...", err) })
+		},
+
+		OnReconnect: a.loggedIn,
+
+		OnDecodeError: func(p *server.Packet, err error) {
+			locked(func() { a.printf("Failed to read packet: %v", err) })
+		},
+
+		OnUserStatusChange: func(e *client.UserStatusChange) {
+			locked(func() {
+				c := a.contacts[e.UserID]
+				if c == nil || c.status == e.Status {
+					return
+				}
+
+				c.status = e.Status
+				a.printf("* %v is now %v.", a.nameOf(c.id), statusName(c.status))
+			})
+		},
+
+		OnChatFrom: func(e *client.ChatFrom) {
+			locked(func() {
+				a.addLine(e.FromUserID, fmt.Sprintf("%v <%v> %v", timestamp(e.SentAt), a.nameOf(e.FromUserID), e.Message), e.MessageID)
+			})
+		},
+
+		OnActionFrom: func(e *client.ActionFrom) {
+			locked(func() {
+				a.addLine(e.FromUserID, fmt.Sprintf("%v * %v %v", timestamp(time.Now()), a.nameOf(e.FromUserID), e.Action), 0)
+			})
+		},
+
+		OnNudgeFrom: func(e *client.NudgeFrom) {
+			locked(func() {
+				a.addLine(e.FromUserID, fmt.Sprintf("%v %v nudged you!\a", timestamp(time.Now()), a.nameOf(e.FromUserID)), 0)
+			})
+		},
+
+		OnImageFrom: func(e *client.ImageFrom) {
+			locked(func() {
+				a.addLine(e.FromUserID, fmt.Sprintf("%v %v sent an image.", timestamp(time.Now()), a.nameOf(e.FromUserID)), 0)
+			})
+		},
+
+		OnTypingStartedFrom: func(e *client.TypingStartedFrom) {
+			locked(func() {
+				if a.current != nil && a.current.userID == e.FromUserID {
+					a.printf("  %v is typing...", a.nameOf(e.FromUserID))
+				}
+			})
+		},
+
+		OnDeliveryFailed: func(e *client.DeliveryFailed) {
+			locked(func() {
+				reason := "an error"
+				switch e.Reason {
+				case builders.DeliveryFailedReasonNotContact:
+					reason = "they are not a contact"
+				case builders.DeliveryFailedReasonBlocked:
+					reason = "they are blocked"
+				}
+				a.printf("* Message to %v was not delivered: %v.", a.nameOf(e.ToUserID), reason)
+			})
+		},
+
+		OnAddContactResponse: func(e *client.AddContactResponse) {
+			locked(func() {
+				switch e.ResultCode {
+				case builders.AddContactResultSuccess:
+					a.printf("* Contact request sent.")
+				case builders.AddContactResultUserNotFound:
+					a.printf("* No user with that username.")
+				case builders.AddContactResultUserAlreadyContact:
+					a.printf("* They are already a contact.")
+				case builders.AddContactResultUserAlreadyPending:
+					a.printf("* A contact request is already waiting for an answer.")
+				case builders.AddContactResultUserBlocked:
+					a.printf("* Contact request not allowed.")
+				default:
+					a.printf("* Failed to send the contact request.")
+				}
+			})
+		},
+
+		OnNotifyAddRequest: func(e *client.NotifyAddRequest) {
+			locked(func() {
+				p := &contact{id: e.UserID, username: deref(e.Username), displayName: deref(e.DisplayName)}
+				a.pending[p.id] = p
+
+				a.printf("* %v (%v) wants to be your contact: %v", p.displayName, p.username, deref(e.Message))
+				a.printf("  /accept %v or /reject %v", p.username, p.username)
+			})
+		},
+
+		OnConfirmContactResponse: func(e *client.ConfirmContactResponse) {
+			locked(func() {
+				delete(a.pending, e.UserID)
+
+				if e.Contact == nil {
+					a.printf("* Failed to accept the contact request.")
+					return
+				}
+
+				a.addContact(e.Contact.ID, e.Contact.Username, deref(e.Contact.DisplayName), e.Contact.Status, deref(e.Contact.StatusText))
+			})
+		},
+
+		OnRejectContactResponse: func(e *client.RejectContactResponse) {
+			locked(func() {
+				if e.ResultCode == builders.RejectContactResultSuccess {
+					a.printf("* Rejected the contact request from %v.", a.nameOf(e.UserID))
+				} else {
+					a.printf("* Failed to reject the contact request.")
+				}
+				delete(a.pending, e.UserID)
+			})
+		},
+
+		OnAddContactAccepted: func(e *client.AddContactAccepted) {
+			locked(func() {
+				c := e.Contact
+				a.addContact(c.ID, c.Username, deref(c.DisplayName), c.Status, deref(c.StatusText))
+			})
+		},
+
+		OnRemoveContactResponse: func(e *client.RemoveContactResponse) {
+			locked(func() {
+				if e.ResultCode == builders.RemoveContactResultSuccess {
+					a.printf("* Removed %v.", a.nameOf(e.UserID))
+					a.removeContact(e.UserID)
+				} else {
+					a.printf("* Failed to remove the contact.")
+				}
+			})
+		},
+
+		OnContactRemoved: func(e *client.ContactRemoved) {
+			locked(func() {
+				a.printf("* %v removed you from their contacts.", a.nameOf(e.UserID))
+				a.removeContact(e.UserID)
+			})
+		},
+
+		OnProfileChanged: func(e *client.ProfileChanged) {
+			locked(func() {
+				if c := a.contacts[e.UserID]; c != nil {
+					c.displayName = deref(e.DisplayName)
+					c.statusText = deref(e.StatusText)
+				}
+			})
+		},
+
+		OnSessionRevoked: func(e *client.SessionRevoked) {
+			locked(func() { a.printf("* Logged out everywhere from another client.") })
+		},
+
+		OnServerShutdown: func(e *client.ServerShutdown) {
+			locked(func() { a.printf("* The server is shutting down.") })
+		},
+	}
+}
+
+func (a *app) addContact(id int, username string, displayName string, status int, statusText string) {
+	a.contacts[id] = &contact{id: id, username: username, displayName: displayName, status: status, statusText: statusText}
+	a.printf("* %v (%v) is now a contact, and is %v.", a.nameOf(id), username, statusName(status))
+}
+
+func (a *app) removeContact(userID int) {
+	if a.current != nil && a.current.userID == userID {
+		a.current = nil
+	}
+
+	delete(a.contacts, userID)
+	delete(a.windows, userID)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"chatServer/client"
+	"chatServer/dbaccess"
+	"chatServer/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Creates an app logged in with bob as a contact and a request from carol, recording the requests it sends.
+func newTestApp() (*app, *bytes.Buffer, *[]client.Request) {
+	out := &bytes.Buffer{}
+	sent := &[]client.Request{}
+
+	a := newApp(out)
+	a.send = func(r client.Request) error {
+		*sent = append(*sent, r)
+		return nil
+	}
+	a.chat = func(toUserID int, message string) (int, error) {
+		*sent = append(*sent, client.ChatRequest{ToUserID: toUserID, Message: message, MessageID: 1})
+		return 1, nil
+	}
+
+	name := "Alice"
+	a.loggedIn(&client.LoginResult{
+		Success:         true,
+		UserID:          1,
+		DisplayName:     &name,
+		Friends:         []models.FriendModel{{ID: 2, Username: "bob", Status: dbaccess.StatusOffline}},
+		PendingContacts: []models.PendingContactModel{{ID: 3, Username: "carol"}},
+	})
+
+	out.Reset()
+
+	return a, out, sent
+}
+
+func TestCommands(t *testing.T) {
+	a, _, sent := newTestApp()
+
+	lines := []string{
+		"/add dave  hello there",
+		"/accept carol",
+		"/nudge bob",
+		"/status busy",
+		"/chat bob",
+		"hi bob",
+		"/me waves",
+	}
+	for _, line := range lines {
+		a.handleLine(line)
+	}
+
+	expected := []client.Request{
+		client.AddContactRequest{Username: "dave", Message: "hello there"},
+		client.ConfirmContactRequest{UserID: 3},
+		client.NudgeRequest{ToUserID: 2},
+		client.UserStatusChangeRequest{Status: dbaccess.StatusBusy},
+		client.ChatRequest{ToUserID: 2, Message: "hi bob", MessageID: 1},
+		client.ActionRequest{ToUserID: 2, Action: "waves"},
+	}
+
+	if !reflect.DeepEqual(*sent, expected) {
+		t.Errorf("Sent %+v, expected %+v.", *sent, expected)
+	}
+
+	if a.handleLine("/quit") {
+		t.Errorf("Expected /quit to quit.")
+	}
+}
+
+func TestChatWindows(t *testing.T) {
+	a, out, sent := newTestApp()
+	handlers := a.handlers()
+
+	handlers.OnUserStatusChange(&client.UserStatusChange{UserID: 2, Status: dbaccess.StatusOnline})
+	if !strings.Contains(out.String(), "bob is now online") {
+		t.Errorf("Expected the status change to be shown, got %q.", out.String())
+	}
+
+	// Messages in a background window are held until it is opened, then read receipts are sent.
+	handlers.OnChatFrom(&client.ChatFrom{FromUserID: 2, Message: "are you there?", SentAt: time.Now(), MessageID: 5})
+
+	if strings.Contains(out.String(), "are you there?") || a.windows[2].unread != 1 {
+		t.Errorf("Expected the message to wait in the background, got %q.", out.String())
+	}
+
+	a.handleLine("/chat bob")
+
+	if !strings.Contains(out.String(), "<bob> are you there?") || a.windows[2].unread != 0 {
+		t.Errorf("Expected the message to be shown when the window opens, got %q.", out.String())
+	}
+
+	expected := []client.Request{client.ReadReceiptRequest{FromUserID: 2, MessageID: 5}}
+	if !reflect.DeepEqual(*sent, expected) {
+		t.Errorf("Sent %+v, expected %+v.", *sent, expected)
+	}
+
+	// Contacts that are removed lose their window.
+	handlers.OnContactRemoved(&client.ContactRemoved{UserID: 2})
+
+	if a.current != nil || a.contacts[2] != nil {
+		t.Errorf("Expected bob and his window to be removed.")
+	}
+
+	if a.handleLine("hello?"); !strings.Contains(out.String(), "Open a conversation") {
+		t.Errorf("Expected chat without a window to be refused, got %q.", out.String())
+	}
+}
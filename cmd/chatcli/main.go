@@ -0,0 +1,95 @@
+// Command chatcli is a terminal chat client for testing and operating the chat server.
+//
+// It logs in, shows the roster with live status changes and keeps a conversation window for each contact. Type /help
+// once connected for the commands.
+package main
+
+import (
+	"bufio"
+	"chatServer/client"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	address := flag.String("addr", "localhost:5035", "chat server address")
+	username := flag.String("user", "", "username to log in with")
+	password := flag.String("password", "", "password to log in with, or set CHATCLI_PASSWORD; asked for if neither is set")
+	useTLS := flag.Bool("tls", false, "connect with TLS")
+	insecure := flag.Bool("insecure", false, "don't verify the server certificate when connecting with TLS")
+	flag.Parse()
+
+	input := bufio.NewScanner(os.Stdin)
+
+	if *username == "" {
+		*username = prompt(input, "Username: ")
+	}
+
+	if *password == "" {
+		*password = os.Getenv("CHATCLI_PASSWORD")
+	}
+
+	if *password == "" {
+		*password = prompt(input, "Password: ")
+	}
+
+	config := client.Config{
+		Address:   *address,
+		Username:  *username,
+		Password:  *password,
+		Reconnect: true,
+	}
+
+	if *useTLS {
+		config.TLSConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	}
+
+	a := newApp(os.Stdout)
+
+	c, result, err := client.Dial(config, a.handlers())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to log in: %v\n", err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	a.setClient(c)
+	a.loggedIn(result)
+
+	fmt.Println("Type /help for commands.")
+
+	lines := make(chan string)
+	go func() {
+		for input.Scan() {
+			lines <- input.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok || !a.handleLine(line) {
+				return
+			}
+
+		case <-c.Done():
+			fmt.Fprintf(os.Stderr, "Disconnected: %v\n", c.Err())
+			os.Exit(1)
+		}
+	}
+}
+
+// Asks for a line of input.
+func prompt(input *bufio.Scanner, text string) string {
+	fmt.Print(text)
+
+	if !input.Scan() {
+		os.Exit(1)
+	}
+
+	return strings.TrimSpace(input.Text())
+}
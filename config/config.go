@@ -0,0 +1,263 @@
+// Package config loads the server settings. Each setting has a default that can be overridden, in order of
+// precedence, by a JSON config file, CHATSERVER_* environment variables and command-line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The prefix of environment variables that override settings. A setting such as chat-address is read from
+// CHATSERVER_CHAT_ADDRESS.
+const envPrefix = "CHATSERVER_"
+
+// Config holds all of the server settings.
+type Config struct {
+	ChatAddress     string
+	HTTPAddress     string
+	HTTPSAddress    string
+	LocalTesting    bool
+	RequireTLS      bool
+	CertFile        string
+	KeyFile         string
+	Database        string
+	DatabaseDSN     string
+	LogFile         string
+	AvatarDir       string
+	AvatarURL       string
+	MaxAvatarSize   int
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	MaxPacketLength int64
+	AwayAfter       time.Duration
+	SendQueueLength int
+	SlowConsumer    string
+	PingInterval    time.Duration
+	PingTimeout     time.Duration
+}
+
+// Load reads the settings from the config file, environment and the specified command-line arguments, and
+// validates them.
+func Load(args []string) (*Config, error) {
+	// The config file path is a setting itself, so find it before applying the file.
+	cfg := &Config{}
+	configPath := ""
+	fs := newFlagSet(cfg, &configPath)
+
+	err := applyEnv(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Any problem with the arguments has already been reported by the first parse.
+	cfg = &Config{}
+	fs = newFlagSet(cfg, new(string))
+	fs.SetOutput(io.Discard)
+
+	if configPath != "" {
+		err = applyFile(fs, configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = applyEnv(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func newFlagSet(cfg *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("chatServer", flag.ContinueOnError)
+
+	fs.StringVar(configPath, "config", "", "path of a JSON config file")
+	fs.StringVar(&cfg.ChatAddress, "chat-address", ":5035", "address the chat server listens on")
+	fs.StringVar(&cfg.HTTPAddress, "http-address", ":80", "address the HTTP redirect server listens on")
+	fs.StringVar(&cfg.HTTPSAddress, "https-address", ":443", "address the HTTPS web server listens on")
+	fs.BoolVar(&cfg.LocalTesting, "local-testing", true, "run without the web server or TLS")
+	fs.BoolVar(&cfg.RequireTLS, "require-tls", false, "disconnect chat clients that don't use TLS")
+	fs.StringVar(&cfg.CertFile, "cert-file", "domain.crt", "TLS certificate file")
+	fs.StringVar(&cfg.KeyFile, "key-file", "domain.key", "TLS private key file")
+	fs.StringVar(&cfg.Database, "database", "mysql", "data store to use: mysql, sqlite or memory")
+	fs.StringVar(&cfg.DatabaseDSN, "database-dsn", "", "MySQL data source name, or SQLite database file")
+	fs.StringVar(&cfg.LogFile, "log-file", "log.txt", "file the log is written to as well as stdout")
+	fs.StringVar(&cfg.AvatarDir, "avatar-dir", "avatars", "directory uploaded avatar images are saved in")
+	fs.StringVar(&cfg.AvatarURL, "avatar-url", "/avatars/", "URL avatar images are served from by the web server")
+	fs.IntVar(&cfg.MaxAvatarSize, "max-avatar-size", 1000000, "largest avatar image in bytes a user can upload")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", 60*time.Second, "time allowed for a socket read to complete")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", 30*time.Second, "time allowed for a socket write to complete")
+	fs.Int64Var(&cfg.MaxPacketLength, "max-packet-length", 20000000, "largest packet in bytes a client can send")
+	fs.IntVar(&cfg.SendQueueLength, "send-queue-length", 256, "packets that can wait to be sent to a client")
+	fs.StringVar(&cfg.SlowConsumer, "slow-consumer", "disconnect", "what to do when a client's send queue is full: disconnect or drop")
+	fs.DurationVar(&cfg.PingInterval, "ping-interval", 20*time.Second, "time between pings sent to each client")
+	fs.DurationVar(&cfg.PingTimeout, "ping-timeout", 15*time.Second, "time a client has to reply to a ping before it is disconnected")
+	fs.DurationVar(&cfg.AwayAfter, "away-after", 10*time.Minute, "inactivity before an online user is shown as away")
+
+	return fs
+}
+
+// Sets any flags that have a value in the JSON config file. Keys are the flag names.
+func applyFile(fs *flag.FlagSet, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	values := map[string]interface{}{}
+
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+
+	err = decoder.Decode(&values)
+	if err != nil {
+		return fmt.Errorf("invalid config file %v: %v", path, err)
+	}
+
+	for name, value := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown setting '%v' in config file %v", name, path)
+		}
+
+		err := fs.Set(name, fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("invalid value for '%v' in config file %v: %v", name, path, err)
+		}
+	}
+
+	return nil
+}
+
+// Sets any flags that have a value in the environment.
+func applyEnv(fs *flag.FlagSet) error {
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+
+		value, ok := os.LookupEnv(name)
+		if !ok || err != nil {
+			return
+		}
+
+		if e := fs.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("invalid value for %v: %v", name, e)
+		}
+	})
+
+	return err
+}
+
+func (c *Config) validate() error {
+	if _, _, err := net.SplitHostPort(c.ChatAddress); err != nil {
+		return fmt.Errorf("invalid chat-address: %v", err)
+	}
+
+	if c.Database != "mysql" && c.Database != "sqlite" && c.Database != "memory" {
+		return errors.New("database must be mysql, sqlite or memory")
+	}
+
+	if c.DatabaseDSN == "" && c.Database != "memory" {
+		return errors.New("database-dsn is required")
+	}
+
+	if c.LogFile == "" {
+		return errors.New("log-file is required")
+	}
+
+	if c.AvatarDir == "" {
+		return errors.New("avatar-dir is required")
+	}
+
+	if avatarURL, err := url.Parse(c.AvatarURL); err != nil || !strings.HasSuffix(avatarURL.Path, "/") {
+		return errors.New("avatar-url must be a URL ending with /")
+	}
+
+	if c.ReadTimeout <= 0 {
+		return errors.New("read-timeout must be greater than 0")
+	}
+
+	if c.WriteTimeout <= 0 {
+		return errors.New("write-timeout must be greater than 0")
+	}
+
+	if c.MaxPacketLength <= 0 || c.MaxPacketLength > math.MaxInt32 {
+		return fmt.Errorf("max-packet-length must be between 1 and %v", math.MaxInt32)
+	}
+
+	if c.PingInterval <= 0 || c.PingInterval >= c.ReadTimeout {
+		return errors.New("ping-interval must be greater than 0 and less than read-timeout")
+	}
+
+	if c.PingTimeout <= 0 {
+		return errors.New("ping-timeout must be greater than 0")
+	}
+
+	if c.SendQueueLength <= 0 {
+		return errors.New("send-queue-length must be greater than 0")
+	}
+
+	if c.SlowConsumer != "disconnect" && c.SlowConsumer != "drop" {
+		return errors.New("slow-consumer must be disconnect or drop")
+	}
+
+	if c.AwayAfter <= 0 {
+		return errors.New("away-after must be greater than 0")
+	}
+
+	if c.MaxAvatarSize <= 0 || int64(c.MaxAvatarSize) > c.MaxPacketLength {
+		return errors.New("max-avatar-size must be greater than 0 and no more than max-packet-length")
+	}
+
+	if c.LocalTesting {
+		if c.RequireTLS {
+			return errors.New("require-tls can't be used with local-testing")
+		}
+
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTPAddress); err != nil {
+		return fmt.Errorf("invalid http-address: %v", err)
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTPSAddress); err != nil {
+		return fmt.Errorf("invalid https-address: %v", err)
+	}
+
+	if _, err := os.Stat(c.CertFile); err != nil {
+		return fmt.Errorf("invalid cert-file: %v", err)
+	}
+
+	if _, err := os.Stat(c.KeyFile); err != nil {
+		return fmt.Errorf("invalid key-file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	err := os.WriteFile(path, []byte(`{
+		"database-dsn": "file-dsn",
+		"chat-address": ":6000",
+		"read-timeout": "90s",
+		"max-packet-length": 2000000
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CHATSERVER_CHAT_ADDRESS", ":7000")
+	t.Setenv("CHATSERVER_WRITE_TIMEOUT", "5s")
+
+	cfg, err := Load([]string{"-config", path, "-write-timeout", "10s"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.DatabaseDSN != "file-dsn" {
+		t.Errorf("Expected %v, got %v.", "file-dsn", cfg.DatabaseDSN)
+	}
+
+	if cfg.ChatAddress != ":7000" {
+		t.Errorf("Expected %v, got %v.", ":7000", cfg.ChatAddress)
+	}
+
+	if cfg.ReadTimeout != 90*time.Second {
+		t.Errorf("Expected %v, got %v.", 90*time.Second, cfg.ReadTimeout)
+	}
+
+	if cfg.WriteTimeout != 10*time.Second {
+		t.Errorf("Expected %v, got %v.", 10*time.Second, cfg.WriteTimeout)
+	}
+
+	if cfg.MaxPacketLength != 2000000 {
+		t.Errorf("Expected %v, got %v.", 2000000, cfg.MaxPacketLength)
+	}
+
+	if cfg.LogFile != "log.txt" {
+		t.Errorf("Expected %v, got %v.", "log.txt", cfg.LogFile)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	invalid := [][]string{
+		{},
+		{"-database", "sqlite"},
+		{"-database", "postgres", "-database-dsn", "dsn"},
+		{"-database-dsn", "dsn", "-chat-address", "5035"},
+		{"-database-dsn", "dsn", "-read-timeout", "0s"},
+		{"-database-dsn", "dsn", "-away-after", "-1m"},
+		{"-database-dsn", "dsn", "-ping-interval", "60s"},
+		{"-database-dsn", "dsn", "-ping-timeout", "0s"},
+		{"-database-dsn", "dsn", "-send-queue-length", "0"},
+		{"-database-dsn", "dsn", "-slow-consumer", "wait"},
+		{"-database-dsn", "dsn", "-max-packet-length", "0"},
+		{"-database-dsn", "dsn", "-max-avatar-size", "30000000"},
+		{"-database-dsn", "dsn", "-avatar-url", "/avatars"},
+		{"-database-dsn", "dsn", "-require-tls"},
+		{"-database-dsn", "dsn", "-local-testing=false", "-cert-file", "missing.crt"},
+		{"-database-dsn", "dsn", "-unknown"},
+	}
+
+	for _, args := range invalid {
+		if _, err := Load(args); err == nil {
+			t.Errorf("Expected an error for %v.", args)
+		}
+	}
+
+	if _, err := Load([]string{"-database-dsn", "dsn"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
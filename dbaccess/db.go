@@ -5,44 +5,59 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"time"
 
 	// We use the mysql driver.
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// Status ID of a user.
+// Status ID of a user. Invisible is never shown to contacts, who see the user as offline instead.
 const (
-	StatusOffline = 0
-	StatusOnline  = 1
+	StatusOffline   = 0
+	StatusOnline    = 1
+	StatusAway      = 2
+	StatusBusy      = 3
+	StatusInvisible = 4
 )
 
-var database *sql.DB
+// Types of message stored in a conversation history.
+const (
+	MessageTypeChat   = 0
+	MessageTypeAction = 1
+	MessageTypeNudge  = 2
+	MessageTypeImage  = 3
+)
 
-// OpenConnection opens a connection to the database.
-func OpenConnection() {
-	db, err := sql.Open("mysql", "")
+// MySQLStore is a Store backed by a MySQL database. All queries are stored procedures from the dbscript directory.
+type MySQLStore struct {
+	database *sql.DB
+}
+
+// NewMySQLStore opens a connection to the MySQL database with the specified data source name.
+func NewMySQLStore(dsn string) (*MySQLStore, error) {
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
-	database = db
+	return &MySQLStore{database: db}, nil
 }
 
-// CloseConnection closes the database connection.
-func CloseConnection() {
-	database.Close()
+// Close closes the database connection.
+func (m *MySQLStore) Close() error {
+	return m.database.Close()
 }
 
 // GetUserByUsername returns a user model from the data store by username.
-func GetUserByUsername(username string) (*models.UserModel, error) {
-	rows, err := database.Query("call getUserByUsername(?)", username)
+func (m *MySQLStore) GetUserByUsername(username string) (*models.UserModel, error) {
+	rows, err := m.database.Query("call getUserByUsername(?)", username)
 	if err != nil {
 		return nil, err
 	}
 
 	if rows.Next() {
 		user := models.UserModel{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.DisplayName, &user.Status, &user.StatusText)
+		err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.DisplayName, &user.Status, &user.StatusText, &user.ImageURL)
 		if err != nil {
 			return nil, err
 		}
@@ -54,8 +69,8 @@ func GetUserByUsername(username string) (*models.UserModel, error) {
 }
 
 // GetUserByID returns a user model from the data store by id.
-func GetUserByID(userID int) (*models.UserModel, error) {
-	rows, err := database.Query("call getUserById(?)", userID)
+func (m *MySQLStore) GetUserByID(userID int) (*models.UserModel, error) {
+	rows, err := m.database.Query("call getUserById(?)", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +89,8 @@ func GetUserByID(userID int) (*models.UserModel, error) {
 }
 
 // LoginUser logs a user in and sets their status to online.
-func LoginUser(userID int) error {
-	_, err := database.Exec("call loginUser(?)", userID)
+func (m *MySQLStore) LoginUser(userID int) error {
+	_, err := m.database.Exec("call loginUser(?)", userID)
 	if err != nil {
 		return err
 	}
@@ -83,28 +98,16 @@ func LoginUser(userID int) error {
 	return nil
 }
 
-// LogoutUser logs a user out and sets their status to offline.
-func LogoutUser(userID int) error {
-	res, err := database.Exec("call logoffUser(?)", userID)
-	if err != nil {
-		return err
-	}
-
-	ra, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if ra != 1 {
-		return errors.New("failed to update")
-	}
-
-	return nil
+// LogoutUser logs a user out and sets their status to offline. Logging out a user that already appears offline, such
+// as an invisible user, succeeds. MySQL only counts changed rows, so the rows affected aren't checked.
+func (m *MySQLStore) LogoutUser(userID int) error {
+	_, err := m.database.Exec("call logoffUser(?)", userID)
+	return err
 }
 
 // GetFriends returns a list of friends that the specified user has.
-func GetFriends(userID int) ([]models.FriendModel, error) {
-	rows, err := database.Query("call getFriends(?)", userID)
+func (m *MySQLStore) GetFriends(userID int) ([]models.FriendModel, error) {
+	rows, err := m.database.Query("call getFriends(?)", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +130,8 @@ func GetFriends(userID int) ([]models.FriendModel, error) {
 }
 
 // ResetUserStatuses sets all users to be offline.
-func ResetUserStatuses() error {
-	_, err := database.Exec("call resetUserStatuses()")
+func (m *MySQLStore) ResetUserStatuses() error {
+	_, err := m.database.Exec("call resetUserStatuses()")
 	if err != nil {
 		return err
 	}
@@ -137,8 +140,8 @@ func ResetUserStatuses() error {
 }
 
 // CreateAccount creates a new account.
-func CreateAccount(username string, password string, email string, displayName string, validationGUID string) error {
-	res, err := database.Exec(
+func (m *MySQLStore) CreateAccount(username string, password string, email string, displayName string, validationGUID string) error {
+	res, err := m.database.Exec(
 		"call createAccount(?,?,?,?,?)",
 		username,
 		email,
@@ -163,8 +166,8 @@ func CreateAccount(username string, password string, email string, displayName s
 }
 
 // AddPendingContact adds a contact request for the requested user.
-func AddPendingContact(userID int, userAddingID int, message *string) error {
-	res, err := database.Exec(
+func (m *MySQLStore) AddPendingContact(userID int, userAddingID int, message *string) error {
+	res, err := m.database.Exec(
 		"call addPendingContact(?,?,?)",
 		userID,
 		userAddingID,
@@ -187,8 +190,8 @@ func AddPendingContact(userID int, userAddingID int, message *string) error {
 }
 
 // GetPendingContact retreives a pending contact by user and requested user.
-func GetPendingContact(requestedUserID int, addingUserID int) (*int, error) {
-	row := database.QueryRow("call getPendingContact(?,?)", requestedUserID, addingUserID)
+func (m *MySQLStore) GetPendingContact(requestedUserID int, addingUserID int) (*int, error) {
+	row := m.database.QueryRow("call getPendingContact(?,?)", requestedUserID, addingUserID)
 
 	var rowID int
 
@@ -206,8 +209,8 @@ func GetPendingContact(requestedUserID int, addingUserID int) (*int, error) {
 }
 
 // GetUserContactByContactUserID retreives a users contact.
-func GetUserContactByContactUserID(userID int, contactUserID int) (*int, error) {
-	row := database.QueryRow("call getUserContactByContactUserID(?,?)", userID, contactUserID)
+func (m *MySQLStore) GetUserContactByContactUserID(userID int, contactUserID int) (*int, error) {
+	row := m.database.QueryRow("call getUserContactByContactUserID(?,?)", userID, contactUserID)
 
 	var rowID int
 
@@ -225,8 +228,8 @@ func GetUserContactByContactUserID(userID int, contactUserID int) (*int, error)
 }
 
 // GetUserPendingContacts returns a list of a users pending contact requests.
-func GetUserPendingContacts(userID int) ([]models.PendingContactModel, error) {
-	rows, err := database.Query("call getUserPendingContacts(?)", userID)
+func (m *MySQLStore) GetUserPendingContacts(userID int) ([]models.PendingContactModel, error) {
+	rows, err := m.database.Query("call getUserPendingContacts(?)", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -249,8 +252,8 @@ func GetUserPendingContacts(userID int) ([]models.PendingContactModel, error) {
 }
 
 // ConfirmContactRequest confirms a contact request.
-func ConfirmContactRequest(requestedUserID int, addingUserID int) error {
-	res, err := database.Exec(
+func (m *MySQLStore) ConfirmContactRequest(requestedUserID int, addingUserID int) error {
+	res, err := m.database.Exec(
 		"call confirmContact(?,?)",
 		requestedUserID,
 		addingUserID)
@@ -272,8 +275,8 @@ func ConfirmContactRequest(requestedUserID int, addingUserID int) error {
 }
 
 // RejectContactRequest rejects a contact request.
-func RejectContactRequest(requestedUserID int, addingUserID int) error {
-	res, err := database.Exec(
+func (m *MySQLStore) RejectContactRequest(requestedUserID int, addingUserID int) error {
+	res, err := m.database.Exec(
 		"call rejectContact(?,?)",
 		requestedUserID,
 		addingUserID)
@@ -294,13 +297,352 @@ func RejectContactRequest(requestedUserID int, addingUserID int) error {
 	return nil
 }
 
-// SetStatus sets a users status.
-func SetStatus(userID int, statusID int) error {
-	res, err := database.Exec(
+// SetStatus sets a users status. Setting the status the user already has succeeds. MySQL only counts changed rows,
+// so the rows affected aren't checked.
+func (m *MySQLStore) SetStatus(userID int, statusID int) error {
+	_, err := m.database.Exec(
 		"call setStatus(?,?)",
 		userID,
 		statusID)
 
+	return err
+}
+
+// AddOfflineMessage queues a chat message for a user that is not currently online. The message id is the one
+// assigned by the senders client.
+func (m *MySQLStore) AddOfflineMessage(toUserID int, fromUserID int, message string, sentAt time.Time, messageID int) error {
+	res, err := m.database.Exec(
+		"call addOfflineMessage(?,?,?,?,?)",
+		toUserID,
+		fromUserID,
+		message,
+		sentAt.Unix(),
+		messageID)
+
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra != 1 {
+		return errors.New("failed to add offline message")
+	}
+
+	return nil
+}
+
+// GetOfflineMessages returns the queued chat messages for a user, oldest first.
+func (m *MySQLStore) GetOfflineMessages(userID int) ([]models.OfflineMessageModel, error) {
+	rows, err := m.database.Query("call getOfflineMessages(?)", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.OfflineMessageModel{}
+
+	for rows.Next() {
+		m := models.OfflineMessageModel{}
+		var sentAt int64
+
+		err := rows.Scan(&m.ID, &m.FromUserID, &m.Message, &sentAt, &m.MessageID)
+		if err != nil {
+			log.Printf("Failed to map offline message model.")
+			continue
+		}
+
+		m.SentAt = time.Unix(sentAt, 0)
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// DeleteOfflineMessages removes a users queued chat messages up to and including the specified message id.
+func (m *MySQLStore) DeleteOfflineMessages(userID int, maxMessageID int) error {
+	_, err := m.database.Exec("call deleteOfflineMessages(?,?)", userID, maxMessageID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddMessage records a message sent between two users in their conversation history.
+func (m *MySQLStore) AddMessage(fromUserID int, toUserID int, messageType int, content *string, sentAt time.Time) error {
+	res, err := m.database.Exec(
+		"call addMessage(?,?,?,?,?)",
+		fromUserID,
+		toUserID,
+		messageType,
+		content,
+		sentAt.Unix())
+
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra != 1 {
+		return errors.New("failed to add message")
+	}
+
+	return nil
+}
+
+// GetMessages returns up to count messages between a user and their contact, newest first. Only messages older than
+// the before message id are returned, or the most recent messages if it is 0.
+func (m *MySQLStore) GetMessages(userID int, contactUserID int, beforeMessageID int, count int) ([]models.MessageModel, error) {
+	rows, err := m.database.Query("call getMessages(?,?,?,?)", userID, contactUserID, beforeMessageID, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.MessageModel{}
+
+	for rows.Next() {
+		m := models.MessageModel{}
+		var sentAt int64
+
+		err := rows.Scan(&m.ID, &m.FromUserID, &m.MessageType, &m.Content, &sentAt)
+		if err != nil {
+			log.Printf("Failed to map message model.")
+			continue
+		}
+
+		m.SentAt = time.Unix(sentAt, 0)
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// CreateRoom creates a new group conversation with the specified user as its first member and returns its id.
+func (m *MySQLStore) CreateRoom(userID int, name string) (int, error) {
+	row := m.database.QueryRow("call createRoom(?,?)", userID, name)
+
+	var roomID int
+
+	err := row.Scan(&roomID)
+	if err != nil {
+		return 0, err
+	}
+
+	return roomID, nil
+}
+
+// GetRoomByID returns a group conversation by id.
+func (m *MySQLStore) GetRoomByID(roomID int) (*models.RoomModel, error) {
+	row := m.database.QueryRow("call getRoomById(?)", roomID)
+
+	room := models.RoomModel{}
+
+	err := row.Scan(&room.ID, &room.Name)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// GetUserRooms returns the group conversations a user is a member of.
+func (m *MySQLStore) GetUserRooms(userID int) ([]models.RoomModel, error) {
+	rows, err := m.database.Query("call getUserRooms(?)", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []models.RoomModel{}
+
+	for rows.Next() {
+		r := models.RoomModel{}
+
+		err := rows.Scan(&r.ID, &r.Name)
+		if err != nil {
+			log.Printf("Failed to map room model.")
+			continue
+		}
+
+		rooms = append(rooms, r)
+	}
+
+	return rooms, nil
+}
+
+// GetRoomMembers returns the members of a group conversation.
+func (m *MySQLStore) GetRoomMembers(roomID int) ([]models.RoomMemberModel, error) {
+	rows, err := m.database.Query("call getRoomMembers(?)", roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.RoomMemberModel{}
+
+	for rows.Next() {
+		m := models.RoomMemberModel{}
+
+		err := rows.Scan(&m.ID, &m.Username, &m.DisplayName, &m.Status)
+		if err != nil {
+			log.Printf("Failed to map room member model.")
+			continue
+		}
+
+		members = append(members, m)
+	}
+
+	return members, nil
+}
+
+// GetRoomMember retreives a users membership of a group conversation.
+func (m *MySQLStore) GetRoomMember(roomID int, userID int) (*int, error) {
+	row := m.database.QueryRow("call getRoomMember(?,?)", roomID, userID)
+
+	var rowID int
+
+	err := row.Scan(&rowID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rowID, nil
+}
+
+// AddRoomMember adds a user to a group conversation.
+func (m *MySQLStore) AddRoomMember(roomID int, userID int) error {
+	res, err := m.database.Exec("call addRoomMember(?,?)", roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra != 1 {
+		return errors.New("failed to add room member")
+	}
+
+	return nil
+}
+
+// RemoveRoomMember removes a user from a group conversation. The room is deleted when its last member is removed.
+func (m *MySQLStore) RemoveRoomMember(roomID int, userID int) error {
+	_, err := m.database.Exec("call removeRoomMember(?,?)", roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RenameRoom sets the name of a group conversation.
+func (m *MySQLStore) RenameRoom(roomID int, name string) error {
+	_, err := m.database.Exec("call renameRoom(?,?)", roomID, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateSession stores a login session for a user that can be resumed with its token until it expires. Only the
+// hash of the token is stored.
+func (m *MySQLStore) CreateSession(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := m.database.Exec("call createSession(?,?,?)", userID, tokenHash, expiresAt.Unix())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSessionUserID returns the id of the user that owns an unexpired session token hash.
+func (m *MySQLStore) GetSessionUserID(tokenHash string) (*int, error) {
+	row := m.database.QueryRow("call getSessionUserId(?)", tokenHash)
+
+	var userID int
+
+	err := row.Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &userID, nil
+}
+
+// DeleteUserSessions revokes all login sessions of a user.
+func (m *MySQLStore) DeleteUserSessions(userID int) error {
+	_, err := m.database.Exec("call deleteUserSessions(?)", userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveContact removes two users from each others contacts.
+func (m *MySQLStore) RemoveContact(userID int, removeUserID int) error {
+	_, err := m.database.Exec(
+		"call removeContact(?,?)",
+		userID,
+		removeUserID)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BlockUser adds a user to another users block list.
+func (m *MySQLStore) BlockUser(userID int, blockedUserID int) error {
+	res, err := m.database.Exec("call blockUser(?,?)", userID, blockedUserID)
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra != 1 {
+		return errors.New("failed to block user")
+	}
+
+	return nil
+}
+
+// UnblockUser removes a user from another users block list.
+func (m *MySQLStore) UnblockUser(userID int, blockedUserID int) error {
+	res, err := m.database.Exec("call unblockUser(?,?)", userID, blockedUserID)
 	if err != nil {
 		return err
 	}
@@ -311,7 +653,117 @@ func SetStatus(userID int, statusID int) error {
 	}
 
 	if ra != 1 {
-		return errors.New("failed to set user status")
+		return errors.New("failed to unblock user")
+	}
+
+	return nil
+}
+
+// GetBlock retreives a users block of another user.
+func (m *MySQLStore) GetBlock(userID int, blockedUserID int) (*int, error) {
+	row := m.database.QueryRow("call getBlock(?,?)", userID, blockedUserID)
+
+	var rowID int
+
+	err := row.Scan(&rowID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rowID, nil
+}
+
+// GetBlockedUsers returns the users on a users block list.
+func (m *MySQLStore) GetBlockedUsers(userID int) ([]models.BlockedUserModel, error) {
+	rows, err := m.database.Query("call getBlockedUsers(?)", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.BlockedUserModel{}
+
+	for rows.Next() {
+		u := models.BlockedUserModel{}
+
+		err := rows.Scan(&u.ID, &u.Username, &u.DisplayName)
+		if err != nil {
+			log.Printf("Failed to map blocked user model.")
+			continue
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// GetBlockRelations returns the ids of users that the specified user has blocked or been blocked by.
+func (m *MySQLStore) GetBlockRelations(userID int) ([]int, error) {
+	rows, err := m.database.Query("call getBlockRelations(?)", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// SetDisplayName sets a users display name.
+func (m *MySQLStore) SetDisplayName(userID int, displayName string) error {
+	_, err := m.database.Exec(
+		"call setDisplayName(?,?)",
+		userID,
+		displayName)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetStatusText sets a users status text. A nil status text clears it.
+func (m *MySQLStore) SetStatusText(userID int, statusText *string) error {
+	_, err := m.database.Exec(
+		"call setStatusText(?,?)",
+		userID,
+		statusText)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetUserImage sets the URL of a users avatar image.
+func (m *MySQLStore) SetUserImage(userID int, imageURL string) error {
+	_, err := m.database.Exec(
+		"call setUserImage(?,?)",
+		userID,
+		imageURL)
+
+	if err != nil {
+		return err
 	}
 
 	return nil
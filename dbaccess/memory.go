@@ -0,0 +1,842 @@
+package dbaccess
+
+import (
+	"chatServer/models"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in memory. Nothing is persisted, so it is meant for tests and local
+// development.
+type MemoryStore struct {
+	mutex           *sync.Mutex
+	nextID          int
+	users           []*memoryUser
+	friends         []*memoryRelation
+	pendingContacts []*memoryPendingContact
+	offlineMessages []*memoryOfflineMessage
+	messages        []*memoryMessage
+	rooms           []*models.RoomModel
+	roomMembers     []*memoryRoomMember
+	sessions        []*memorySession
+	blocks          []*memoryRelation
+}
+
+type memoryUser struct {
+	models.UserModel
+	email          string
+	validationGUID string
+}
+
+// A row relating a user to another, such as a friend or a block.
+type memoryRelation struct {
+	id          int
+	userID      int
+	otherUserID int
+}
+
+type memoryPendingContact struct {
+	id              int
+	requestedUserID int
+	addingUserID    int
+	message         *string
+}
+
+type memoryOfflineMessage struct {
+	models.OfflineMessageModel
+	toUserID int
+}
+
+type memoryMessage struct {
+	models.MessageModel
+	toUserID int
+}
+
+type memoryRoomMember struct {
+	id     int
+	roomID int
+	userID int
+}
+
+type memorySession struct {
+	userID    int
+	tokenHash string
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates a new empty MemoryStore instance.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mutex: &sync.Mutex{},
+	}
+}
+
+// Close does nothing, the data is kept until the store is garbage collected.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// Returns the next id for a new row. Ids are unique across all rows, which keeps them increasing in insert order.
+func (m *MemoryStore) newID() int {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *MemoryStore) findUser(userID int) *memoryUser {
+	for _, u := range m.users {
+		if u.ID == userID {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) findRelation(relations []*memoryRelation, userID int, otherUserID int) *memoryRelation {
+	for _, r := range relations {
+		if r.userID == userID && r.otherUserID == otherUserID {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// GetUserByUsername returns a user model from the data store by username. Usernames are case insensitive.
+func (m *MemoryStore) GetUserByUsername(username string) (*models.UserModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, u := range m.users {
+		if strings.EqualFold(u.Username, username) {
+			user := u.UserModel
+			user.DisplayName = copyString(u.DisplayName)
+			user.StatusText = copyString(u.StatusText)
+			user.ImageURL = copyString(u.ImageURL)
+
+			return &user, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetUserByID returns a user model from the data store by id.
+func (m *MemoryStore) GetUserByID(userID int) (*models.UserModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	u := m.findUser(userID)
+	if u == nil {
+		return nil, nil
+	}
+
+	return &models.UserModel{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: copyString(u.DisplayName),
+		Status:      u.Status,
+		StatusText:  copyString(u.StatusText),
+		ImageURL:    copyString(u.ImageURL),
+	}, nil
+}
+
+// LoginUser logs a user in and sets their status to online.
+func (m *MemoryStore) LoginUser(userID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if u := m.findUser(userID); u != nil {
+		u.Status = StatusOnline
+	}
+
+	return nil
+}
+
+// LogoutUser logs a user out and sets their status to offline. Logging out a user that already appears offline, such
+// as an invisible user, succeeds.
+func (m *MemoryStore) LogoutUser(userID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	u := m.findUser(userID)
+	if u == nil {
+		return errors.New("failed to update")
+	}
+
+	u.Status = StatusOffline
+
+	return nil
+}
+
+// GetFriends returns a list of friends that the specified user has.
+func (m *MemoryStore) GetFriends(userID int) ([]models.FriendModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	friends := []models.FriendModel{}
+
+	for _, r := range m.friends {
+		if r.userID != userID {
+			continue
+		}
+
+		if u := m.findUser(r.otherUserID); u != nil {
+			friends = append(friends, models.FriendModel{
+				ID:          u.ID,
+				Username:    u.Username,
+				DisplayName: copyString(u.DisplayName),
+				Status:      u.Status,
+				ImageURL:    copyString(u.ImageURL),
+				StatusText:  copyString(u.StatusText),
+			})
+		}
+	}
+
+	return friends, nil
+}
+
+// ResetUserStatuses sets all users to be offline.
+func (m *MemoryStore) ResetUserStatuses() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, u := range m.users {
+		u.Status = StatusOffline
+	}
+
+	return nil
+}
+
+// CreateAccount creates a new account.
+func (m *MemoryStore) CreateAccount(username string, password string, email string, displayName string, validationGUID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, u := range m.users {
+		if strings.EqualFold(u.Username, username) {
+			return errors.New("failed to create")
+		}
+	}
+
+	m.users = append(m.users, &memoryUser{
+		UserModel: models.UserModel{
+			ID:          m.newID(),
+			Username:    username,
+			Password:    password,
+			DisplayName: copyString(&displayName),
+		},
+		email:          email,
+		validationGUID: validationGUID,
+	})
+
+	return nil
+}
+
+// AddPendingContact adds a contact request for the requested user.
+func (m *MemoryStore) AddPendingContact(userID int, userAddingID int, message *string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pendingContacts = append(m.pendingContacts, &memoryPendingContact{
+		id:              m.newID(),
+		requestedUserID: userID,
+		addingUserID:    userAddingID,
+		message:         copyString(message),
+	})
+
+	return nil
+}
+
+// GetPendingContact retreives a pending contact by user and requested user.
+func (m *MemoryStore) GetPendingContact(requestedUserID int, addingUserID int) (*int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, pc := range m.pendingContacts {
+		if pc.requestedUserID == requestedUserID && pc.addingUserID == addingUserID {
+			rowID := pc.id
+			return &rowID, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetUserContactByContactUserID retreives a users contact.
+func (m *MemoryStore) GetUserContactByContactUserID(userID int, contactUserID int) (*int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if r := m.findRelation(m.friends, userID, contactUserID); r != nil {
+		rowID := r.id
+		return &rowID, nil
+	}
+
+	return nil, nil
+}
+
+// GetUserPendingContacts returns a list of a users pending contact requests.
+func (m *MemoryStore) GetUserPendingContacts(userID int) ([]models.PendingContactModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	contacts := []models.PendingContactModel{}
+
+	for _, pc := range m.pendingContacts {
+		if pc.addingUserID != userID {
+			continue
+		}
+
+		if u := m.findUser(pc.requestedUserID); u != nil {
+			contacts = append(contacts, models.PendingContactModel{
+				ID:          u.ID,
+				Username:    u.Username,
+				DisplayName: copyString(u.DisplayName),
+				ImageURL:    copyString(u.ImageURL),
+				Message:     copyString(pc.message),
+			})
+		}
+	}
+
+	return contacts, nil
+}
+
+// ConfirmContactRequest confirms a contact request.
+func (m *MemoryStore) ConfirmContactRequest(requestedUserID int, addingUserID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.deletePendingContact(requestedUserID, addingUserID)
+
+	m.friends = append(m.friends,
+		&memoryRelation{id: m.newID(), userID: requestedUserID, otherUserID: addingUserID},
+		&memoryRelation{id: m.newID(), userID: addingUserID, otherUserID: requestedUserID})
+
+	return nil
+}
+
+// RejectContactRequest rejects a contact request.
+func (m *MemoryStore) RejectContactRequest(requestedUserID int, addingUserID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.deletePendingContact(requestedUserID, addingUserID) {
+		return errors.New("failed to reject pending contact")
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) deletePendingContact(requestedUserID int, addingUserID int) bool {
+	deleted := false
+	kept := m.pendingContacts[:0]
+
+	for _, pc := range m.pendingContacts {
+		if pc.requestedUserID == requestedUserID && pc.addingUserID == addingUserID {
+			deleted = true
+			continue
+		}
+
+		kept = append(kept, pc)
+	}
+
+	m.pendingContacts = kept
+
+	return deleted
+}
+
+// SetStatus sets a users status.
+func (m *MemoryStore) SetStatus(userID int, statusID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	u := m.findUser(userID)
+	if u == nil {
+		return errors.New("failed to set user status")
+	}
+
+	u.Status = statusID
+
+	return nil
+}
+
+// AddOfflineMessage queues a chat message for a user that is not currently online.
+func (m *MemoryStore) AddOfflineMessage(toUserID int, fromUserID int, message string, sentAt time.Time, messageID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.offlineMessages = append(m.offlineMessages, &memoryOfflineMessage{
+		OfflineMessageModel: models.OfflineMessageModel{
+			ID:         m.newID(),
+			FromUserID: fromUserID,
+			Message:    message,
+			SentAt:     time.Unix(sentAt.Unix(), 0),
+			MessageID:  messageID,
+		},
+		toUserID: toUserID,
+	})
+
+	return nil
+}
+
+// GetOfflineMessages returns the queued chat messages for a user, oldest first.
+func (m *MemoryStore) GetOfflineMessages(userID int) ([]models.OfflineMessageModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	messages := []models.OfflineMessageModel{}
+
+	for _, om := range m.offlineMessages {
+		if om.toUserID == userID {
+			messages = append(messages, om.OfflineMessageModel)
+		}
+	}
+
+	return messages, nil
+}
+
+// DeleteOfflineMessages removes a users queued chat messages up to and including the specified message id.
+func (m *MemoryStore) DeleteOfflineMessages(userID int, maxMessageID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	kept := m.offlineMessages[:0]
+
+	for _, om := range m.offlineMessages {
+		if om.toUserID != userID || om.ID > maxMessageID {
+			kept = append(kept, om)
+		}
+	}
+
+	m.offlineMessages = kept
+
+	return nil
+}
+
+// AddMessage records a message sent between two users in their conversation history.
+func (m *MemoryStore) AddMessage(fromUserID int, toUserID int, messageType int, content *string, sentAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, &memoryMessage{
+		MessageModel: models.MessageModel{
+			ID:          m.newID(),
+			FromUserID:  fromUserID,
+			MessageType: messageType,
+			Content:     copyString(content),
+			SentAt:      time.Unix(sentAt.Unix(), 0),
+		},
+		toUserID: toUserID,
+	})
+
+	return nil
+}
+
+// GetMessages returns up to count messages between a user and their contact, newest first. Only messages older than
+// the before message id are returned, or the most recent messages if it is 0.
+func (m *MemoryStore) GetMessages(userID int, contactUserID int, beforeMessageID int, count int) ([]models.MessageModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	messages := []models.MessageModel{}
+
+	for i := len(m.messages) - 1; i >= 0 && len(messages) < count; i-- {
+		msg := m.messages[i]
+
+		between := (msg.FromUserID == userID && msg.toUserID == contactUserID) ||
+			(msg.FromUserID == contactUserID && msg.toUserID == userID)
+
+		if between && (beforeMessageID == 0 || msg.ID < beforeMessageID) {
+			model := msg.MessageModel
+			model.Content = copyString(msg.Content)
+			messages = append(messages, model)
+		}
+	}
+
+	return messages, nil
+}
+
+// CreateRoom creates a new group conversation with the specified user as its first member and returns its id.
+func (m *MemoryStore) CreateRoom(userID int, name string) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	room := &models.RoomModel{ID: m.newID(), Name: name}
+
+	m.rooms = append(m.rooms, room)
+	m.roomMembers = append(m.roomMembers, &memoryRoomMember{id: m.newID(), roomID: room.ID, userID: userID})
+
+	return room.ID, nil
+}
+
+func (m *MemoryStore) findRoom(roomID int) *models.RoomModel {
+	for _, r := range m.rooms {
+		if r.ID == roomID {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// GetRoomByID returns a group conversation by id.
+func (m *MemoryStore) GetRoomByID(roomID int) (*models.RoomModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r := m.findRoom(roomID)
+	if r == nil {
+		return nil, nil
+	}
+
+	room := *r
+
+	return &room, nil
+}
+
+// GetUserRooms returns the group conversations a user is a member of.
+func (m *MemoryStore) GetUserRooms(userID int) ([]models.RoomModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rooms := []models.RoomModel{}
+
+	for _, rm := range m.roomMembers {
+		if rm.userID != userID {
+			continue
+		}
+
+		if r := m.findRoom(rm.roomID); r != nil {
+			rooms = append(rooms, *r)
+		}
+	}
+
+	return rooms, nil
+}
+
+// GetRoomMembers returns the members of a group conversation.
+func (m *MemoryStore) GetRoomMembers(roomID int) ([]models.RoomMemberModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	members := []models.RoomMemberModel{}
+
+	for _, rm := range m.roomMembers {
+		if rm.roomID != roomID {
+			continue
+		}
+
+		if u := m.findUser(rm.userID); u != nil {
+			members = append(members, models.RoomMemberModel{
+				ID:          u.ID,
+				Username:    u.Username,
+				DisplayName: copyString(u.DisplayName),
+				Status:      u.Status,
+			})
+		}
+	}
+
+	return members, nil
+}
+
+func (m *MemoryStore) findRoomMember(roomID int, userID int) *memoryRoomMember {
+	for _, rm := range m.roomMembers {
+		if rm.roomID == roomID && rm.userID == userID {
+			return rm
+		}
+	}
+
+	return nil
+}
+
+// GetRoomMember retreives a users membership of a group conversation.
+func (m *MemoryStore) GetRoomMember(roomID int, userID int) (*int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if rm := m.findRoomMember(roomID, userID); rm != nil {
+		rowID := rm.id
+		return &rowID, nil
+	}
+
+	return nil, nil
+}
+
+// AddRoomMember adds a user to a group conversation.
+func (m *MemoryStore) AddRoomMember(roomID int, userID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.findRoom(roomID) == nil || m.findRoomMember(roomID, userID) != nil {
+		return errors.New("failed to add room member")
+	}
+
+	m.roomMembers = append(m.roomMembers, &memoryRoomMember{id: m.newID(), roomID: roomID, userID: userID})
+
+	return nil
+}
+
+// RemoveRoomMember removes a user from a group conversation. The room is deleted when its last member is removed.
+func (m *MemoryStore) RemoveRoomMember(roomID int, userID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	kept := m.roomMembers[:0]
+	empty := true
+
+	for _, rm := range m.roomMembers {
+		if rm.roomID == roomID && rm.userID == userID {
+			continue
+		}
+
+		if rm.roomID == roomID {
+			empty = false
+		}
+
+		kept = append(kept, rm)
+	}
+
+	m.roomMembers = kept
+
+	if empty {
+		rooms := m.rooms[:0]
+
+		for _, r := range m.rooms {
+			if r.ID != roomID {
+				rooms = append(rooms, r)
+			}
+		}
+
+		m.rooms = rooms
+	}
+
+	return nil
+}
+
+// RenameRoom sets the name of a group conversation.
+func (m *MemoryStore) RenameRoom(roomID int, name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if r := m.findRoom(roomID); r != nil {
+		r.Name = name
+	}
+
+	return nil
+}
+
+// CreateSession stores a login session for a user that can be resumed with its token until it expires. Only the
+// hash of the token is stored.
+func (m *MemoryStore) CreateSession(userID int, tokenHash string, expiresAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	kept := m.sessions[:0]
+
+	for _, s := range m.sessions {
+		if s.userID != userID || s.expiresAt.After(now) {
+			kept = append(kept, s)
+		}
+	}
+
+	m.sessions = append(kept, &memorySession{userID: userID, tokenHash: tokenHash, expiresAt: expiresAt})
+
+	return nil
+}
+
+// GetSessionUserID returns the id of the user that owns an unexpired session token hash.
+func (m *MemoryStore) GetSessionUserID(tokenHash string) (*int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, s := range m.sessions {
+		if s.tokenHash == tokenHash && s.expiresAt.After(now) {
+			userID := s.userID
+			return &userID, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteUserSessions revokes all login sessions of a user.
+func (m *MemoryStore) DeleteUserSessions(userID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	kept := m.sessions[:0]
+
+	for _, s := range m.sessions {
+		if s.userID != userID {
+			kept = append(kept, s)
+		}
+	}
+
+	m.sessions = kept
+
+	return nil
+}
+
+// RemoveContact removes two users from each others contacts.
+func (m *MemoryStore) RemoveContact(userID int, removeUserID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	kept := m.friends[:0]
+
+	for _, r := range m.friends {
+		if (r.userID == userID && r.otherUserID == removeUserID) || (r.userID == removeUserID && r.otherUserID == userID) {
+			continue
+		}
+
+		kept = append(kept, r)
+	}
+
+	m.friends = kept
+
+	return nil
+}
+
+// BlockUser adds a user to another users block list.
+func (m *MemoryStore) BlockUser(userID int, blockedUserID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.findRelation(m.blocks, userID, blockedUserID) != nil {
+		return errors.New("failed to block user")
+	}
+
+	m.blocks = append(m.blocks, &memoryRelation{id: m.newID(), userID: userID, otherUserID: blockedUserID})
+
+	return nil
+}
+
+// UnblockUser removes a user from another users block list.
+func (m *MemoryStore) UnblockUser(userID int, blockedUserID int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, r := range m.blocks {
+		if r.userID == userID && r.otherUserID == blockedUserID {
+			m.blocks = append(m.blocks[:i], m.blocks[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("failed to unblock user")
+}
+
+// GetBlock retreives a users block of another user.
+func (m *MemoryStore) GetBlock(userID int, blockedUserID int) (*int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if r := m.findRelation(m.blocks, userID, blockedUserID); r != nil {
+		rowID := r.id
+		return &rowID, nil
+	}
+
+	return nil, nil
+}
+
+// GetBlockedUsers returns the users on a users block list.
+func (m *MemoryStore) GetBlockedUsers(userID int) ([]models.BlockedUserModel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	users := []models.BlockedUserModel{}
+
+	for _, r := range m.blocks {
+		if r.userID != userID {
+			continue
+		}
+
+		if u := m.findUser(r.otherUserID); u != nil {
+			users = append(users, models.BlockedUserModel{
+				ID:          u.ID,
+				Username:    u.Username,
+				DisplayName: copyString(u.DisplayName),
+			})
+		}
+	}
+
+	return users, nil
+}
+
+// GetBlockRelations returns the ids of users that the specified user has blocked or been blocked by.
+func (m *MemoryStore) GetBlockRelations(userID int) ([]int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	set := map[int]bool{}
+
+	for _, r := range m.blocks {
+		if r.userID == userID {
+			set[r.otherUserID] = true
+		} else if r.otherUserID == userID {
+			set[r.userID] = true
+		}
+	}
+
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// SetDisplayName sets a users display name.
+func (m *MemoryStore) SetDisplayName(userID int, displayName string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if u := m.findUser(userID); u != nil {
+		u.DisplayName = copyString(&displayName)
+	}
+
+	return nil
+}
+
+// SetStatusText sets a users status text. A nil status text clears it.
+func (m *MemoryStore) SetStatusText(userID int, statusText *string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if u := m.findUser(userID); u != nil {
+		u.StatusText = copyString(statusText)
+	}
+
+	return nil
+}
+
+// SetUserImage sets the URL of a users avatar image.
+func (m *MemoryStore) SetUserImage(userID int, imageURL string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if u := m.findUser(userID); u != nil {
+		u.ImageURL = copyString(&imageURL)
+	}
+
+	return nil
+}
+
+// Copies a string so callers can't change the stored value through the pointer.
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+
+	return &c
+}
@@ -0,0 +1,696 @@
+package dbaccess
+
+import (
+	"chatServer/models"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	// SQLite is embedded with a pure Go driver, so it needs no external database or cgo.
+	_ "modernc.org/sqlite"
+)
+
+// The SQLite schema, equivalent to the MySQL tables. Times are stored as unix seconds.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password TEXT NOT NULL,
+    email TEXT NOT NULL,
+    displayname TEXT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    statustext TEXT NULL,
+    userimage TEXT NULL,
+    validationguid TEXT NULL
+);
+
+CREATE TABLE IF NOT EXISTS friends (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userid INTEGER NOT NULL,
+    frienduserid INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_friends_userid ON friends (userid, frienduserid);
+
+CREATE TABLE IF NOT EXISTS pendingcontacts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    requesteduserid INTEGER NOT NULL,
+    addinguserid INTEGER NOT NULL,
+    message TEXT NULL
+);
+
+CREATE TABLE IF NOT EXISTS offlinemessages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    touserid INTEGER NOT NULL,
+    fromuserid INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    sentat INTEGER NOT NULL,
+    messageid INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_offlinemessages_touserid ON offlinemessages (touserid);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fromuserid INTEGER NOT NULL,
+    touserid INTEGER NOT NULL,
+    messagetype INTEGER NOT NULL,
+    content TEXT NULL,
+    sentat INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_from_to ON messages (fromuserid, touserid, id);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    createdbyuserid INTEGER NOT NULL,
+    createdat INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS roommembers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    roomid INTEGER NOT NULL,
+    userid INTEGER NOT NULL,
+    UNIQUE (roomid, userid)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userid INTEGER NOT NULL,
+    tokenhash TEXT NOT NULL UNIQUE,
+    expiresat INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userid INTEGER NOT NULL,
+    blockeduserid INTEGER NOT NULL,
+    UNIQUE (userid, blockeduserid)
+);
+`
+
+// SQLiteStore is a Store backed by an embedded SQLite database file.
+type SQLiteStore struct {
+	database *sql.DB
+}
+
+// NewSQLiteStore opens the SQLite database at the specified path, creating it and its tables if they don't exist.
+// The path ":memory:" opens a database that only lives as long as the store.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time, and each connection to ":memory:" would be a separate database.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{database: db}, nil
+}
+
+// Close closes the database.
+func (l *SQLiteStore) Close() error {
+	return l.database.Close()
+}
+
+// Runs the specified function in a transaction, which is committed if it returns no error.
+func (l *SQLiteStore) transaction(f func(tx *sql.Tx) error) error {
+	tx, err := l.database.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Executes a statement that must change exactly one row.
+func (l *SQLiteStore) execOne(failure string, query string, args ...interface{}) error {
+	res, err := l.database.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra != 1 {
+		return errors.New(failure)
+	}
+
+	return nil
+}
+
+// Returns the id of the single row found by a query, or nil if there isn't one.
+func (l *SQLiteStore) queryID(query string, args ...interface{}) (*int, error) {
+	var rowID int
+
+	err := l.database.QueryRow(query, args...).Scan(&rowID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rowID, nil
+}
+
+// GetUserByUsername returns a user model from the data store by username.
+func (l *SQLiteStore) GetUserByUsername(username string) (*models.UserModel, error) {
+	row := l.database.QueryRow(
+		"SELECT id, username, password, displayname, status, statustext, userimage FROM users WHERE username = ?",
+		username)
+
+	user := models.UserModel{}
+
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.DisplayName, &user.Status, &user.StatusText, &user.ImageURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserByID returns a user model from the data store by id.
+func (l *SQLiteStore) GetUserByID(userID int) (*models.UserModel, error) {
+	row := l.database.QueryRow(
+		"SELECT id, username, displayname, status, userimage, statustext FROM users WHERE id = ?",
+		userID)
+
+	user := models.UserModel{}
+
+	err := row.Scan(&user.ID, &user.Username, &user.DisplayName, &user.Status, &user.ImageURL, &user.StatusText)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// LoginUser logs a user in and sets their status to online.
+func (l *SQLiteStore) LoginUser(userID int) error {
+	_, err := l.database.Exec("UPDATE users SET status = ? WHERE id = ?", StatusOnline, userID)
+	return err
+}
+
+// LogoutUser logs a user out and sets their status to offline. Logging out a user that already appears offline, such
+// as an invisible user, succeeds.
+func (l *SQLiteStore) LogoutUser(userID int) error {
+	return l.execOne("failed to update", "UPDATE users SET status = ? WHERE id = ?", StatusOffline, userID)
+}
+
+// GetFriends returns a list of friends that the specified user has.
+func (l *SQLiteStore) GetFriends(userID int) ([]models.FriendModel, error) {
+	rows, err := l.database.Query(`
+		SELECT f.frienduserid, u.username, u.displayname, u.status, u.userimage, u.statustext
+		FROM friends AS f JOIN users AS u ON u.id = f.frienduserid
+		WHERE f.userid = ?
+		ORDER BY f.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friends := []models.FriendModel{}
+
+	for rows.Next() {
+		friend := models.FriendModel{}
+
+		err := rows.Scan(&friend.ID, &friend.Username, &friend.DisplayName, &friend.Status, &friend.ImageURL, &friend.StatusText)
+		if err != nil {
+			log.Printf("Failed to map friend model.")
+			continue
+		}
+
+		friends = append(friends, friend)
+	}
+
+	return friends, nil
+}
+
+// ResetUserStatuses sets all users to be offline.
+func (l *SQLiteStore) ResetUserStatuses() error {
+	_, err := l.database.Exec("UPDATE users SET status = ?", StatusOffline)
+	return err
+}
+
+// CreateAccount creates a new account.
+func (l *SQLiteStore) CreateAccount(username string, password string, email string, displayName string, validationGUID string) error {
+	return l.execOne("failed to create",
+		"INSERT INTO users (username, password, displayname, email, validationguid) VALUES (?, ?, ?, ?, ?)",
+		username, password, displayName, email, validationGUID)
+}
+
+// AddPendingContact adds a contact request for the requested user.
+func (l *SQLiteStore) AddPendingContact(userID int, userAddingID int, message *string) error {
+	return l.execOne("failed to add pending contact",
+		"INSERT INTO pendingcontacts (requesteduserid, addinguserid, message) VALUES (?, ?, ?)",
+		userID, userAddingID, message)
+}
+
+// GetPendingContact retreives a pending contact by user and requested user.
+func (l *SQLiteStore) GetPendingContact(requestedUserID int, addingUserID int) (*int, error) {
+	return l.queryID(
+		"SELECT id FROM pendingcontacts WHERE requesteduserid = ? AND addinguserid = ?",
+		requestedUserID, addingUserID)
+}
+
+// GetUserContactByContactUserID retreives a users contact.
+func (l *SQLiteStore) GetUserContactByContactUserID(userID int, contactUserID int) (*int, error) {
+	return l.queryID("SELECT id FROM friends WHERE userid = ? AND frienduserid = ?", userID, contactUserID)
+}
+
+// GetUserPendingContacts returns a list of a users pending contact requests.
+func (l *SQLiteStore) GetUserPendingContacts(userID int) ([]models.PendingContactModel, error) {
+	rows, err := l.database.Query(`
+		SELECT u.id, u.username, u.displayname, u.userimage, pc.message
+		FROM pendingcontacts AS pc JOIN users AS u ON u.id = pc.requesteduserid
+		WHERE pc.addinguserid = ?
+		ORDER BY pc.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []models.PendingContactModel{}
+
+	for rows.Next() {
+		c := models.PendingContactModel{}
+
+		err := rows.Scan(&c.ID, &c.Username, &c.DisplayName, &c.ImageURL, &c.Message)
+		if err != nil {
+			log.Printf("Failed to map pending contact model.")
+			continue
+		}
+
+		contacts = append(contacts, c)
+	}
+
+	return contacts, nil
+}
+
+// ConfirmContactRequest confirms a contact request.
+func (l *SQLiteStore) ConfirmContactRequest(requestedUserID int, addingUserID int) error {
+	return l.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"DELETE FROM pendingcontacts WHERE requesteduserid = ? AND addinguserid = ?",
+			requestedUserID, addingUserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO friends (userid, frienduserid) VALUES (?, ?), (?, ?)",
+			requestedUserID, addingUserID, addingUserID, requestedUserID)
+
+		return err
+	})
+}
+
+// RejectContactRequest rejects a contact request.
+func (l *SQLiteStore) RejectContactRequest(requestedUserID int, addingUserID int) error {
+	return l.execOne("failed to reject pending contact",
+		"DELETE FROM pendingcontacts WHERE requesteduserid = ? AND addinguserid = ?",
+		requestedUserID, addingUserID)
+}
+
+// SetStatus sets a users status.
+func (l *SQLiteStore) SetStatus(userID int, statusID int) error {
+	return l.execOne("failed to set user status", "UPDATE users SET status = ? WHERE id = ?", statusID, userID)
+}
+
+// AddOfflineMessage queues a chat message for a user that is not currently online.
+func (l *SQLiteStore) AddOfflineMessage(toUserID int, fromUserID int, message string, sentAt time.Time, messageID int) error {
+	return l.execOne("failed to add offline message",
+		"INSERT INTO offlinemessages (touserid, fromuserid, message, sentat, messageid) VALUES (?, ?, ?, ?, ?)",
+		toUserID, fromUserID, message, sentAt.Unix(), messageID)
+}
+
+// GetOfflineMessages returns the queued chat messages for a user, oldest first.
+func (l *SQLiteStore) GetOfflineMessages(userID int) ([]models.OfflineMessageModel, error) {
+	rows, err := l.database.Query(
+		"SELECT id, fromuserid, message, sentat, messageid FROM offlinemessages WHERE touserid = ? ORDER BY id",
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.OfflineMessageModel{}
+
+	for rows.Next() {
+		m := models.OfflineMessageModel{}
+		var sentAt int64
+
+		err := rows.Scan(&m.ID, &m.FromUserID, &m.Message, &sentAt, &m.MessageID)
+		if err != nil {
+			log.Printf("Failed to map offline message model.")
+			continue
+		}
+
+		m.SentAt = time.Unix(sentAt, 0)
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// DeleteOfflineMessages removes a users queued chat messages up to and including the specified message id.
+func (l *SQLiteStore) DeleteOfflineMessages(userID int, maxMessageID int) error {
+	_, err := l.database.Exec("DELETE FROM offlinemessages WHERE touserid = ? AND id <= ?", userID, maxMessageID)
+	return err
+}
+
+// AddMessage records a message sent between two users in their conversation history.
+func (l *SQLiteStore) AddMessage(fromUserID int, toUserID int, messageType int, content *string, sentAt time.Time) error {
+	return l.execOne("failed to add message",
+		"INSERT INTO messages (fromuserid, touserid, messagetype, content, sentat) VALUES (?, ?, ?, ?, ?)",
+		fromUserID, toUserID, messageType, content, sentAt.Unix())
+}
+
+// GetMessages returns up to count messages between a user and their contact, newest first. Only messages older than
+// the before message id are returned, or the most recent messages if it is 0.
+func (l *SQLiteStore) GetMessages(userID int, contactUserID int, beforeMessageID int, count int) ([]models.MessageModel, error) {
+	rows, err := l.database.Query(`
+		SELECT id, fromuserid, messagetype, content, sentat
+		FROM messages
+		WHERE ((fromuserid = ? AND touserid = ?) OR (fromuserid = ? AND touserid = ?))
+			AND (? = 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?`,
+		userID, contactUserID, contactUserID, userID, beforeMessageID, beforeMessageID, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.MessageModel{}
+
+	for rows.Next() {
+		m := models.MessageModel{}
+		var sentAt int64
+
+		err := rows.Scan(&m.ID, &m.FromUserID, &m.MessageType, &m.Content, &sentAt)
+		if err != nil {
+			log.Printf("Failed to map message model.")
+			continue
+		}
+
+		m.SentAt = time.Unix(sentAt, 0)
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// CreateRoom creates a new group conversation with the specified user as its first member and returns its id.
+func (l *SQLiteStore) CreateRoom(userID int, name string) (int, error) {
+	var roomID int64
+
+	err := l.transaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			"INSERT INTO rooms (name, createdbyuserid, createdat) VALUES (?, ?, ?)",
+			name, userID, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+
+		roomID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO roommembers (roomid, userid) VALUES (?, ?)", roomID, userID)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(roomID), nil
+}
+
+// GetRoomByID returns a group conversation by id.
+func (l *SQLiteStore) GetRoomByID(roomID int) (*models.RoomModel, error) {
+	room := models.RoomModel{}
+
+	err := l.database.QueryRow("SELECT id, name FROM rooms WHERE id = ?", roomID).Scan(&room.ID, &room.Name)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+// GetUserRooms returns the group conversations a user is a member of.
+func (l *SQLiteStore) GetUserRooms(userID int) ([]models.RoomModel, error) {
+	rows, err := l.database.Query(`
+		SELECT r.id, r.name
+		FROM roommembers AS rm JOIN rooms AS r ON r.id = rm.roomid
+		WHERE rm.userid = ?
+		ORDER BY rm.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []models.RoomModel{}
+
+	for rows.Next() {
+		r := models.RoomModel{}
+
+		err := rows.Scan(&r.ID, &r.Name)
+		if err != nil {
+			log.Printf("Failed to map room model.")
+			continue
+		}
+
+		rooms = append(rooms, r)
+	}
+
+	return rooms, nil
+}
+
+// GetRoomMembers returns the members of a group conversation.
+func (l *SQLiteStore) GetRoomMembers(roomID int) ([]models.RoomMemberModel, error) {
+	rows, err := l.database.Query(`
+		SELECT u.id, u.username, u.displayname, u.status
+		FROM roommembers AS rm JOIN users AS u ON u.id = rm.userid
+		WHERE rm.roomid = ?
+		ORDER BY rm.id`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.RoomMemberModel{}
+
+	for rows.Next() {
+		m := models.RoomMemberModel{}
+
+		err := rows.Scan(&m.ID, &m.Username, &m.DisplayName, &m.Status)
+		if err != nil {
+			log.Printf("Failed to map room member model.")
+			continue
+		}
+
+		members = append(members, m)
+	}
+
+	return members, nil
+}
+
+// GetRoomMember retreives a users membership of a group conversation.
+func (l *SQLiteStore) GetRoomMember(roomID int, userID int) (*int, error) {
+	return l.queryID("SELECT id FROM roommembers WHERE roomid = ? AND userid = ?", roomID, userID)
+}
+
+// AddRoomMember adds a user to a group conversation.
+func (l *SQLiteStore) AddRoomMember(roomID int, userID int) error {
+	return l.execOne("failed to add room member",
+		"INSERT INTO roommembers (roomid, userid) SELECT id, ? FROM rooms WHERE id = ?", userID, roomID)
+}
+
+// RemoveRoomMember removes a user from a group conversation. The room is deleted when its last member is removed.
+func (l *SQLiteStore) RemoveRoomMember(roomID int, userID int) error {
+	return l.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM roommembers WHERE roomid = ? AND userid = ?", roomID, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"DELETE FROM rooms WHERE id = ? AND NOT EXISTS (SELECT 1 FROM roommembers WHERE roomid = ?)",
+			roomID, roomID)
+
+		return err
+	})
+}
+
+// RenameRoom sets the name of a group conversation.
+func (l *SQLiteStore) RenameRoom(roomID int, name string) error {
+	_, err := l.database.Exec("UPDATE rooms SET name = ? WHERE id = ?", name, roomID)
+	return err
+}
+
+// CreateSession stores a login session for a user that can be resumed with its token until it expires. Only the
+// hash of the token is stored.
+func (l *SQLiteStore) CreateSession(userID int, tokenHash string, expiresAt time.Time) error {
+	return l.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM sessions WHERE userid = ? AND expiresat <= ?", userID, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO sessions (userid, tokenhash, expiresat) VALUES (?, ?, ?)",
+			userID, tokenHash, expiresAt.Unix())
+
+		return err
+	})
+}
+
+// GetSessionUserID returns the id of the user that owns an unexpired session token hash.
+func (l *SQLiteStore) GetSessionUserID(tokenHash string) (*int, error) {
+	return l.queryID(
+		"SELECT userid FROM sessions WHERE tokenhash = ? AND expiresat > ?",
+		tokenHash, time.Now().Unix())
+}
+
+// DeleteUserSessions revokes all login sessions of a user.
+func (l *SQLiteStore) DeleteUserSessions(userID int) error {
+	_, err := l.database.Exec("DELETE FROM sessions WHERE userid = ?", userID)
+	return err
+}
+
+// RemoveContact removes two users from each others contacts.
+func (l *SQLiteStore) RemoveContact(userID int, removeUserID int) error {
+	_, err := l.database.Exec(
+		"DELETE FROM friends WHERE (userid = ? AND frienduserid = ?) OR (userid = ? AND frienduserid = ?)",
+		userID, removeUserID, removeUserID, userID)
+	return err
+}
+
+// BlockUser adds a user to another users block list.
+func (l *SQLiteStore) BlockUser(userID int, blockedUserID int) error {
+	return l.execOne("failed to block user",
+		"INSERT INTO blocks (userid, blockeduserid) VALUES (?, ?)", userID, blockedUserID)
+}
+
+// UnblockUser removes a user from another users block list.
+func (l *SQLiteStore) UnblockUser(userID int, blockedUserID int) error {
+	return l.execOne("failed to unblock user",
+		"DELETE FROM blocks WHERE userid = ? AND blockeduserid = ?", userID, blockedUserID)
+}
+
+// GetBlock retreives a users block of another user.
+func (l *SQLiteStore) GetBlock(userID int, blockedUserID int) (*int, error) {
+	return l.queryID("SELECT id FROM blocks WHERE userid = ? AND blockeduserid = ?", userID, blockedUserID)
+}
+
+// GetBlockedUsers returns the users on a users block list.
+func (l *SQLiteStore) GetBlockedUsers(userID int) ([]models.BlockedUserModel, error) {
+	rows, err := l.database.Query(`
+		SELECT u.id, u.username, u.displayname
+		FROM blocks AS b JOIN users AS u ON u.id = b.blockeduserid
+		WHERE b.userid = ?
+		ORDER BY b.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.BlockedUserModel{}
+
+	for rows.Next() {
+		u := models.BlockedUserModel{}
+
+		err := rows.Scan(&u.ID, &u.Username, &u.DisplayName)
+		if err != nil {
+			log.Printf("Failed to map blocked user model.")
+			continue
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// GetBlockRelations returns the ids of users that the specified user has blocked or been blocked by.
+func (l *SQLiteStore) GetBlockRelations(userID int) ([]int, error) {
+	rows, err := l.database.Query(`
+		SELECT blockeduserid FROM blocks WHERE userid = ?
+		UNION
+		SELECT userid FROM blocks WHERE blockeduserid = ?`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// SetDisplayName sets a users display name.
+func (l *SQLiteStore) SetDisplayName(userID int, displayName string) error {
+	_, err := l.database.Exec("UPDATE users SET displayname = ? WHERE id = ?", displayName, userID)
+	return err
+}
+
+// SetStatusText sets a users status text. A nil status text clears it.
+func (l *SQLiteStore) SetStatusText(userID int, statusText *string) error {
+	_, err := l.database.Exec("UPDATE users SET statustext = ? WHERE id = ?", statusText, userID)
+	return err
+}
+
+// SetUserImage sets the URL of a users avatar image.
+func (l *SQLiteStore) SetUserImage(userID int, imageURL string) error {
+	_, err := l.database.Exec("UPDATE users SET userimage = ? WHERE id = ?", imageURL, userID)
+	return err
+}
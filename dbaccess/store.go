@@ -0,0 +1,138 @@
+package dbaccess
+
+import (
+	"chatServer/models"
+	"time"
+)
+
+// Store is the data store the chat server keeps its users, contacts and messages in. Lookups of a single item
+// return nil without an error when it doesn't exist.
+type Store interface {
+	// Close releases the resources held by the store.
+	Close() error
+
+	// GetUserByUsername returns a user model by username, including their password hash.
+	GetUserByUsername(username string) (*models.UserModel, error)
+
+	// GetUserByID returns a user model by id, without their password hash.
+	GetUserByID(userID int) (*models.UserModel, error)
+
+	// LoginUser logs a user in and sets their status to online.
+	LoginUser(userID int) error
+
+	// LogoutUser logs a user out and sets their status to offline.
+	LogoutUser(userID int) error
+
+	// GetFriends returns a list of friends that the specified user has.
+	GetFriends(userID int) ([]models.FriendModel, error)
+
+	// ResetUserStatuses sets all users to be offline.
+	ResetUserStatuses() error
+
+	// CreateAccount creates a new account.
+	CreateAccount(username string, password string, email string, displayName string, validationGUID string) error
+
+	// AddPendingContact adds a contact request for the requested user.
+	AddPendingContact(userID int, userAddingID int, message *string) error
+
+	// GetPendingContact retreives a pending contact by user and requested user.
+	GetPendingContact(requestedUserID int, addingUserID int) (*int, error)
+
+	// GetUserContactByContactUserID retreives a users contact.
+	GetUserContactByContactUserID(userID int, contactUserID int) (*int, error)
+
+	// GetUserPendingContacts returns a list of a users pending contact requests.
+	GetUserPendingContacts(userID int) ([]models.PendingContactModel, error)
+
+	// ConfirmContactRequest confirms a contact request.
+	ConfirmContactRequest(requestedUserID int, addingUserID int) error
+
+	// RejectContactRequest rejects a contact request.
+	RejectContactRequest(requestedUserID int, addingUserID int) error
+
+	// SetStatus sets a users status.
+	SetStatus(userID int, statusID int) error
+
+	// AddOfflineMessage queues a chat message for a user that is not currently online.
+	AddOfflineMessage(toUserID int, fromUserID int, message string, sentAt time.Time, messageID int) error
+
+	// GetOfflineMessages returns the queued chat messages for a user, oldest first.
+	GetOfflineMessages(userID int) ([]models.OfflineMessageModel, error)
+
+	// DeleteOfflineMessages removes a users queued chat messages up to and including the specified message id.
+	DeleteOfflineMessages(userID int, maxMessageID int) error
+
+	// AddMessage records a message sent between two users in their conversation history.
+	AddMessage(fromUserID int, toUserID int, messageType int, content *string, sentAt time.Time) error
+
+	// GetMessages returns up to count messages between a user and their contact, newest first, that are older than
+	// the before message id, or the most recent messages if it is 0.
+	GetMessages(userID int, contactUserID int, beforeMessageID int, count int) ([]models.MessageModel, error)
+
+	// CreateRoom creates a new group conversation with the specified user as its first member and returns its id.
+	CreateRoom(userID int, name string) (int, error)
+
+	// GetRoomByID returns a group conversation by id.
+	GetRoomByID(roomID int) (*models.RoomModel, error)
+
+	// GetUserRooms returns the group conversations a user is a member of.
+	GetUserRooms(userID int) ([]models.RoomModel, error)
+
+	// GetRoomMembers returns the members of a group conversation.
+	GetRoomMembers(roomID int) ([]models.RoomMemberModel, error)
+
+	// GetRoomMember retreives a users membership of a group conversation.
+	GetRoomMember(roomID int, userID int) (*int, error)
+
+	// AddRoomMember adds a user to a group conversation.
+	AddRoomMember(roomID int, userID int) error
+
+	// RemoveRoomMember removes a user from a group conversation, deleting it when its last member is removed.
+	RemoveRoomMember(roomID int, userID int) error
+
+	// RenameRoom sets the name of a group conversation.
+	RenameRoom(roomID int, name string) error
+
+	// CreateSession stores the hash of a login session token for a user, valid until it expires.
+	CreateSession(userID int, tokenHash string, expiresAt time.Time) error
+
+	// GetSessionUserID returns the id of the user that owns an unexpired session token hash.
+	GetSessionUserID(tokenHash string) (*int, error)
+
+	// DeleteUserSessions revokes all login sessions of a user.
+	DeleteUserSessions(userID int) error
+
+	// RemoveContact removes two users from each others contacts.
+	RemoveContact(userID int, removeUserID int) error
+
+	// BlockUser adds a user to another users block list.
+	BlockUser(userID int, blockedUserID int) error
+
+	// UnblockUser removes a user from another users block list.
+	UnblockUser(userID int, blockedUserID int) error
+
+	// GetBlock retreives a users block of another user.
+	GetBlock(userID int, blockedUserID int) (*int, error)
+
+	// GetBlockedUsers returns the users on a users block list.
+	GetBlockedUsers(userID int) ([]models.BlockedUserModel, error)
+
+	// GetBlockRelations returns the ids of users that the specified user has blocked or been blocked by.
+	GetBlockRelations(userID int) ([]int, error)
+
+	// SetDisplayName sets a users display name.
+	SetDisplayName(userID int, displayName string) error
+
+	// SetStatusText sets a users status text. A nil status text clears it.
+	SetStatusText(userID int, statusText *string) error
+
+	// SetUserImage sets the URL of a users avatar image.
+	SetUserImage(userID int, imageURL string) error
+}
+
+// Make sure each implementation satisfies the interface.
+var (
+	_ Store = (*MySQLStore)(nil)
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
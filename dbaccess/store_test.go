@@ -0,0 +1,270 @@
+package dbaccess
+
+import (
+	"testing"
+	"time"
+)
+
+// Runs the same tests against each Store implementation that doesn't need an external database.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := NewSQLiteStore(":memory:")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer store.Close()
+
+		test(t, store)
+	})
+}
+
+// Creates an account and returns the id of the new user.
+func createUser(t *testing.T, store Store, username string) int {
+	t.Helper()
+
+	err := store.CreateAccount(username, "hash", username+"@example.com", username, "guid")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	user, err := store.GetUserByUsername(username)
+	if err != nil || user == nil {
+		t.Fatalf("Expected user '%v' to exist, got %v, %v.", username, user, err)
+	}
+
+	return user.ID
+}
+
+func TestAccounts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		userID := createUser(t, store, "alice")
+
+		if err := store.CreateAccount("Alice", "hash", "other@example.com", "Other", "guid"); err == nil {
+			t.Errorf("Expected usernames to be unique regardless of case.")
+		}
+
+		if err := store.LoginUser(userID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		statusText := "Around"
+		store.SetStatusText(userID, &statusText)
+		store.SetDisplayName(userID, "Ali")
+		store.SetUserImage(userID, "/avatars/alice.png")
+
+		user, err := store.GetUserByID(userID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if user.Status != StatusOnline || *user.DisplayName != "Ali" || *user.StatusText != "Around" || *user.ImageURL != "/avatars/alice.png" {
+			t.Errorf("Unexpected user %+v.", user)
+		}
+
+		// Logging in already set the user online, publishing it again must not fail.
+		if err := store.SetStatus(userID, StatusOnline); err != nil {
+			t.Errorf("Expected setting an unchanged status to succeed, got %v.", err)
+		}
+
+		if err := store.LogoutUser(userID); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		// An invisible user is already offline when their last session logs out.
+		if err := store.LogoutUser(userID); err != nil {
+			t.Errorf("Expected logging out an offline user to succeed, got %v.", err)
+		}
+
+		missing, err := store.GetUserByID(userID + 100)
+		if missing != nil || err != nil {
+			t.Errorf("Expected (nil, nil), got (%v, %v).", missing, err)
+		}
+	})
+}
+
+func TestContacts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		alice := createUser(t, store, "alice")
+		bob := createUser(t, store, "bob")
+		carol := createUser(t, store, "carol")
+
+		message := "Hi"
+		store.AddPendingContact(alice, bob, &message)
+		store.AddPendingContact(carol, bob, nil)
+
+		pending, err := store.GetUserPendingContacts(bob)
+		if err != nil || len(pending) != 2 || pending[0].ID != alice || *pending[0].Message != "Hi" {
+			t.Fatalf("Unexpected pending contacts %+v, %v.", pending, err)
+		}
+
+		if rowID, _ := store.GetPendingContact(alice, bob); rowID == nil {
+			t.Errorf("Expected a pending contact.")
+		}
+
+		if err := store.ConfirmContactRequest(alice, bob); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := store.RejectContactRequest(carol, bob); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := store.RejectContactRequest(carol, bob); err == nil {
+			t.Errorf("Expected rejecting a missing request to fail.")
+		}
+
+		for _, ids := range [][2]int{{alice, bob}, {bob, alice}} {
+			friends, _ := store.GetFriends(ids[0])
+			if len(friends) != 1 || friends[0].ID != ids[1] {
+				t.Errorf("Expected user %v to have friend %v, got %+v.", ids[0], ids[1], friends)
+			}
+		}
+
+		store.RemoveContact(bob, alice)
+
+		if rowID, _ := store.GetUserContactByContactUserID(alice, bob); rowID != nil {
+			t.Errorf("Expected the contact to be removed.")
+		}
+	})
+}
+
+func TestMessages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		alice := createUser(t, store, "alice")
+		bob := createUser(t, store, "bob")
+		sentAt := time.Unix(1700000000, 0)
+
+		for i := 0; i < 5; i++ {
+			content := "message"
+			store.AddMessage(alice, bob, MessageTypeChat, &content, sentAt)
+		}
+		store.AddMessage(bob, alice, MessageTypeNudge, nil, sentAt)
+
+		page, err := store.GetMessages(alice, bob, 0, 4)
+		if err != nil || len(page) != 4 || page[0].MessageType != MessageTypeNudge || page[0].Content != nil {
+			t.Fatalf("Unexpected messages %+v, %v.", page, err)
+		}
+
+		older, _ := store.GetMessages(bob, alice, page[3].ID, 10)
+		if len(older) != 2 || older[0].ID >= page[3].ID || !older[0].SentAt.Equal(sentAt) {
+			t.Errorf("Unexpected older messages %+v.", older)
+		}
+
+		store.AddOfflineMessage(bob, alice, "first", sentAt, 7)
+		store.AddOfflineMessage(bob, alice, "second", sentAt, 8)
+
+		queued, _ := store.GetOfflineMessages(bob)
+		if len(queued) != 2 || queued[0].Message != "first" || queued[1].MessageID != 8 {
+			t.Fatalf("Unexpected offline messages %+v.", queued)
+		}
+
+		store.DeleteOfflineMessages(bob, queued[0].ID)
+
+		queued, _ = store.GetOfflineMessages(bob)
+		if len(queued) != 1 || queued[0].Message != "second" {
+			t.Errorf("Unexpected offline messages %+v.", queued)
+		}
+	})
+}
+
+func TestRooms(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		alice := createUser(t, store, "alice")
+		bob := createUser(t, store, "bob")
+
+		roomID, err := store.CreateRoom(alice, "Room")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := store.AddRoomMember(roomID, bob); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := store.AddRoomMember(roomID, bob); err == nil {
+			t.Errorf("Expected adding a member twice to fail.")
+		}
+
+		store.RenameRoom(roomID, "Renamed")
+
+		rooms, _ := store.GetUserRooms(bob)
+		if len(rooms) != 1 || rooms[0].Name != "Renamed" {
+			t.Errorf("Unexpected rooms %+v.", rooms)
+		}
+
+		members, _ := store.GetRoomMembers(roomID)
+		if len(members) != 2 || members[0].ID != alice || members[1].ID != bob {
+			t.Errorf("Unexpected members %+v.", members)
+		}
+
+		store.RemoveRoomMember(roomID, alice)
+		if room, _ := store.GetRoomByID(roomID); room == nil {
+			t.Fatalf("Expected the room to remain while it has members.")
+		}
+
+		store.RemoveRoomMember(roomID, bob)
+		if room, _ := store.GetRoomByID(roomID); room != nil {
+			t.Errorf("Expected the room to be removed with its last member.")
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		alice := createUser(t, store, "alice")
+
+		store.CreateSession(alice, "valid", time.Now().Add(time.Hour))
+		store.CreateSession(alice, "expired", time.Now().Add(-time.Hour))
+
+		if userID, _ := store.GetSessionUserID("valid"); userID == nil || *userID != alice {
+			t.Errorf("Expected the session to belong to user %v, got %v.", alice, userID)
+		}
+
+		if userID, _ := store.GetSessionUserID("expired"); userID != nil {
+			t.Errorf("Expected an expired session to be ignored.")
+		}
+
+		store.DeleteUserSessions(alice)
+
+		if userID, _ := store.GetSessionUserID("valid"); userID != nil {
+			t.Errorf("Expected the session to be revoked.")
+		}
+	})
+}
+
+func TestBlocks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		alice := createUser(t, store, "alice")
+		bob := createUser(t, store, "bob")
+		carol := createUser(t, store, "carol")
+
+		store.BlockUser(alice, bob)
+		store.BlockUser(carol, alice)
+
+		if err := store.BlockUser(alice, bob); err == nil {
+			t.Errorf("Expected blocking a user twice to fail.")
+		}
+
+		blocked, _ := store.GetBlockedUsers(alice)
+		if len(blocked) != 1 || blocked[0].ID != bob {
+			t.Errorf("Unexpected blocked users %+v.", blocked)
+		}
+
+		relations, _ := store.GetBlockRelations(alice)
+		if len(relations) != 2 {
+			t.Errorf("Expected 2 block relations, got %v.", relations)
+		}
+
+		if err := store.UnblockUser(alice, bob); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if rowID, _ := store.GetBlock(alice, bob); rowID != nil {
+			t.Errorf("Expected the block to be removed.")
+		}
+	})
+}
@@ -0,0 +1,15 @@
+DELIMITER $$
+CREATE PROCEDURE addMessage(
+    pFromUserID int,
+    pToUserID int,
+    pMessageType int,
+    pContent longtext,
+    pSentAt bigint
+    )
+BEGIN
+	INSERT INTO messages
+        (fromuserid, touserid, messagetype, content, sentat)
+    VALUES
+        (pFromUserID, pToUserID, pMessageType, pContent, FROM_UNIXTIME(pSentAt));
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,15 @@
+DELIMITER $$
+CREATE PROCEDURE addOfflineMessage(
+    pToUserID int,
+    pFromUserID int,
+    pMessage text,
+    pSentAt bigint,
+    pMessageID int
+    )
+BEGIN
+	INSERT INTO offlinemessages
+        (touserid, fromuserid, message, sentat, messageid)
+    VALUES
+        (pToUserID, pFromUserID, pMessage, FROM_UNIXTIME(pSentAt), pMessageID);
+END $$
+DELIMITER ;
\ No newline at end of file
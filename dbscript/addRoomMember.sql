@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE addRoomMember(pRoomID int, pUserID int)
+BEGIN
+	INSERT INTO roommembers (roomid, userid) VALUES (pRoomID, pUserID);
+END $$
+DELIMITER ;
\ No newline at end of file
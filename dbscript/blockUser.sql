@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE blockUser(pUserID int, pBlockedUserID int)
+BEGIN
+	INSERT INTO blocks (userid, blockeduserid) VALUES (pUserID, pBlockedUserID);
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE blocks (
+    id int NOT NULL AUTO_INCREMENT,
+    userid int NOT NULL,
+    blockeduserid int NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_blocks_userid_blockeduserid (userid, blockeduserid),
+    KEY idx_blocks_blockeduserid (blockeduserid),
+    CONSTRAINT fk_blocks_userid FOREIGN KEY (userid) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_blocks_blockeduserid FOREIGN KEY (blockeduserid) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
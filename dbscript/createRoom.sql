@@ -0,0 +1,15 @@
+DELIMITER $$
+CREATE PROCEDURE createRoom(
+    pUserID int,
+    pName varchar(50)
+    )
+BEGIN
+	INSERT INTO rooms (name, createdbyuserid) VALUES (pName, pUserID);
+
+    SET @roomID = LAST_INSERT_ID();
+
+    INSERT INTO roommembers (roomid, userid) VALUES (@roomID, pUserID);
+
+    SELECT @roomID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,15 @@
+DELIMITER $$
+CREATE PROCEDURE createSession(
+    pUserID int,
+    pTokenHash char(64),
+    pExpiresAt bigint
+    )
+BEGIN
+	DELETE FROM sessions WHERE userid = pUserID AND expiresat <= NOW();
+
+	INSERT INTO sessions
+        (userid, tokenhash, expiresat)
+    VALUES
+        (pUserID, pTokenHash, FROM_UNIXTIME(pExpiresAt));
+END $$
+DELIMITER ;
\ No newline at end of file
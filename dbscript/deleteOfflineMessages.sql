@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE deleteOfflineMessages(pUserID int, pMaxID int)
+BEGIN
+	DELETE FROM offlinemessages WHERE touserid = pUserID AND id <= pMaxID;
+END $$
+DELIMITER ;
\ No newline at end of file
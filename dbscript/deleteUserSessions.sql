@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE deleteUserSessions(pUserID int)
+BEGIN
+	DELETE FROM sessions WHERE userid = pUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
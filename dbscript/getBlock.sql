@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE getBlock(pUserID int, pBlockedUserID int)
+BEGIN
+	SELECT id FROM blocks WHERE userid = pUserID AND blockeduserid = pBlockedUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,8 @@
+DELIMITER $$
+CREATE PROCEDURE getBlockRelations(pUserID int)
+BEGIN
+	SELECT blockeduserid FROM blocks WHERE userid = pUserID
+    UNION
+    SELECT userid FROM blocks WHERE blockeduserid = pUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
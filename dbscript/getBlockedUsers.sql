@@ -0,0 +1,14 @@
+DELIMITER $$
+CREATE PROCEDURE getBlockedUsers(pUserID int)
+BEGIN
+	SELECT
+        u.id,
+        u.username,
+        u.displayname
+    FROM
+        chatzorz.blocks AS b
+        JOIN chatzorz.users AS u ON u.id = b.blockeduserid
+    WHERE
+        b.userid = pUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,23 @@
+DELIMITER $$
+CREATE PROCEDURE getMessages(
+    pUserID int,
+    pContactUserID int,
+    pBeforeID int,
+    pCount int
+    )
+BEGIN
+	SELECT
+        id,
+        fromuserid,
+        messagetype,
+        content,
+        UNIX_TIMESTAMP(sentat)
+    FROM
+        messages
+    WHERE
+        ((fromuserid = pUserID AND touserid = pContactUserID) OR (fromuserid = pContactUserID AND touserid = pUserID))
+        AND (pBeforeID = 0 OR id < pBeforeID)
+    ORDER BY id DESC
+    LIMIT pCount;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE getOfflineMessages(pUserID int)
+BEGIN
+	SELECT id, fromuserid, message, UNIX_TIMESTAMP(sentat), messageid FROM offlinemessages WHERE touserid = pUserID ORDER BY id;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE getRoomById(pRoomID int)
+BEGIN
+	SELECT id, name FROM rooms WHERE id = pRoomID;
+END $$
+DELIMITER ;
\ No newline at end of file
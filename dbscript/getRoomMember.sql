@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE getRoomMember(pRoomID int, pUserID int)
+BEGIN
+	SELECT id FROM roommembers WHERE roomid = pRoomID AND userid = pUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,15 @@
+DELIMITER $$
+CREATE PROCEDURE getRoomMembers(pRoomID int)
+BEGIN
+	SELECT
+        u.id,
+        u.username,
+        u.displayname,
+        u.status
+    FROM
+        chatzorz.roommembers AS rm
+        JOIN chatzorz.users AS u ON u.id = rm.userid
+    WHERE
+        rm.roomid = pRoomID;
+END $$
+DELIMITER ;
\ No newline at end of file
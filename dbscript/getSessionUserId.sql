@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE getSessionUserId(pTokenHash char(64))
+BEGIN
+	SELECT userid FROM sessions WHERE tokenhash = pTokenHash AND expiresat > NOW();
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -1,6 +1,6 @@
 DELIMITER $$
 CREATE PROCEDURE getUserByUsername(pUsername varchar(50))
 BEGIN
-	SELECT id, username, password, displayname, status, statusText FROM users WHERE username = pUsername;
+	SELECT id, username, password, displayname, status, statusText, userimage FROM users WHERE username = pUsername;
 END $$
 DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,13 @@
+DELIMITER $$
+CREATE PROCEDURE getUserRooms(pUserID int)
+BEGIN
+	SELECT
+        r.id,
+        r.name
+    FROM
+        chatzorz.roommembers AS rm
+        JOIN chatzorz.rooms AS r ON r.id = rm.roomid
+    WHERE
+        rm.userid = pUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -1,6 +1,6 @@
 DELIMITER $$
 CREATE PROCEDURE logoffUser(pId int)
 BEGIN
-    UPDATE users SET status = 0 WHERE id = pId AND status <> 0;
+    UPDATE users SET status = 0 WHERE id = pId;
 END $$
 DELIMITER ;
\ No newline at end of file
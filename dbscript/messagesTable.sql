@@ -0,0 +1,13 @@
+CREATE TABLE messages (
+    id int NOT NULL AUTO_INCREMENT,
+    fromuserid int NOT NULL,
+    touserid int NOT NULL,
+    messagetype int NOT NULL,
+    content longtext NULL,
+    sentat datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_messages_from_to (fromuserid, touserid, id),
+    KEY idx_messages_to_from (touserid, fromuserid, id),
+    CONSTRAINT fk_messages_fromuserid FOREIGN KEY (fromuserid) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_messages_touserid FOREIGN KEY (touserid) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
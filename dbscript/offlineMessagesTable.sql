@@ -0,0 +1,12 @@
+CREATE TABLE offlinemessages (
+    id int NOT NULL AUTO_INCREMENT,
+    touserid int NOT NULL,
+    fromuserid int NOT NULL,
+    message text NOT NULL,
+    sentat datetime NOT NULL,
+    messageid int NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    KEY idx_offlinemessages_touserid (touserid),
+    CONSTRAINT fk_offlinemessages_touserid FOREIGN KEY (touserid) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_offlinemessages_fromuserid FOREIGN KEY (fromuserid) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
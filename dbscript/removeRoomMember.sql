@@ -0,0 +1,11 @@
+DELIMITER $$
+CREATE PROCEDURE removeRoomMember(pRoomID int, pUserID int)
+BEGIN
+
+	DELETE FROM roommembers WHERE roomid = pRoomID AND userid = pUserID;
+
+    -- Rooms are removed once the last member leaves.
+    DELETE FROM rooms WHERE id = pRoomID AND NOT EXISTS (SELECT 1 FROM roommembers WHERE roomid = pRoomID);
+
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE renameRoom(pRoomID int, pName varchar(50))
+BEGIN
+    UPDATE rooms SET name = pName WHERE id = pRoomID;
+END $$
+DELIMITER ;
\ No newline at end of file
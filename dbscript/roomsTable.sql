@@ -0,0 +1,18 @@
+CREATE TABLE rooms (
+    id int NOT NULL AUTO_INCREMENT,
+    name varchar(50) NOT NULL,
+    createdbyuserid int NOT NULL,
+    createdat datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE roommembers (
+    id int NOT NULL AUTO_INCREMENT,
+    roomid int NOT NULL,
+    userid int NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_roommembers_roomid_userid (roomid, userid),
+    KEY idx_roommembers_userid (userid),
+    CONSTRAINT fk_roommembers_roomid FOREIGN KEY (roomid) REFERENCES rooms (id) ON DELETE CASCADE,
+    CONSTRAINT fk_roommembers_userid FOREIGN KEY (userid) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE sessions (
+    id int NOT NULL AUTO_INCREMENT,
+    userid int NOT NULL,
+    tokenhash char(64) NOT NULL,
+    expiresat datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_sessions_tokenhash (tokenhash),
+    KEY idx_sessions_userid (userid),
+    CONSTRAINT fk_sessions_userid FOREIGN KEY (userid) REFERENCES users (id) ON DELETE CASCADE
+);
\ No newline at end of file
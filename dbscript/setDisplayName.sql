@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE setDisplayName(pId int, pDisplayName varchar(20))
+BEGIN
+    UPDATE users SET displayname = pDisplayName WHERE id = pId;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE setStatusText(pId int, pStatusText varchar(100))
+BEGIN
+    UPDATE users SET statustext = pStatusText WHERE id = pId;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE setUserImage(pId int, pUserImage varchar(255))
+BEGIN
+    UPDATE users SET userimage = pUserImage WHERE id = pId;
+END $$
+DELIMITER ;
\ No newline at end of file
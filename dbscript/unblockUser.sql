@@ -0,0 +1,6 @@
+DELIMITER $$
+CREATE PROCEDURE unblockUser(pUserID int, pBlockedUserID int)
+BEGIN
+	DELETE FROM blocks WHERE userid = pUserID AND blockeduserid = pBlockedUserID;
+END $$
+DELIMITER ;
\ No newline at end of file
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/dbaccess"
+	"chatServer/requests"
+	"chatServer/roster"
+	"chatServer/server"
+	"log"
+)
+
+// HandleBlockUser handles the receipt of a block user packet. The blocked user can no longer send the client
+// contact requests or direct packets, and the two users appear offline to each other.
+func HandleBlockUser(s *server.TCPServer, client *server.Client, request *requests.BlockUser) {
+	blockUserID := request.UserID
+
+	if blockUserID == client.UserID {
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultUserNotFound, blockUserID))
+		return
+	}
+
+	user, err := store.GetUserByID(blockUserID)
+	if err != nil {
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultFailed, blockUserID))
+		return
+	}
+
+	if user == nil {
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultUserNotFound, blockUserID))
+		return
+	}
+
+	rowID, err := store.GetBlock(client.UserID, blockUserID)
+	if err != nil {
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultFailed, blockUserID))
+		return
+	}
+
+	if rowID != nil {
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultAlreadyBlocked, blockUserID))
+		return
+	}
+
+	err = store.BlockUser(client.UserID, blockUserID)
+	if err != nil {
+		log.Printf("Failed to block user id '%v' for user id '%v': %v", blockUserID, client.UserID, err)
+		client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultFailed, blockUserID))
+		return
+	}
+
+	roster.AddBlock(client.UserID, blockUserID)
+
+	client.SendPacket(builders.NewBlockUserResponsePacket(builders.BlockResultSuccess, blockUserID))
+
+	// The blocked user can no longer reach the client, so end any call and typing between them.
+	endCallsBetween(s, client.UserID, blockUserID)
+	StopTyping(s, client.UserID, blockUserID)
+	StopTyping(s, blockUserID, client.UserID)
+
+	// Contacts that were online to each other now go offline.
+	isContact, err := roster.IsContact(client.UserID, blockUserID)
+	if err == nil && isContact {
+		s.BroadcastPacketToUserID(client.UserID, builders.NewUserStatusChangePacket(blockUserID, dbaccess.StatusOffline))
+		s.BroadcastPacketToUserID(blockUserID, builders.NewUserStatusChangePacket(client.UserID, dbaccess.StatusOffline))
+	}
+}
+
+// HandleUnblockUser handles the receipt of an unblock user packet.
+func HandleUnblockUser(s *server.TCPServer, client *server.Client, request *requests.UnblockUser) {
+	blockedUserID := request.UserID
+
+	rowID, err := store.GetBlock(client.UserID, blockedUserID)
+	if err != nil {
+		client.SendPacket(builders.NewUnblockUserResponsePacket(builders.BlockResultFailed, blockedUserID))
+		return
+	}
+
+	if rowID == nil {
+		client.SendPacket(builders.NewUnblockUserResponsePacket(builders.BlockResultNotBlocked, blockedUserID))
+		return
+	}
+
+	err = store.UnblockUser(client.UserID, blockedUserID)
+	if err != nil {
+		log.Printf("Failed to unblock user id '%v' for user id '%v': %v", blockedUserID, client.UserID, err)
+		client.SendPacket(builders.NewUnblockUserResponsePacket(builders.BlockResultFailed, blockedUserID))
+		return
+	}
+
+	roster.InvalidateBlocks(client.UserID, blockedUserID)
+
+	client.SendPacket(builders.NewUnblockUserResponsePacket(builders.BlockResultSuccess, blockedUserID))
+
+	// If they are contacts and neither is still blocking the other, show each other their real status again.
+	isContact, err := roster.IsContact(client.UserID, blockedUserID)
+	if err != nil || !isContact {
+		return
+	}
+
+	blocked, err := roster.IsBlocked(client.UserID, blockedUserID)
+	if err != nil || blocked {
+		return
+	}
+
+	user, err := store.GetUserByID(blockedUserID)
+	if err != nil || user == nil {
+		return
+	}
+
+	s.BroadcastPacketToUserID(client.UserID, builders.NewUserStatusChangePacket(blockedUserID, user.Status))
+	s.BroadcastPacketToUserID(blockedUserID, builders.NewUserStatusChangePacket(client.UserID, presenceTracker.Published(client.UserID)))
+}
+
+// HandleGetBlockedUsers handles the receipt of a get blocked users packet.
+func HandleGetBlockedUsers(s *server.TCPServer, client *server.Client, request *requests.GetBlockedUsers) {
+	users, err := store.GetBlockedUsers(client.UserID)
+	if err != nil {
+		log.Printf("Failed to get blocked users for user id '%v': %v", client.UserID, err)
+		users = nil
+	}
+
+	client.SendPacket(builders.NewBlockedUsersPacket(users))
+}
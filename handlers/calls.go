@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/calls"
+	"chatServer/requests"
+	"chatServer/server"
+)
+
+var callRegistry = calls.NewRegistry()
+
+// HandleCallInvite handles the receipt of a call invite packet. Every session of the called user rings until one
+// of them accepts.
+func HandleCallInvite(s *server.TCPServer, client *server.Client, request *requests.CallInvite) {
+	calleeUserID := request.CalleeUserID
+
+	// A block looks the same as not being contacts, so the caller can't tell they were blocked.
+	if !canReach(client.UserID, calleeUserID) {
+		client.SendPacket(builders.NewCallInviteResponsePacket(builders.CallResultNotContact, 0, calleeUserID))
+		return
+	}
+
+	if len(s.GetClientsByID(calleeUserID)) == 0 {
+		client.SendPacket(builders.NewCallInviteResponsePacket(builders.CallResultUserOffline, 0, calleeUserID))
+		return
+	}
+
+	call, err := callRegistry.Start(client, calleeUserID)
+	if err != nil {
+		client.SendPacket(builders.NewCallInviteResponsePacket(builders.CallResultAlreadyInCall, 0, calleeUserID))
+		return
+	}
+
+	client.SendPacket(builders.NewCallInviteResponsePacket(builders.CallResultSuccess, call.ID, calleeUserID))
+	s.BroadcastPacketToUserID(calleeUserID, builders.NewCallIncomingPacket(call.ID, client.UserID))
+}
+
+// HandleCallAccept handles the receipt of a call accept packet.
+func HandleCallAccept(s *server.TCPServer, client *server.Client, request *requests.CallAccept) {
+	callID := request.CallID
+
+	call, err := callRegistry.Accept(callID, client)
+	if err != nil {
+		client.SendPacket(builders.NewCallStateChangePacket(callID, client.UserID, builders.CallStateEnded))
+		return
+	}
+
+	// The called users other sessions stop ringing when they see the accept.
+	notifyCallStateChange(s, call, client.UserID, builders.CallStateAccepted)
+}
+
+// HandleCallDecline handles the receipt of a call decline packet.
+func HandleCallDecline(s *server.TCPServer, client *server.Client, request *requests.CallDecline) {
+	endCall(s, client, request.CallID, builders.CallStateDeclined)
+}
+
+// HandleCallHangup handles the receipt of a call hangup packet.
+func HandleCallHangup(s *server.TCPServer, client *server.Client, request *requests.CallHangup) {
+	endCall(s, client, request.CallID, builders.CallStateEnded)
+}
+
+// HandleAudio handles the receipt of an audio packet, forwarding it to the other participant of the clients call.
+func HandleAudio(s *server.TCPServer, client *server.Client, request *requests.Audio) {
+	peer := callRegistry.Peer(client)
+	if peer == nil {
+		return
+	}
+
+	peer.SendPacket(&server.Packet{
+		ID:   builders.PacketIDAudio,
+		Data: &request.Data,
+	})
+}
+
+// EndClientCall ends the call a client is in, if any, and the calls ringing for the user if it was their last
+// session. Used when the client disconnects.
+func EndClientCall(s *server.TCPServer, client *server.Client) {
+	call := callRegistry.EndClientCall(client)
+	if call != nil {
+		notifyCallStateChange(s, call, client.UserID, builders.CallStateEnded)
+	}
+
+	if len(s.GetClientsByID(client.UserID)) > 0 {
+		return
+	}
+
+	for _, call := range callRegistry.EndRinging(client.UserID) {
+		notifyCallStateChange(s, call, client.UserID, builders.CallStateEnded)
+	}
+}
+
+// Ends the calls between two users that can no longer reach each other.
+func endCallsBetween(s *server.TCPServer, userID int, otherUserID int) {
+	for _, call := range callRegistry.EndBetween(userID, otherUserID) {
+		notifyCallStateChange(s, call, userID, builders.CallStateEnded)
+	}
+}
+
+func endCall(s *server.TCPServer, client *server.Client, callID int, state int) {
+	call, err := callRegistry.End(callID, client)
+	if err != nil {
+		return
+	}
+
+	notifyCallStateChange(s, call, client.UserID, state)
+}
+
+// Sends a call state change to the caller and every session of the called user.
+func notifyCallStateChange(s *server.TCPServer, call *calls.Call, byUserID int, state int) {
+	statePacket := builders.NewCallStateChangePacket(call.ID, byUserID, state)
+
+	call.Caller.SendPacket(statePacket)
+	s.BroadcastPacketToUserID(call.CalleeUserID, statePacket)
+}
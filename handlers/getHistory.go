@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/models"
+	"chatServer/requests"
+	"chatServer/server"
+	"log"
+)
+
+// The maximum number of messages returned in a single page of history.
+const historyPageSize = 50
+
+// The maximum size of a page of history in bytes, so a page of images stays well under the largest packet a client
+// accepts.
+const historyPageBytes = 4 * 1024 * 1024
+
+// The bytes a history result takes besides the message contents: the contact id and message count of the page, and
+// the id, sender, type, content length and time of each message.
+const (
+	historyPageOverhead    = 8
+	historyMessageOverhead = 24
+)
+
+// HandleGetHistory handles the receipt of a get history packet. The client sends the id of the oldest message it
+// has (or 0 for the most recent) and receives the page of messages before it.
+func HandleGetHistory(s *server.TCPServer, client *server.Client, request *requests.GetHistory) {
+	contactUserID := request.ContactUserID
+	beforeMessageID := request.BeforeMessageID
+
+	if beforeMessageID < 0 {
+		beforeMessageID = 0
+	}
+
+	messages, err := store.GetMessages(client.UserID, contactUserID, beforeMessageID, historyPageSize)
+	if err != nil {
+		log.Printf("Failed to get history for user id '%v': %v", client.UserID, err)
+		messages = nil
+	}
+
+	messages = limitHistoryPage(messages, s.Settings().MaxPacketLength)
+
+	client.SendPacket(builders.NewHistoryResultPacket(contactUserID, messages))
+}
+
+// Cuts a page of messages, newest first, down to the messages that fit in the page size. The newest message is always
+// kept so the client can page past it, without its content if that could never fit in a packet.
+func limitHistoryPage(messages []models.MessageModel, maxPacketLength int64) []models.MessageModel {
+	limit := int64(historyPageBytes)
+	if maxPacketLength < limit {
+		limit = maxPacketLength
+	}
+
+	size := int64(historyPageOverhead)
+
+	for i, m := range messages {
+		size += historyMessageOverhead
+		if m.Content != nil {
+			size += int64(len(*m.Content))
+		}
+
+		if size <= limit {
+			continue
+		}
+
+		if i > 0 {
+			return messages[:i]
+		}
+
+		if size > maxPacketLength {
+			messages[0].Content = nil
+		}
+
+		return messages[:1]
+	}
+
+	return messages
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/server"
+	"context"
+	"log"
+	"time"
+)
+
+// HandlePing handles the receipt of a ping packet from a client checking its connection.
+func HandlePing(s *server.TCPServer, client *server.Client, request *requests.Ping) {
+	client.SendPacket(builders.NewPongPacket(request.Sequence))
+}
+
+// HandlePong handles the receipt of a pong packet in reply to a ping from the server.
+func HandlePong(s *server.TCPServer, client *server.Client, request *requests.Pong) {
+	sequence := request.Sequence
+
+	client.PongReceived(sequence, time.Now())
+}
+
+// RunHeartbeat pings every connected client at the specified interval, and disconnects clients that don't reply
+// within the timeout. Blocks until the context is cancelled.
+func RunHeartbeat(ctx context.Context, s *server.TCPServer, interval time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, c := range s.Clients() {
+				sequence, alive := c.NextPing(now, timeout)
+				if !alive {
+					log.Printf("Client '%v' didn't reply to ping within %v, disconnecting.", c.Username, timeout)
+					c.CloseNow()
+					continue
+				}
+
+				if sequence != 0 {
+					c.SendPacket(builders.NewPingPacket(sequence))
+				}
+			}
+		}
+	}
+}
@@ -4,70 +4,199 @@ import (
 	"chatServer/builders"
 	"chatServer/dbaccess"
 	"chatServer/models"
+	"chatServer/requests"
+	"chatServer/roster"
 	"chatServer/server"
 	"chatServer/utils"
 	"log"
-	"strings"
+	"time"
 )
 
+// How long a session token issued at login can be used to resume without a password.
+const sessionTokenDuration = 30 * 24 * time.Hour
+
 // HandleLogin handles the receipt of a login packet.
-func HandleLogin(s *server.TCPServer, client *server.Client, packet *server.Packet) {
-	parts := strings.Split(string(*packet.Data), "\n")
-	username := parts[0]
-	password := parts[1]
+func HandleLogin(s *server.TCPServer, client *server.Client, request *requests.Login) {
+	username := request.Username
+	password := request.Password
 
-	user, err := dbaccess.GetUserByUsername(username)
+	user, err := store.GetUserByUsername(username)
 	if err != nil {
-		panic(err.Error())
+		log.Printf("Failed to get user '%v': %v", username, err)
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+		return
+	}
+
+	if user != nil && utils.ComparePasswordHashes(password, user.Password) {
+		token := utils.GenerateSessionToken()
+		sessionToken := &token
+
+		// The user can still log in without a session, they just won't be able to resume it.
+		err := store.CreateSession(user.ID, utils.HashSessionToken(token), time.Now().Add(sessionTokenDuration))
+		if err != nil {
+			log.Printf("Failed to create session for user '%v': %v", username, err)
+			sessionToken = nil
+		}
+
+		completeLogin(s, client, user, sessionToken)
+	} else {
+		log.Printf("User '%v' login denied.", username)
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+	}
+}
+
+// HandleTokenLogin handles the receipt of a token login packet, resuming a session from an earlier password login.
+func HandleTokenLogin(s *server.TCPServer, client *server.Client, request *requests.TokenLogin) {
+	token := request.Token
+
+	userID, err := store.GetSessionUserID(utils.HashSessionToken(token))
+	if err != nil {
+		log.Printf("Failed to get session: %v", err)
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+		return
+	}
+
+	if userID == nil {
+		log.Printf("Token login denied.")
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+		return
 	}
 
-	loggedIn := false
+	user, err := store.GetUserByID(*userID)
+	if err != nil || user == nil {
+		log.Printf("Failed to get user ID '%v' for token login.", *userID)
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+		return
+	}
+
+	completeLogin(s, client, user, &token)
+}
+
+// HandleLogoutEverywhere handles the receipt of a logout everywhere packet. All session tokens of the user are
+// revoked and their other connected clients are disconnected.
+func HandleLogoutEverywhere(s *server.TCPServer, client *server.Client, request *requests.LogoutEverywhere) {
+	err := store.DeleteUserSessions(client.UserID)
+	if err != nil {
+		log.Printf("Failed to revoke sessions for user ID '%v': %v", client.UserID, err)
+		client.SendPacket(builders.NewLogoutEverywhereResponsePacket(builders.LogoutEverywhereResultFailed))
+		return
+	}
+
+	for _, c := range s.GetClientsByID(client.UserID) {
+		if c != client {
+			c.SendPacket(builders.NewSessionRevokedPacket())
+			c.Close()
+		}
+	}
+
+	client.SendPacket(builders.NewLogoutEverywhereResponsePacket(builders.LogoutEverywhereResultSuccess))
+}
+
+// Marks the client as logged in as the user, sends the login result and notifies the users contacts.
+func completeLogin(s *server.TCPServer, client *server.Client, user *models.UserModel, sessionToken *string) {
 	var friends []models.FriendModel
 	var pendingContacts []models.PendingContactModel
 
-	if user != nil && utils.ComparePasswordHashes(password, user.Password) {
-		err := dbaccess.LoginUser(user.ID)
-		if err != nil {
-			log.Print(err.Error())
-			log.Printf("User '%v' login failed due to error.", username)
-		} else {
-			loggedIn = true
-			client.Username = user.Username
-			client.DisplayName = user.DisplayName
-			client.LoggedIn = true
-			client.UserID = user.ID
-			client.Status = 1 // Default status set from the LoginUser proc.
-			client.ImageURL = user.ImageURL
-			client.StatusText = user.StatusText
-
-			log.Printf("User '%v' logged in.", username)
-
-			f, err := dbaccess.GetFriends(user.ID)
-			if err != nil {
-				log.Printf("Failed to get friends list for user ID '%v'.", user.ID)
-			} else {
-				friends = f
-			}
+	err := store.LoginUser(user.ID)
+	if err != nil {
+		log.Print(err.Error())
+		log.Printf("User '%v' login failed due to error.", user.Username)
+		client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil))
+		return
+	}
+
+	client.Username = user.Username
+	client.SetDisplayName(user.DisplayName)
+	client.LoggedIn = true
+	s.SetClientUserID(client, user.ID)
+	client.SetImageURL(user.ImageURL)
+	client.SetStatusText(user.StatusText)
+
+	log.Printf("User '%v' logged in.", user.Username)
 
-			pc, err := dbaccess.GetUserPendingContacts(user.ID)
-			if err != nil {
-				log.Printf("Failed to get pending contacts list for user ID '%v'.", user.ID)
-			} else {
-				pendingContacts = pc
+	f, err := store.GetFriends(user.ID)
+	if err != nil {
+		log.Printf("Failed to get friends list for user ID '%v'.", user.ID)
+	} else {
+		friends = f
+
+		contactIDs := make([]int, 0, len(f))
+		for i, friend := range f {
+			contactIDs = append(contactIDs, friend.ID)
+
+			// Blocked users appear offline to each other.
+			blocked, err := roster.IsBlocked(user.ID, friend.ID)
+			if err != nil || blocked {
+				friends[i].Status = dbaccess.StatusOffline
 			}
 		}
+		roster.SetContacts(user.ID, contactIDs)
+	}
+
+	pc, err := store.GetUserPendingContacts(user.ID)
+	if err != nil {
+		log.Printf("Failed to get pending contacts list for user ID '%v'.", user.ID)
 	} else {
-		log.Printf("User '%v' login denied.", username)
+		pendingContacts = pc
 	}
 
-	if loggedIn {
-		go client.SendPacket(builders.NewLoginResultPacket(
-			loggedIn, client.UserID, client.DisplayName, user.StatusText, friends, pendingContacts))
+	// The session is tracked before the client learns it is logged in, so anything it does next counts it as one of
+	// the users sessions.
+	presenceTracker.Login(client)
 
-		// Notify this contacts friends, if they are logged in, that this user came online.
-		statusPacket := builders.NewUserStatusChangePacket(user.ID, dbaccess.StatusOnline)
-		go utils.BroadcastPacketToContacts(s, user.ID, statusPacket)
-	} else {
-		go client.SendPacket(builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil))
+	// The login result is queued before any offline messages so the client receives it first.
+	client.SendPacket(builders.NewLoginResultPacket(
+		true, client.UserID, client.DisplayName(), user.StatusText, friends, pendingContacts, sessionToken))
+
+	deliverOfflineMessages(s, client)
+
+	// Another session may already have chosen a status, otherwise contacts are told this user came online.
+	status := publishStatus(s, user.ID)
+
+	// The LoginUser proc always sets the user online, so restore the status shown for their other sessions.
+	if status != dbaccess.StatusOnline {
+		err = store.SetStatus(user.ID, status)
+		if err != nil {
+			log.Printf("Failed to restore status for user id '%v': %v", user.ID, err)
+		}
+	}
+}
+
+// Sends the chat messages that were queued while the user was offline. Each message is removed from the queue, and
+// its sender told it was delivered, once it has been written to the client. Messages that are dropped instead stay
+// queued for the next login.
+func deliverOfflineMessages(s *server.TCPServer, client *server.Client) {
+	messages, err := store.GetOfflineMessages(client.UserID)
+	if err != nil {
+		log.Printf("Failed to get offline messages for user ID '%v': %v", client.UserID, err)
+		return
+	}
+
+	for _, m := range messages {
+		m := m
+		chatPacket := builders.NewChatFromPacket(m.FromUserID, m.Message, m.SentAt, m.MessageID)
+
+		err := client.SendPacketWaitNotify(chatPacket, func(written bool) {
+			if written {
+				offlineMessageWritten(s, client.UserID, m)
+			}
+		})
+		if err != nil {
+			log.Printf("Failed to deliver offline messages for user ID '%v': %v", client.UserID, err)
+			return
+		}
+	}
+}
+
+// Removes an offline message once it has been written to the recipient. Messages are written in order, so the
+// earlier ones have been written too.
+func offlineMessageWritten(s *server.TCPServer, userID int, m models.OfflineMessageModel) {
+	err := store.DeleteOfflineMessages(userID, m.ID)
+	if err != nil {
+		log.Printf("Failed to delete offline messages for user ID '%v': %v", userID, err)
+	}
+
+	if m.MessageID != 0 {
+		s.BroadcastPacketToUserID(m.FromUserID, builders.NewMessageDeliveredPacket(userID, m.MessageID, false))
 	}
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/dbaccess"
+	"chatServer/presence"
+	"chatServer/requests"
+	"chatServer/server"
+	"chatServer/utils"
+	"context"
+	"log"
+	"time"
+)
+
+var presenceTracker = presence.NewTracker()
+
+// How often sessions are checked for inactivity.
+const idleCheckInterval = 30 * time.Second
+
+// HandleUserStatusChange handles the receipt of a user status change packet. The chosen status applies to every
+// session of the user. Asking to appear offline makes the user invisible, they stay logged in.
+func HandleUserStatusChange(s *server.TCPServer, client *server.Client, request *requests.UserStatusChange) {
+	status := request.Status
+	if status == dbaccess.StatusOffline {
+		status = dbaccess.StatusInvisible
+	}
+
+	if !presence.IsValidStatus(status) {
+		return
+	}
+
+	presenceTracker.SetStatus(client.UserID, status)
+
+	// Let the users other sessions know what they chose.
+	statusPacket := builders.NewUserStatusChangePacket(client.UserID, status)
+	for _, c := range s.GetClientsByID(client.UserID) {
+		if c != client {
+			c.SendPacket(statusPacket)
+		}
+	}
+
+	publishStatus(s, client.UserID)
+}
+
+// TouchPresence records activity from a logged in client, bringing the user back from being away if they were idle.
+func TouchPresence(s *server.TCPServer, client *server.Client) {
+	if presenceTracker.Touch(client) {
+		publishStatus(s, client.UserID)
+	}
+}
+
+// EndClientPresence removes a disconnected client from the presence tracker. Returns true if it was the users last
+// session, and whether their contacts could see them before it was removed.
+func EndClientPresence(client *server.Client) (bool, bool) {
+	last, published := presenceTracker.Logout(client)
+	return last, published != dbaccess.StatusOffline
+}
+
+// RunAwayMonitor marks users away once all of their sessions have been inactive for the specified duration. Blocks
+// until the context is cancelled.
+func RunAwayMonitor(ctx context.Context, s *server.TCPServer, awayAfter time.Duration) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, userID := range presenceTracker.CheckIdle(awayAfter) {
+				publishStatus(s, userID)
+			}
+		}
+	}
+}
+
+// Saves and broadcasts the status contacts should see for a user, if it has changed. Returns the status.
+func publishStatus(s *server.TCPServer, userID int) int {
+	status, changed := presenceTracker.Publish(userID)
+
+	if !changed {
+		return status
+	}
+
+	err := store.SetStatus(userID, status)
+	if err != nil {
+		log.Printf("Failed to set status for user id '%v': %v", userID, err)
+	}
+
+	utils.BroadcastPacketToContacts(s, userID, builders.NewUserStatusChangePacket(userID, status))
+
+	return status
+}
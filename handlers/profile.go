@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"chatServer/avatars"
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/server"
+	"chatServer/utils"
+	"log"
+)
+
+// The maximum length of a users status text.
+const maxStatusTextLength = 100
+
+var avatarStore *avatars.Store
+
+// SetAvatarStore sets where uploaded avatar images are saved.
+func SetAvatarStore(store *avatars.Store) {
+	avatarStore = store
+}
+
+// HandleSetDisplayName handles the receipt of a set display name packet.
+func HandleSetDisplayName(s *server.TCPServer, client *server.Client, request *requests.SetDisplayName) {
+	name := request.DisplayName
+
+	if !utils.IsValidDisplayName(name) {
+		client.SendPacket(builders.NewSetDisplayNameResponsePacket(builders.ProfileResultInvalid, client.DisplayName()))
+		return
+	}
+
+	err := store.SetDisplayName(client.UserID, name)
+	if err != nil {
+		log.Printf("Failed to set display name for user id '%v': %v", client.UserID, err)
+		client.SendPacket(builders.NewSetDisplayNameResponsePacket(builders.ProfileResultFailed, client.DisplayName()))
+		return
+	}
+
+	for _, c := range s.GetClientsByID(client.UserID) {
+		c.SetDisplayName(&name)
+	}
+
+	client.SendPacket(builders.NewSetDisplayNameResponsePacket(builders.ProfileResultSuccess, &name))
+
+	broadcastProfileChange(s, client)
+}
+
+// HandleSetStatusText handles the receipt of a set status text packet. A nil status text clears it.
+func HandleSetStatusText(s *server.TCPServer, client *server.Client, request *requests.SetStatusText) {
+	statusText := request.StatusText
+
+	if statusText != nil && len(*statusText) > maxStatusTextLength {
+		client.SendPacket(builders.NewSetStatusTextResponsePacket(builders.ProfileResultInvalid, client.StatusText()))
+		return
+	}
+
+	err := store.SetStatusText(client.UserID, statusText)
+	if err != nil {
+		log.Printf("Failed to set status text for user id '%v': %v", client.UserID, err)
+		client.SendPacket(builders.NewSetStatusTextResponsePacket(builders.ProfileResultFailed, client.StatusText()))
+		return
+	}
+
+	for _, c := range s.GetClientsByID(client.UserID) {
+		c.SetStatusText(statusText)
+	}
+
+	client.SendPacket(builders.NewSetStatusTextResponsePacket(builders.ProfileResultSuccess, statusText))
+
+	broadcastProfileChange(s, client)
+}
+
+// HandleSetAvatar handles the receipt of a set avatar packet. The image is a PNG, JPEG or GIF.
+func HandleSetAvatar(s *server.TCPServer, client *server.Client, request *requests.SetAvatar) {
+	if len(request.Image) == 0 {
+		client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultInvalid, client.ImageURL()))
+		return
+	}
+
+	imageURL, err := avatarStore.Save(client.UserID, request.Image)
+
+	if err == avatars.ErrTooLarge {
+		client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultTooLarge, client.ImageURL()))
+		return
+	}
+
+	if err == avatars.ErrUnsupportedType {
+		client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultInvalid, client.ImageURL()))
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to save avatar for user id '%v': %v", client.UserID, err)
+		client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultFailed, client.ImageURL()))
+		return
+	}
+
+	err = store.SetUserImage(client.UserID, imageURL)
+	if err != nil {
+		log.Printf("Failed to set avatar for user id '%v': %v", client.UserID, err)
+		avatarStore.Remove(imageURL)
+		client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultFailed, client.ImageURL()))
+		return
+	}
+
+	// The previous image is no longer used by anyone.
+	if oldImageURL := client.ImageURL(); oldImageURL != nil {
+		err = avatarStore.Remove(*oldImageURL)
+		if err != nil {
+			log.Printf("Failed to remove old avatar for user id '%v': %v", client.UserID, err)
+		}
+	}
+
+	for _, c := range s.GetClientsByID(client.UserID) {
+		c.SetImageURL(&imageURL)
+	}
+
+	client.SendPacket(builders.NewSetAvatarResponsePacket(builders.ProfileResultSuccess, &imageURL))
+
+	broadcastProfileChange(s, client)
+}
+
+// Sends the clients profile to their contacts and their other logged in sessions.
+func broadcastProfileChange(s *server.TCPServer, client *server.Client) {
+	profilePacket := builders.NewProfileChangedPacket(client.UserID, client.DisplayName(), client.StatusText(), client.ImageURL())
+
+	for _, c := range s.GetClientsByID(client.UserID) {
+		if c != client {
+			c.SendPacket(profilePacket)
+		}
+	}
+
+	utils.BroadcastPacketToContacts(s, client.UserID, profilePacket)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/server"
+	"errors"
+	"log"
+)
+
+// SendProtocolError tells a client a packet it sent was refused because it couldn't be decoded.
+func SendProtocolError(client *server.Client, packet *server.Packet, err error) {
+	reason := builders.ProtocolErrorInvalid
+
+	switch {
+	case errors.Is(err, requests.ErrUnknownPacket):
+		reason = builders.ProtocolErrorUnknownPacket
+	case errors.Is(err, requests.ErrTruncated):
+		reason = builders.ProtocolErrorTruncated
+	case errors.Is(err, requests.ErrTooLong):
+		reason = builders.ProtocolErrorTooLong
+	}
+
+	log.Printf("Refused packet from user id '%v': %v", client.UserID, err)
+
+	client.SendPacket(builders.NewProtocolErrorPacket(int(packet.ID), reason))
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/roster"
+	"chatServer/server"
+	"log"
+)
+
+// HandleReadReceipt handles the receipt of a read receipt packet, forwarding it to every session of the user that
+// sent the message. Receipts between users that are not contacts, or have blocked each other, are dropped.
+func HandleReadReceipt(s *server.TCPServer, client *server.Client, request *requests.ReadReceipt) {
+	fromUserID := request.FromUserID
+	messageID := request.MessageID
+
+	if messageID == 0 {
+		return
+	}
+
+	if !canReach(client.UserID, fromUserID) {
+		return
+	}
+
+	s.BroadcastPacketToUserID(fromUserID, builders.NewReadReceiptFromPacket(client.UserID, messageID))
+}
+
+// Checks two users are contacts that haven't blocked each other, for packets that are dropped silently otherwise.
+func canReach(fromUserID int, toUserID int) bool {
+	isContact, err := roster.IsContact(fromUserID, toUserID)
+	if err != nil {
+		log.Printf("Failed to check contact '%v' for user id '%v': %v", toUserID, fromUserID, err)
+		return false
+	}
+
+	if !isContact {
+		return false
+	}
+
+	blocked, err := roster.IsBlocked(fromUserID, toUserID)
+	if err != nil {
+		log.Printf("Failed to check block of '%v' for user id '%v': %v", toUserID, fromUserID, err)
+		return false
+	}
+
+	return !blocked
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/roster"
+	"chatServer/server"
+)
+
+// HandleRemoveContact handles the receipt of a remove contact packet.
+func HandleRemoveContact(s *server.TCPServer, client *server.Client, request *requests.RemoveContact) {
+	contactUserID := request.ContactUserID
+
+	// Is this user a contact of the client?
+	rowID, err := store.GetUserContactByContactUserID(client.UserID, contactUserID)
+	if err != nil {
+		client.SendPacket(builders.NewRemoveContactResponsePacket(builders.RemoveContactResultFailed, contactUserID))
+		return
+	}
+
+	if rowID == nil {
+		client.SendPacket(builders.NewRemoveContactResponsePacket(builders.RemoveContactResultNotContact, contactUserID))
+		return
+	}
+
+	// Do the remove.
+	err = store.RemoveContact(client.UserID, contactUserID)
+	if err != nil {
+		client.SendPacket(builders.NewRemoveContactResponsePacket(builders.RemoveContactResultFailed, contactUserID))
+		return
+	}
+
+	roster.RemoveContact(client.UserID, contactUserID)
+
+	// Every session of the client updates its contacts list, not just the one that removed the contact.
+	s.BroadcastPacketToUserID(client.UserID, builders.NewRemoveContactResponsePacket(builders.RemoveContactResultSuccess, contactUserID))
+
+	// Let the removed user update their contacts list if they are online.
+	s.BroadcastPacketToUserID(contactUserID, builders.NewContactRemovedPacket(client.UserID))
+
+	// The two users can no longer talk, so end any call and typing between them.
+	endCallsBetween(s, client.UserID, contactUserID)
+	StopTyping(s, client.UserID, contactUserID)
+	StopTyping(s, contactUserID, client.UserID)
+}
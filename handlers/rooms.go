@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/models"
+	"chatServer/requests"
+	"chatServer/roster"
+	"chatServer/server"
+	"log"
+	"time"
+)
+
+// The maximum length of a room name.
+const maxRoomNameLength = 50
+
+// HandleCreateRoom handles the receipt of a create room packet.
+func HandleCreateRoom(s *server.TCPServer, client *server.Client, request *requests.CreateRoom) {
+	name := request.Name
+
+	if !isValidRoomName(name) {
+		client.SendPacket(builders.NewCreateRoomResponsePacket(builders.RoomResultInvalidName, 0, nil))
+		return
+	}
+
+	roomID, err := store.CreateRoom(client.UserID, *name)
+	if err != nil {
+		log.Printf("Failed to create room for user id '%v': %v", client.UserID, err)
+		client.SendPacket(builders.NewCreateRoomResponsePacket(builders.RoomResultFailed, 0, nil))
+		return
+	}
+
+	// Sent to all of the users sessions so each of them shows the new room.
+	s.BroadcastPacketToUserID(client.UserID, builders.NewCreateRoomResponsePacket(builders.RoomResultSuccess, roomID, name))
+}
+
+// HandleInviteToRoom handles the receipt of an invite to room packet. Only contacts of the inviting user that neither
+// has blocked can be added.
+func HandleInviteToRoom(s *server.TCPServer, client *server.Client, request *requests.InviteToRoom) {
+	roomID := request.RoomID
+	userID := request.UserID
+
+	room, members, resultCode := getRoomForMember(roomID, client.UserID)
+	if resultCode != builders.RoomResultSuccess {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(resultCode, roomID, userID))
+		return
+	}
+
+	isContact, err := roster.IsContact(client.UserID, userID)
+	if err != nil {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultFailed, roomID, userID))
+		return
+	}
+
+	if !isContact {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultNotContact, roomID, userID))
+		return
+	}
+
+	// A block either way is refused like a user that isn't a contact, so it isn't revealed.
+	blocked, err := roster.IsBlocked(client.UserID, userID)
+	if err != nil {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultFailed, roomID, userID))
+		return
+	}
+
+	if blocked {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultNotContact, roomID, userID))
+		return
+	}
+
+	if isRoomMember(members, userID) {
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultAlreadyMember, roomID, userID))
+		return
+	}
+
+	err = store.AddRoomMember(roomID, userID)
+	if err != nil {
+		log.Printf("Failed to add user id '%v' to room '%v': %v", userID, roomID, err)
+		client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultFailed, roomID, userID))
+		return
+	}
+
+	client.SendPacket(builders.NewInviteToRoomResponsePacket(builders.RoomResultSuccess, roomID, userID))
+
+	broadcastToRoom(s, members, builders.NewRoomMemberChangePacket(roomID, userID, builders.RoomMemberJoined))
+	s.BroadcastPacketToUserID(userID, builders.NewAddedToRoomPacket(roomID, &room.Name, client.UserID))
+}
+
+// HandleLeaveRoom handles the receipt of a leave room packet.
+func HandleLeaveRoom(s *server.TCPServer, client *server.Client, request *requests.LeaveRoom) {
+	roomID := request.RoomID
+
+	_, members, resultCode := getRoomForMember(roomID, client.UserID)
+	if resultCode != builders.RoomResultSuccess {
+		client.SendPacket(builders.NewLeaveRoomResponsePacket(resultCode, roomID))
+		return
+	}
+
+	err := store.RemoveRoomMember(roomID, client.UserID)
+	if err != nil {
+		log.Printf("Failed to remove user id '%v' from room '%v': %v", client.UserID, roomID, err)
+		client.SendPacket(builders.NewLeaveRoomResponsePacket(builders.RoomResultFailed, roomID))
+		return
+	}
+
+	// Sent to all of the users sessions so they all drop the room, and to the remaining members.
+	broadcastToRoom(s, members, builders.NewRoomMemberChangePacket(roomID, client.UserID, builders.RoomMemberLeft))
+	s.BroadcastPacketToUserID(client.UserID, builders.NewLeaveRoomResponsePacket(builders.RoomResultSuccess, roomID))
+}
+
+// HandleRenameRoom handles the receipt of a rename room packet.
+func HandleRenameRoom(s *server.TCPServer, client *server.Client, request *requests.RenameRoom) {
+	roomID := request.RoomID
+	name := request.Name
+
+	if !isValidRoomName(name) {
+		client.SendPacket(builders.NewRenameRoomResponsePacket(builders.RoomResultInvalidName, roomID))
+		return
+	}
+
+	_, members, resultCode := getRoomForMember(roomID, client.UserID)
+	if resultCode != builders.RoomResultSuccess {
+		client.SendPacket(builders.NewRenameRoomResponsePacket(resultCode, roomID))
+		return
+	}
+
+	err := store.RenameRoom(roomID, *name)
+	if err != nil {
+		log.Printf("Failed to rename room '%v': %v", roomID, err)
+		client.SendPacket(builders.NewRenameRoomResponsePacket(builders.RoomResultFailed, roomID))
+		return
+	}
+
+	client.SendPacket(builders.NewRenameRoomResponsePacket(builders.RoomResultSuccess, roomID))
+	broadcastToRoom(s, members, builders.NewRoomRenamedPacket(roomID, client.UserID, name))
+}
+
+// HandleGetRoomMembers handles the receipt of a get room members packet.
+func HandleGetRoomMembers(s *server.TCPServer, client *server.Client, request *requests.GetRoomMembers) {
+	roomID := request.RoomID
+
+	_, members, resultCode := getRoomForMember(roomID, client.UserID)
+	if resultCode != builders.RoomResultSuccess {
+		client.SendPacket(builders.NewRoomMembersPacket(resultCode, roomID, nil))
+		return
+	}
+
+	client.SendPacket(builders.NewRoomMembersPacket(builders.RoomResultSuccess, roomID, members))
+}
+
+// HandleGetRooms handles the receipt of a get rooms packet.
+func HandleGetRooms(s *server.TCPServer, client *server.Client, request *requests.GetRooms) {
+	rooms, err := store.GetUserRooms(client.UserID)
+	if err != nil {
+		log.Printf("Failed to get rooms for user id '%v': %v", client.UserID, err)
+		rooms = nil
+	}
+
+	client.SendPacket(builders.NewRoomsPacket(rooms))
+}
+
+// HandleRoomMessage handles the receipt of a room message packet. The message is sent to every online member of the
+// room except the sender.
+func HandleRoomMessage(s *server.TCPServer, client *server.Client, request *requests.RoomMessage) {
+	roomID := request.RoomID
+	msg := request.Message
+
+	if msg == nil {
+		return
+	}
+
+	_, members, resultCode := getRoomForMember(roomID, client.UserID)
+	if resultCode != builders.RoomResultSuccess {
+		return
+	}
+
+	msgPacket := builders.NewRoomMessageFromPacket(roomID, client.UserID, *msg, time.Now())
+
+	for _, m := range members {
+		if m.ID != client.UserID {
+			s.BroadcastPacketToUserID(m.ID, msgPacket)
+		}
+	}
+}
+
+// Fetches a room and its members, checking the user is one of them. Returns a room result code on failure.
+func getRoomForMember(roomID int, userID int) (*models.RoomModel, []models.RoomMemberModel, int) {
+	room, err := store.GetRoomByID(roomID)
+	if err != nil {
+		log.Printf("Failed to get room '%v': %v", roomID, err)
+		return nil, nil, builders.RoomResultFailed
+	}
+
+	if room == nil {
+		return nil, nil, builders.RoomResultNotMember
+	}
+
+	members, err := store.GetRoomMembers(roomID)
+	if err != nil {
+		log.Printf("Failed to get members of room '%v': %v", roomID, err)
+		return nil, nil, builders.RoomResultFailed
+	}
+
+	if !isRoomMember(members, userID) {
+		return nil, nil, builders.RoomResultNotMember
+	}
+
+	return room, members, builders.RoomResultSuccess
+}
+
+func isRoomMember(members []models.RoomMemberModel, userID int) bool {
+	for _, m := range members {
+		if m.ID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isValidRoomName(name *string) bool {
+	return name != nil && len(*name) <= maxRoomNameLength
+}
+
+func broadcastToRoom(s *server.TCPServer, members []models.RoomMemberModel, packet *server.Packet) {
+	for _, m := range members {
+		s.BroadcastPacketToUserID(m.ID, packet)
+	}
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import "chatServer/dbaccess"
+
+// The data store the handlers read and write users, contacts and messages with.
+var store dbaccess.Store
+
+// SetStore sets the data store used by the handlers. It must be called before any packets are handled.
+func SetStore(dataStore dbaccess.Store) {
+	store = dataStore
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"chatServer/builders"
+	"chatServer/requests"
+	"chatServer/server"
+	"chatServer/typing"
+	"time"
+)
+
+// Typing started packets from a user are forwarded at most once per throttle duration, and the user is stopped if
+// their client doesn't send another within the timeout.
+const (
+	typingThrottle = 3 * time.Second
+	typingTimeout  = 10 * time.Second
+)
+
+var typingTracker *typing.Tracker
+
+// InitTypingIndicators sets up the tracking of typing users for the server, which must be done before any typing
+// packets are handled.
+func InitTypingIndicators(s *server.TCPServer) {
+	typingTracker = typing.NewTracker(typingThrottle, typingTimeout, func(fromUserID int, toUserID int) {
+		s.BroadcastPacketToUserID(toUserID, builders.NewTypingStoppedFromPacket(fromUserID))
+	})
+}
+
+// HandleTypingStarted handles the receipt of a typing started packet.
+func HandleTypingStarted(s *server.TCPServer, client *server.Client, request *requests.TypingStarted) {
+	toUserID := request.ToUserID
+
+	if !canReach(client.UserID, toUserID) {
+		return
+	}
+
+	if typingTracker.Start(client.UserID, toUserID) {
+		s.BroadcastPacketToUserID(toUserID, builders.NewTypingStartedFromPacket(client.UserID))
+	}
+}
+
+// HandleTypingStopped handles the receipt of a typing stopped packet.
+func HandleTypingStopped(s *server.TCPServer, client *server.Client, request *requests.TypingStopped) {
+	toUserID := request.ToUserID
+
+	StopTyping(s, client.UserID, toUserID)
+}
+
+// StopTyping tells the recipient a user stopped typing to them, if they were. Sending a message stops typing too.
+func StopTyping(s *server.TCPServer, fromUserID int, toUserID int) {
+	if typingTracker.Stop(fromUserID, toUserID) {
+		s.BroadcastPacketToUserID(toUserID, builders.NewTypingStoppedFromPacket(fromUserID))
+	}
+}
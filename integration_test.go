@@ -0,0 +1,911 @@
+package main
+
+import (
+	"chatServer/builders"
+	"chatServer/dbaccess"
+	"chatServer/handlers"
+	"chatServer/models"
+	"chatServer/roster"
+	"chatServer/server"
+	"chatServer/utils"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testPassword = "password"
+
+// testServer is a chat server listening on an ephemeral port, backed by an in-memory store.
+type testServer struct {
+	t      *testing.T
+	server *server.TCPServer
+	store  *dbaccess.MemoryStore
+}
+
+func startTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return startTestServerWith(t, func(serv *server.TCPServer) error {
+		return serv.Listen("127.0.0.1:0")
+	})
+}
+
+// Starts a test server that opens its port with the listen function, such as to listen for TLS connections.
+func startTestServerWith(t *testing.T, listen func(serv *server.TCPServer) error) *testServer {
+	t.Helper()
+
+	memoryStore := dbaccess.NewMemoryStore()
+
+	store = memoryStore
+	handlers.SetStore(memoryStore)
+	roster.SetStore(memoryStore)
+
+	serv := server.NewTCPServer(onHandlePacket, onClientConnect, onClientDisconnect)
+	handlers.InitTypingIndicators(serv)
+
+	if err := listen(serv); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		serv.Run(ctx)
+		close(stopped)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelShutdown()
+
+		if err := serv.Shutdown(shutdownCtx, builders.NewServerShutdownPacket()); err != nil {
+			t.Errorf("Failed to shut down: %v", err)
+		}
+	})
+
+	return &testServer{t: t, server: serv, store: memoryStore}
+}
+
+// Creates an account and returns the id of the new user.
+func (ts *testServer) createUser(username string) int {
+	ts.t.Helper()
+
+	err := ts.store.CreateAccount(username, utils.HashPassword(testPassword), username+"@example.com", username, "guid")
+	if err != nil {
+		ts.t.Fatalf("Failed to create user: %v", err)
+	}
+
+	user, _ := ts.store.GetUserByUsername(username)
+
+	return user.ID
+}
+
+// Makes two users contacts of each other without going through a contact request.
+func (ts *testServer) makeContacts(userID int, otherUserID int) {
+	ts.t.Helper()
+
+	ts.store.AddPendingContact(userID, otherUserID, nil)
+	if err := ts.store.ConfirmContactRequest(userID, otherUserID); err != nil {
+		ts.t.Fatalf("Failed to make contacts: %v", err)
+	}
+}
+
+// Connects a client and logs in as the specified user.
+func (ts *testServer) login(username string) *testClient {
+	ts.t.Helper()
+
+	c := dialTestClient(ts.t, ts.server.Addr().String())
+
+	if result := c.login(username, testPassword); !result.Bool("Success") {
+		ts.t.Fatalf("Expected '%v' to log in.", username)
+	}
+
+	return c
+}
+
+func TestLogin(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	c := dialTestClient(t, ts.server.Addr().String())
+
+	if result := c.login("alice", "wrong"); result.Bool("Success") {
+		t.Fatalf("Expected the wrong password to be denied.")
+	}
+
+	result := c.login("alice", testPassword)
+	if !result.Bool("Success") || result.Int("UserID") != alice || result.String("DisplayName") != "alice" {
+		t.Fatalf("Unexpected login result %v.", result.values)
+	}
+
+	if result.String("SessionToken") == "" {
+		t.Errorf("Expected a session token.")
+	}
+
+	friends := result.List("Friends")
+	if len(friends) != 1 || friends[0].Int("ID") != bob || friends[0].Int("Status") != dbaccess.StatusOffline {
+		t.Errorf("Unexpected friends %v.", friends)
+	}
+
+	// The session token logs in on another connection.
+	other := dialTestClient(t, ts.server.Addr().String())
+	other.send(builders.PacketIDTokenLogin, []byte(result.String("SessionToken")))
+
+	if tokenResult := other.waitFor(builders.PacketIDLoginResult); tokenResult.Int("UserID") != alice {
+		t.Errorf("Unexpected token login result %v.", tokenResult.values)
+	}
+}
+
+// A store that can't look up users, as when the database is unavailable.
+type failingUserStore struct {
+	dbaccess.Store
+}
+
+func (f *failingUserStore) GetUserByUsername(username string) (*models.UserModel, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestLoginStoreError(t *testing.T) {
+	ts := startTestServer(t)
+	ts.createUser("alice")
+
+	handlers.SetStore(&failingUserStore{Store: ts.store})
+
+	c := dialTestClient(t, ts.server.Addr().String())
+
+	if result := c.login("alice", testPassword); result.Bool("Success") {
+		t.Fatalf("Expected the login to fail.")
+	}
+
+	// The server is still running after the error.
+	handlers.SetStore(ts.store)
+
+	if result := c.login("alice", testPassword); !result.Bool("Success") {
+		t.Errorf("Expected the login to succeed, got %v.", result.values)
+	}
+}
+
+// Writes a self-signed certificate for 127.0.0.1 and its key to a temporary directory, returning their paths.
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "chatServer test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "test.crt")
+	keyFile := filepath.Join(dir, "test.key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestLoginOverTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+
+	ts := startTestServerWith(t, func(serv *server.TCPServer) error {
+		return serv.ListenTLS("127.0.0.1:0", certFile, keyFile, true)
+	})
+	alice := ts.createUser("alice")
+
+	conn, err := tls.Dial("tcp", ts.server.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	c := newTestClient(t, conn)
+
+	if result := c.login("alice", testPassword); !result.Bool("Success") || result.Int("UserID") != alice {
+		t.Errorf("Unexpected login result %v.", result.values)
+	}
+
+	// TLS is required, so a plaintext client is disconnected without an answer.
+	plain := dialTestClient(t, ts.server.Addr().String())
+	plain.send(builders.PacketIDLogin, []byte("alice\n"+testPassword))
+
+	if result, err := plain.next(builders.PacketIDLoginResult, testPacketTimeout); err == nil {
+		t.Errorf("Expected the plaintext client to be disconnected, got %v.", result.values)
+	}
+}
+
+func TestLoginOverWebSocket(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	httpServer := httptest.NewServer(ts.server.WebSocketHandler())
+	t.Cleanup(httpServer.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	c := newTestClient(t, &wsTestConn{ws: ws})
+
+	if result := c.login("alice", testPassword); !result.Bool("Success") || result.Int("UserID") != alice {
+		t.Fatalf("Unexpected login result %v.", result.values)
+	}
+
+	// WebSocket and TCP clients talk to each other like any others.
+	bobClient := ts.login("bob")
+	bobClient.send(builders.PacketIDChat, alice, "hi over ws")
+
+	if chat := c.waitFor(builders.PacketIDChatFrom); chat.Int("FromUserID") != bob || chat.String("Message") != "hi over ws" {
+		t.Errorf("Unexpected chat %v.", chat.values)
+	}
+}
+
+func TestContactRequests(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	carol := ts.createUser("carol")
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+	carolClient := ts.login("carol")
+
+	aliceClient.send(builders.PacketIDAddContact, "bob", "Hi Bob")
+
+	if response := aliceClient.waitFor(builders.PacketIDAddContactResponse); response.Int("ResultCode") != builders.AddContactResultSuccess {
+		t.Fatalf("Unexpected add contact response %v.", response.values)
+	}
+
+	request := bobClient.waitFor(builders.PacketIDNotifyAddRequest)
+	if request.Int("UserID") != alice || request.String("Username") != "alice" || request.String("Message") != "Hi Bob" {
+		t.Fatalf("Unexpected add request %v.", request.values)
+	}
+
+	aliceClient.send(builders.PacketIDAddContact, "bob", "Again")
+	if response := aliceClient.waitFor(builders.PacketIDAddContactResponse); response.Int("ResultCode") != builders.AddContactResultUserAlreadyPending {
+		t.Errorf("Unexpected add contact response %v.", response.values)
+	}
+
+	bobClient.send(builders.PacketIDConfirmContact, alice)
+
+	confirmed := bobClient.waitFor(builders.PacketIDConfirmContactResponse)
+	if confirmed.Int("ResultCode") != builders.ConfirmContactResultSuccess || confirmed.String("Username") != "alice" {
+		t.Fatalf("Unexpected confirm response %v.", confirmed.values)
+	}
+
+	accepted := aliceClient.waitFor(builders.PacketIDAddContactAccepted)
+	if accepted.Int("UserID") != bob || accepted.Int("Status") != dbaccess.StatusOnline {
+		t.Errorf("Unexpected accepted packet %v.", accepted.values)
+	}
+
+	// Carol's request is rejected, and can't be rejected twice.
+	carolClient.send(builders.PacketIDAddContact, "bob", "")
+	bobClient.waitFor(builders.PacketIDNotifyAddRequest)
+
+	bobClient.send(builders.PacketIDRejectContact, carol)
+	if rejected := bobClient.waitFor(builders.PacketIDRejectContactResponse); rejected.Int("ResultCode") != builders.RejectContactResultSuccess {
+		t.Errorf("Unexpected reject response %v.", rejected.values)
+	}
+
+	bobClient.send(builders.PacketIDRejectContact, carol)
+	if rejected := bobClient.waitFor(builders.PacketIDRejectContactResponse); rejected.Int("ResultCode") != builders.RejectContactResultNotPending {
+		t.Errorf("Unexpected reject response %v.", rejected.values)
+	}
+
+	if friends, _ := ts.store.GetFriends(bob); len(friends) != 1 || friends[0].ID != alice {
+		t.Errorf("Unexpected friends %+v.", friends)
+	}
+}
+
+func TestChatRouting(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	carol := ts.createUser("carol")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+	bobOther := ts.login("bob")
+
+	// Bob coming online, so it isn't mistaken for bob going offline below.
+	aliceClient.waitFor(builders.PacketIDUserStatusChange)
+
+	aliceClient.send(builders.PacketIDChat, bob, "Hello", 1)
+
+	for _, c := range []*testClient{bobClient, bobOther} {
+		chat := c.waitFor(builders.PacketIDChatFrom)
+		if chat.Int("FromUserID") != alice || chat.String("Message") != "Hello" || chat.Int("MessageID") != 1 {
+			t.Errorf("Unexpected chat %v.", chat.values)
+		}
+	}
+
+	delivered := aliceClient.waitFor(builders.PacketIDMessageDelivered)
+	if delivered.Int("ToUserID") != bob || delivered.Int("MessageID") != 1 || delivered.Bool("Queued") {
+		t.Errorf("Unexpected delivered receipt %v.", delivered.values)
+	}
+
+	// Only contacts can be messaged.
+	aliceClient.send(builders.PacketIDChat, carol, "Hello", 2)
+
+	failed := aliceClient.waitFor(builders.PacketIDDeliveryFailed)
+	if failed.Int("ToUserID") != carol || failed.Int("Reason") != builders.DeliveryFailedReasonNotContact {
+		t.Errorf("Unexpected delivery failure %v.", failed.values)
+	}
+
+	// Messages to offline contacts are queued until they log in.
+	bobClient.close()
+	bobOther.close()
+
+	if change := aliceClient.waitFor(builders.PacketIDUserStatusChange); change.Int("Status") != dbaccess.StatusOffline {
+		t.Fatalf("Expected bob to go offline, got %v.", change.values)
+	}
+
+	aliceClient.send(builders.PacketIDChat, bob, "Later", 3)
+
+	if queued := aliceClient.waitFor(builders.PacketIDMessageDelivered); !queued.Bool("Queued") {
+		t.Errorf("Expected the message to be queued, got %v.", queued.values)
+	}
+
+	bobClient = ts.login("bob")
+
+	if chat := bobClient.waitFor(builders.PacketIDChatFrom); chat.String("Message") != "Later" {
+		t.Errorf("Unexpected chat %v.", chat.values)
+	}
+
+	if delivered := aliceClient.waitFor(builders.PacketIDMessageDelivered); delivered.Int("MessageID") != 3 || delivered.Bool("Queued") {
+		t.Errorf("Unexpected delivered receipt %v.", delivered.values)
+	}
+
+	// The receipt is only sent once the message has been written and removed from the queue.
+	if messages, _ := ts.store.GetOfflineMessages(bob); len(messages) != 0 {
+		t.Errorf("Expected the offline messages to be removed, got %v.", messages)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	carol := ts.createUser("carol")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	aliceClient.send(builders.PacketIDCreateRoom, strings.Repeat("a", 51))
+
+	if invalid := aliceClient.waitFor(builders.PacketIDCreateRoomResponse); invalid.Int("ResultCode") != builders.RoomResultInvalidName {
+		t.Errorf("Expected the long name to be refused, got %v.", invalid.values)
+	}
+
+	aliceClient.send(builders.PacketIDCreateRoom, "team")
+
+	created := aliceClient.waitFor(builders.PacketIDCreateRoomResponse)
+	if created.Int("ResultCode") != builders.RoomResultSuccess || created.String("Name") != "team" {
+		t.Fatalf("Unexpected create room response %v.", created.values)
+	}
+
+	roomID := created.Int("RoomID")
+
+	// Only contacts can be invited, and only by members.
+	aliceClient.send(builders.PacketIDInviteToRoom, roomID, carol)
+
+	if invited := aliceClient.waitFor(builders.PacketIDInviteToRoomResponse); invited.Int("ResultCode") != builders.RoomResultNotContact {
+		t.Errorf("Expected carol to be refused, got %v.", invited.values)
+	}
+
+	bobClient.send(builders.PacketIDInviteToRoom, roomID, alice)
+
+	if invited := bobClient.waitFor(builders.PacketIDInviteToRoomResponse); invited.Int("ResultCode") != builders.RoomResultNotMember {
+		t.Errorf("Expected bob to be refused, got %v.", invited.values)
+	}
+
+	aliceClient.send(builders.PacketIDInviteToRoom, roomID, bob)
+
+	if invited := aliceClient.waitFor(builders.PacketIDInviteToRoomResponse); invited.Int("ResultCode") != builders.RoomResultSuccess {
+		t.Fatalf("Unexpected invite response %v.", invited.values)
+	}
+
+	joined := aliceClient.waitFor(builders.PacketIDRoomMemberChange)
+	if joined.Int("UserID") != bob || joined.Int("Change") != builders.RoomMemberJoined {
+		t.Errorf("Unexpected member change %v.", joined.values)
+	}
+
+	added := bobClient.waitFor(builders.PacketIDAddedToRoom)
+	if added.Int("RoomID") != roomID || added.String("Name") != "team" || added.Int("InvitedByUserID") != alice {
+		t.Errorf("Unexpected added to room %v.", added.values)
+	}
+
+	aliceClient.send(builders.PacketIDInviteToRoom, roomID, bob)
+
+	if invited := aliceClient.waitFor(builders.PacketIDInviteToRoomResponse); invited.Int("ResultCode") != builders.RoomResultAlreadyMember {
+		t.Errorf("Expected bob to already be a member, got %v.", invited.values)
+	}
+
+	bobClient.send(builders.PacketIDGetRoomMembers, roomID)
+
+	if members := bobClient.waitFor(builders.PacketIDRoomMembers); len(members.List("Members")) != 2 {
+		t.Errorf("Expected 2 members, got %v.", members.values)
+	}
+
+	// Room messages go to the other members, not back to the sender.
+	bobClient.send(builders.PacketIDRoomMessage, roomID, "hello room")
+
+	message := aliceClient.waitFor(builders.PacketIDRoomMessageFrom)
+	if message.Int("RoomID") != roomID || message.Int("FromUserID") != bob || message.String("Message") != "hello room" {
+		t.Errorf("Unexpected room message %v.", message.values)
+	}
+
+	bobClient.expectNone(builders.PacketIDRoomMessageFrom, 100*time.Millisecond)
+
+	aliceClient.send(builders.PacketIDRenameRoom, roomID, "crew")
+
+	if renamed := aliceClient.waitFor(builders.PacketIDRenameRoomResponse); renamed.Int("ResultCode") != builders.RoomResultSuccess {
+		t.Errorf("Unexpected rename response %v.", renamed.values)
+	}
+
+	renamed := bobClient.waitFor(builders.PacketIDRoomRenamed)
+	if renamed.Int("ByUserID") != alice || renamed.String("Name") != "crew" {
+		t.Errorf("Unexpected room renamed %v.", renamed.values)
+	}
+
+	bobClient.send(builders.PacketIDLeaveRoom, roomID)
+
+	if left := bobClient.waitFor(builders.PacketIDLeaveRoomResponse); left.Int("ResultCode") != builders.RoomResultSuccess {
+		t.Errorf("Unexpected leave response %v.", left.values)
+	}
+
+	left := aliceClient.waitFor(builders.PacketIDRoomMemberChange)
+	if left.Int("UserID") != bob || left.Int("Change") != builders.RoomMemberLeft {
+		t.Errorf("Unexpected member change %v.", left.values)
+	}
+
+	// Bob is no longer a member, so bobs messages go nowhere.
+	bobClient.send(builders.PacketIDRoomMessage, roomID, "still here?")
+	aliceClient.expectNone(builders.PacketIDRoomMessageFrom, 100*time.Millisecond)
+
+	bobClient.send(builders.PacketIDGetRoomMembers, roomID)
+
+	if members := bobClient.waitFor(builders.PacketIDRoomMembers); members.Int("ResultCode") != builders.RoomResultNotMember {
+		t.Errorf("Expected bob not to be a member, got %v.", members.values)
+	}
+}
+
+func TestRoomInviteBlocked(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	aliceClient.send(builders.PacketIDBlockUser, bob)
+	aliceClient.waitFor(builders.PacketIDBlockUserResponse)
+
+	bobClient.send(builders.PacketIDCreateRoom, "team")
+	roomID := bobClient.waitFor(builders.PacketIDCreateRoomResponse).Int("RoomID")
+
+	// The blocked user can't pull the blocking user into a room.
+	bobClient.send(builders.PacketIDInviteToRoom, roomID, alice)
+
+	if invited := bobClient.waitFor(builders.PacketIDInviteToRoomResponse); invited.Int("ResultCode") != builders.RoomResultNotContact {
+		t.Errorf("Expected the invite to be refused, got %v.", invited.values)
+	}
+
+	aliceClient.expectNone(builders.PacketIDAddedToRoom, 100*time.Millisecond)
+}
+
+func TestHistoryPaging(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	// Three large images only fit in history pages two at a time.
+	image := strings.Repeat("x", 1500*1000)
+	for i := 0; i < 3; i++ {
+		ts.store.AddMessage(bob, alice, dbaccess.MessageTypeImage, &image, time.Now())
+	}
+
+	aliceClient := ts.login("alice")
+	aliceClient.send(builders.PacketIDGetHistory, bob, 0)
+
+	messages := aliceClient.waitFor(builders.PacketIDHistoryResult).List("Messages")
+	if len(messages) != 2 || messages[0].Int("ID") <= messages[1].Int("ID") {
+		t.Fatalf("Expected the newest two messages, got %v.", len(messages))
+	}
+
+	oldestID := messages[1].Int("ID")
+	aliceClient.send(builders.PacketIDGetHistory, bob, oldestID)
+
+	messages = aliceClient.waitFor(builders.PacketIDHistoryResult).List("Messages")
+	if len(messages) != 1 || messages[0].Int("ID") >= oldestID || messages[0].String("Content") != image {
+		t.Errorf("Expected the oldest message, got %v.", len(messages))
+	}
+}
+
+func TestStatusBroadcast(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	expectStatus := func(status int) {
+		t.Helper()
+
+		change := aliceClient.waitFor(builders.PacketIDUserStatusChange)
+		if change.Int("UserID") != bob || change.Int("Status") != status {
+			t.Fatalf("Expected bob to be %v, got %v.", status, change.values)
+		}
+	}
+
+	expectStatus(dbaccess.StatusOnline)
+
+	bobClient.send(builders.PacketIDUserStatusChange, dbaccess.StatusBusy)
+	expectStatus(dbaccess.StatusBusy)
+
+	// Invisible users appear offline.
+	bobClient.send(builders.PacketIDUserStatusChange, dbaccess.StatusInvisible)
+	expectStatus(dbaccess.StatusOffline)
+
+	if user, _ := ts.store.GetUserByID(bob); user.Status != dbaccess.StatusOffline {
+		t.Errorf("Expected bob to be stored as offline, got %v.", user.Status)
+	}
+
+	bobClient.send(builders.PacketIDUserStatusChange, dbaccess.StatusOnline)
+	expectStatus(dbaccess.StatusOnline)
+}
+
+func TestMultiLoginDisconnect(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobFirst := ts.login("bob")
+
+	if change := aliceClient.waitFor(builders.PacketIDUserStatusChange); change.Int("Status") != dbaccess.StatusOnline {
+		t.Fatalf("Unexpected status change %v.", change.values)
+	}
+
+	// A second session keeps the status chosen on the first.
+	bobFirst.send(builders.PacketIDUserStatusChange, dbaccess.StatusAway)
+	aliceClient.waitFor(builders.PacketIDUserStatusChange)
+
+	bobSecond := ts.login("bob")
+	aliceClient.expectNone(builders.PacketIDUserStatusChange, 200*time.Millisecond)
+
+	if user, _ := ts.store.GetUserByID(bob); user.Status != dbaccess.StatusAway {
+		t.Errorf("Expected bob to stay away, got %v.", user.Status)
+	}
+
+	// Bob stays online until his last session disconnects.
+	bobFirst.close()
+	aliceClient.expectNone(builders.PacketIDUserStatusChange, 200*time.Millisecond)
+
+	bobSecond.close()
+
+	change := aliceClient.waitFor(builders.PacketIDUserStatusChange)
+	if change.Int("UserID") != bob || change.Int("Status") != dbaccess.StatusOffline {
+		t.Errorf("Expected bob to go offline, got %v.", change.values)
+	}
+
+	if user, _ := ts.store.GetUserByID(bob); user.Status != dbaccess.StatusOffline {
+		t.Errorf("Expected bob to be stored as offline, got %v.", user.Status)
+	}
+}
+
+func TestCallEndsWhenCalleeDisconnects(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	aliceClient.send(builders.PacketIDCallInvite, bob)
+
+	response := aliceClient.waitFor(builders.PacketIDCallInviteResponse)
+	if response.Int("ResultCode") != builders.CallResultSuccess {
+		t.Fatalf("Unexpected invite response %v.", response.values)
+	}
+
+	bobClient.waitFor(builders.PacketIDCallIncoming)
+	bobClient.close()
+
+	ended := aliceClient.waitFor(builders.PacketIDCallStateChange)
+	if ended.Int("CallID") != response.Int("CallID") || ended.Int("State") != builders.CallStateEnded {
+		t.Errorf("Expected the ringing call to end, got %v.", ended.values)
+	}
+
+	// Alice is no longer in a call, bob is just offline.
+	aliceClient.send(builders.PacketIDCallInvite, bob)
+
+	if response := aliceClient.waitFor(builders.PacketIDCallInviteResponse); response.Int("ResultCode") != builders.CallResultUserOffline {
+		t.Errorf("Expected bob to be offline, got %v.", response.values)
+	}
+}
+
+func TestCallInviteToBlockingUser(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	bobClient.send(builders.PacketIDBlockUser, alice)
+	bobClient.waitFor(builders.PacketIDBlockUserResponse)
+
+	aliceClient.send(builders.PacketIDCallInvite, bob)
+
+	// The block looks like bob isn't a contact, and bobs client doesn't ring.
+	response := aliceClient.waitFor(builders.PacketIDCallInviteResponse)
+	if response.Int("ResultCode") != builders.CallResultNotContact {
+		t.Errorf("Expected not contact, got %v.", response.values)
+	}
+
+	bobClient.expectNone(builders.PacketIDCallIncoming, 100*time.Millisecond)
+}
+
+func TestBlockEndsCall(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	aliceClient.send(builders.PacketIDCallInvite, bob)
+
+	response := aliceClient.waitFor(builders.PacketIDCallInviteResponse)
+	if response.Int("ResultCode") != builders.CallResultSuccess {
+		t.Fatalf("Unexpected invite response %v.", response.values)
+	}
+
+	bobClient.waitFor(builders.PacketIDCallIncoming)
+	bobClient.send(builders.PacketIDCallAccept, response.Int("CallID"))
+	aliceClient.waitFor(builders.PacketIDCallStateChange)
+
+	aliceClient.send(builders.PacketIDTypingStarted, bob)
+	bobClient.waitFor(builders.PacketIDTypingStartedFrom)
+
+	bobClient.send(builders.PacketIDBlockUser, alice)
+	bobClient.waitFor(builders.PacketIDBlockUserResponse)
+
+	ended := aliceClient.waitFor(builders.PacketIDCallStateChange)
+	if ended.Int("CallID") != response.Int("CallID") || ended.Int("State") != builders.CallStateEnded {
+		t.Errorf("Expected the call to end, got %v.", ended.values)
+	}
+
+	if stopped := bobClient.waitFor(builders.PacketIDTypingStoppedFrom); stopped.Int("FromUserID") != alice {
+		t.Errorf("Expected alice to stop typing, got %v.", stopped.values)
+	}
+
+	// Audio from alice no longer reaches bob.
+	aliceClient.send(builders.PacketIDAudio, []byte{1, 2, 3})
+	bobClient.expectNone(builders.PacketIDAudio, 100*time.Millisecond)
+}
+
+func TestRemoveContactEndsCall(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	aliceClient := ts.login("alice")
+	aliceOther := ts.login("alice")
+	bobClient := ts.login("bob")
+
+	aliceClient.send(builders.PacketIDCallInvite, bob)
+
+	response := aliceClient.waitFor(builders.PacketIDCallInviteResponse)
+	if response.Int("ResultCode") != builders.CallResultSuccess {
+		t.Fatalf("Unexpected invite response %v.", response.values)
+	}
+
+	bobClient.waitFor(builders.PacketIDCallIncoming)
+	bobClient.send(builders.PacketIDCallAccept, response.Int("CallID"))
+	aliceClient.waitFor(builders.PacketIDCallStateChange)
+
+	bobClient.send(builders.PacketIDTypingStarted, alice)
+	aliceClient.waitFor(builders.PacketIDTypingStartedFrom)
+
+	aliceClient.send(builders.PacketIDRemoveContact, bob)
+
+	// Both of alices sessions see the contact go.
+	for _, c := range []*testClient{aliceClient, aliceOther} {
+		removed := c.waitFor(builders.PacketIDRemoveContactResponse)
+		if removed.Int("ResultCode") != builders.RemoveContactResultSuccess || removed.Int("UserID") != bob {
+			t.Errorf("Unexpected remove contact response %v.", removed.values)
+		}
+	}
+
+	bobClient.waitFor(builders.PacketIDContactRemoved)
+
+	ended := bobClient.waitFor(builders.PacketIDCallStateChange)
+	if ended.Int("CallID") != response.Int("CallID") || ended.Int("State") != builders.CallStateEnded {
+		t.Errorf("Expected the call to end, got %v.", ended.values)
+	}
+
+	if stopped := aliceClient.waitFor(builders.PacketIDTypingStoppedFrom); stopped.Int("FromUserID") != bob {
+		t.Errorf("Expected bob to stop typing, got %v.", stopped.values)
+	}
+}
+
+func TestMalformedPackets(t *testing.T) {
+	ts := startTestServer(t)
+	alice := ts.createUser("alice")
+	bob := ts.createUser("bob")
+	ts.makeContacts(alice, bob)
+
+	c := dialTestClient(t, ts.server.Addr().String())
+
+	// Credentials without a newline between the username and password.
+	c.send(builders.PacketIDLogin, []byte("alice"))
+
+	refused := c.waitFor(builders.PacketIDProtocolError)
+	if refused.Int("PacketID") != builders.PacketIDLogin || refused.Int("Reason") != builders.ProtocolErrorInvalid {
+		t.Errorf("Unexpected protocol error %v.", refused.values)
+	}
+
+	c.login("alice", testPassword)
+	bobClient := ts.login("bob")
+
+	// A message length longer than the rest of the packet.
+	c.send(builders.PacketIDChat, bob, 100, []byte("short"))
+
+	refused = c.waitFor(builders.PacketIDProtocolError)
+	if refused.Int("PacketID") != builders.PacketIDChat || refused.Int("Reason") != builders.ProtocolErrorTruncated {
+		t.Errorf("Unexpected protocol error %v.", refused.values)
+	}
+
+	c.send(1000)
+
+	refused = c.waitFor(builders.PacketIDProtocolError)
+	if refused.Int("PacketID") != 1000 || refused.Int("Reason") != builders.ProtocolErrorUnknownPacket {
+		t.Errorf("Unexpected protocol error %v.", refused.values)
+	}
+
+	bobClient.expectNone(builders.PacketIDChatFrom, 100*time.Millisecond)
+
+	// The connection is still usable after refused packets.
+	c.send(builders.PacketIDChat, bob, "Hello", 1)
+
+	if chat := bobClient.waitFor(builders.PacketIDChatFrom); chat.String("Message") != "Hello" {
+		t.Errorf("Unexpected chat %v.", chat.values)
+	}
+}
+
+// Every packet the builders create must decode with the test client layouts, without any bytes left over.
+func TestDecodeBuilders(t *testing.T) {
+	name := "name"
+	user := &models.UserModel{ID: 1, Username: "alice", DisplayName: &name}
+	sentAt := time.Now()
+
+	packets := []*server.Packet{
+		builders.NewLoginResultPacket(true, 1, &name, nil,
+			[]models.FriendModel{{ID: 2, Username: "bob"}},
+			[]models.PendingContactModel{{ID: 3, Username: "carol", Message: &name}}, &name),
+		builders.NewLoginResultPacket(false, 0, nil, nil, nil, nil, nil),
+		builders.NewUserStatusChangePacket(1, dbaccess.StatusBusy),
+		builders.NewChatFromPacket(1, "hi", sentAt, 5),
+		builders.NewActionFromPacket(1, "waves"),
+		builders.NewNudgeFromPacket(1),
+		builders.NewAddContactResponsePacket(builders.AddContactResultSuccess),
+		builders.NewNotifyAddRequestPacket(1, &user.Username, &name, nil),
+		builders.NewConfirmContactResponsePacket(builders.ConfirmContactResultSuccess, 1, user),
+		builders.NewConfirmContactResponsePacket(builders.ConfirmContactResultFailed, 1, nil),
+		builders.NewRejectContactResponsePacket(builders.RejectContactResultSuccess, 1),
+		builders.NewAddContactAcceptedPacket(1, &user.Username, &name, dbaccess.StatusOnline, nil, nil),
+		builders.NewImageFromPacket(1, &name),
+		builders.NewHistoryResultPacket(1, []models.MessageModel{{ID: 1, FromUserID: 1, Content: &name, SentAt: sentAt}}),
+		builders.NewDeliveryFailedPacket(1, builders.PacketIDChat, builders.DeliveryFailedReasonBlocked),
+		builders.NewCreateRoomResponsePacket(builders.RoomResultSuccess, 1, &name),
+		builders.NewInviteToRoomResponsePacket(builders.RoomResultSuccess, 1, 2),
+		builders.NewAddedToRoomPacket(1, &name, 2),
+		builders.NewLeaveRoomResponsePacket(builders.RoomResultSuccess, 1),
+		builders.NewRenameRoomResponsePacket(builders.RoomResultSuccess, 1),
+		builders.NewRoomRenamedPacket(1, 2, &name),
+		builders.NewRoomMembersPacket(builders.RoomResultSuccess, 1, []models.RoomMemberModel{{ID: 1, Username: "alice"}}),
+		builders.NewRoomMemberChangePacket(1, 2, builders.RoomMemberJoined),
+		builders.NewRoomMessageFromPacket(1, 2, "hi", sentAt),
+		builders.NewRoomsPacket([]models.RoomModel{{ID: 1, Name: "room"}}),
+		builders.NewCallInviteResponsePacket(builders.CallResultSuccess, 1, 2),
+		builders.NewCallIncomingPacket(1, 2),
+		builders.NewCallStateChangePacket(1, 2, builders.CallStateEnded),
+		builders.NewLogoutEverywhereResponsePacket(builders.LogoutEverywhereResultSuccess),
+		builders.NewSessionRevokedPacket(),
+		builders.NewServerShutdownPacket(),
+		builders.NewRemoveContactResponsePacket(builders.RemoveContactResultSuccess, 1),
+		builders.NewContactRemovedPacket(1),
+		builders.NewBlockUserResponsePacket(builders.BlockResultSuccess, 1),
+		builders.NewUnblockUserResponsePacket(builders.BlockResultSuccess, 1),
+		builders.NewBlockedUsersPacket([]models.BlockedUserModel{{ID: 1, Username: "alice"}}),
+		builders.NewSetDisplayNameResponsePacket(builders.ProfileResultSuccess, &name),
+		builders.NewProfileChangedPacket(1, &name, nil, &name),
+		builders.NewSetStatusTextResponsePacket(builders.ProfileResultSuccess, &name),
+		builders.NewSetAvatarResponsePacket(builders.ProfileResultSuccess, &name),
+		builders.NewMessageDeliveredPacket(1, 2, true),
+		builders.NewReadReceiptFromPacket(1, 2),
+		builders.NewTypingStartedFromPacket(1),
+		builders.NewTypingStoppedFromPacket(1),
+		builders.NewPingPacket(1),
+		builders.NewPongPacket(1),
+		builders.NewProtocolErrorPacket(builders.PacketIDChat, builders.ProtocolErrorTruncated),
+	}
+
+	for _, p := range packets {
+		data := []byte{}
+		if p.Data != nil {
+			data = *p.Data
+		}
+
+		if _, err := decodePacket(p.ID, data); err != nil {
+			t.Errorf("Failed to decode: %v", err)
+		}
+	}
+
+	// Data that ends between fields isn't a valid packet.
+	status := builders.NewUserStatusChangePacket(1, dbaccess.StatusBusy)
+	if _, err := decodePacket(status.ID, (*status.Data)[:4]); err == nil {
+		t.Errorf("Expected decoding a truncated packet to fail.")
+	}
+}
@@ -1,19 +1,37 @@
 package main
 
 import (
-	"bytes"
+	"chatServer/avatars"
 	"chatServer/builders"
+	"chatServer/config"
 	"chatServer/dbaccess"
 	"chatServer/handlers"
+	"chatServer/requests"
+	"chatServer/roster"
 	"chatServer/server"
 	"chatServer/utils"
+	"context"
+	"flag"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	logFile, err := os.OpenFile("log.txt", os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
+	cfg, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	logFile, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
 	if err != nil {
 		panic(err)
 	}
@@ -22,132 +40,373 @@ func main() {
 	log.SetOutput(mw)
 
 	log.Println("Connecting to database...")
-	dbaccess.OpenConnection()
-	defer dbaccess.CloseConnection()
+	store, err = openStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %v database: %v", cfg.Database, err)
+	}
+	defer store.Close()
 
 	log.Println("Connected.")
 
-	log.Println("Updating user statuses...")
-	dbaccess.ResetUserStatuses()
+	handlers.SetStore(store)
+	roster.SetStore(store)
 
-	localTesting := true
-
-	if !localTesting {
-		go RunWebServer()
-	}
+	log.Println("Updating user statuses...")
+	store.ResetUserStatuses()
 
 	serv := server.NewTCPServer(
 		onHandlePacket,
 		onClientConnect,
 		onClientDisconnect)
 
+	slowConsumerPolicy := server.SlowConsumerDisconnect
+	if cfg.SlowConsumer == "drop" {
+		slowConsumerPolicy = server.SlowConsumerDrop
+	}
+
+	serv.Configure(server.Settings{
+		ReadTimeout:        cfg.ReadTimeout,
+		WriteTimeout:       cfg.WriteTimeout,
+		MaxPacketLength:    cfg.MaxPacketLength,
+		SendQueueLength:    cfg.SendQueueLength,
+		SlowConsumerPolicy: slowConsumerPolicy,
+	})
+
+	handlers.InitTypingIndicators(serv)
+
+	avatarStore := avatars.NewStore(cfg.AvatarDir, cfg.AvatarURL, cfg.MaxAvatarSize)
+	handlers.SetAvatarStore(avatarStore)
+
+	if !cfg.LocalTesting {
+		go RunWebServer(serv, avatarStore, cfg)
+	}
+
 	log.Println("Starting chat server...")
-	serv.Listen(":5035")
-	serv.Run()
+	if cfg.LocalTesting {
+		err = serv.Listen(cfg.ChatAddress)
+	} else {
+		err = serv.ListenTLS(cfg.ChatAddress, cfg.CertFile, cfg.KeyFile, cfg.RequireTLS)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to start chat server: %v", err)
+	}
+
+	// Stop accepting clients when asked to terminate.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, shutting down...", sig)
+		cancel()
+	}()
+
+	// The background loops stop with the context, and are waited for before the store is closed.
+	background := &sync.WaitGroup{}
+	runInBackground := func(loop func()) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			loop()
+		}()
+	}
+
+	runInBackground(func() { handlers.RunAwayMonitor(ctx, serv, cfg.AwayAfter) })
+	runInBackground(func() { logQueueStats(ctx, serv) })
+	runInBackground(func() { handlers.RunHeartbeat(ctx, serv, cfg.PingInterval, cfg.PingTimeout) })
+
+	serv.Run(ctx)
+	background.Wait()
+
+	// Disconnect everyone, which logs out each user as their last client is removed.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	err = serv.Shutdown(shutdownCtx, builders.NewServerShutdownPacket())
+	if err != nil {
+		log.Printf("Timed out waiting for clients to disconnect: %v", err)
+
+		// Make sure nobody is left showing online.
+		store.ResetUserStatuses()
+	}
+
+	log.Println("Chat server stopped.")
+}
+
+// The data store used by the server.
+var store dbaccess.Store
+
+// Opens the data store selected in the config.
+func openStore(cfg *config.Config) (dbaccess.Store, error) {
+	switch cfg.Database {
+	case "sqlite":
+		return dbaccess.NewSQLiteStore(cfg.DatabaseDSN)
+	case "memory":
+		return dbaccess.NewMemoryStore(), nil
+	}
+
+	return dbaccess.NewMySQLStore(cfg.DatabaseDSN)
+}
+
+// The time allowed for connected clients to be notified and disconnected when shutting down.
+const shutdownTimeout = 10 * time.Second
+
+// How often the client send queues are checked for backed up or slow clients.
+const queueStatsInterval = time.Minute
+
+// Logs the client send queue stats whenever packets are waiting, or clients have been too slow since the last check.
+func logQueueStats(ctx context.Context, s *server.TCPServer) {
+	ticker := time.NewTicker(queueStatsInterval)
+	defer ticker.Stop()
+
+	last := server.QueueStats{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := s.QueueStats()
+
+			if stats.MaxQueueDepth > 0 || stats.DroppedPackets != last.DroppedPackets ||
+				stats.SlowConsumerDisconnects != last.SlowConsumerDisconnects {
+				log.Printf("Send queues: %v packets queued for %v clients (deepest %v), %v dropped, %v slow clients disconnected",
+					stats.QueuedPackets, stats.Clients, stats.MaxQueueDepth, stats.DroppedPackets, stats.SlowConsumerDisconnects)
+			}
+
+			last = stats
+		}
+	}
 }
 
 func onHandlePacket(s *server.TCPServer, client *server.Client, packet *server.Packet) {
-	if !client.LoggedIn && packet.ID == builders.PacketIDLogin && packet.Data != nil {
-		handlers.HandleLogin(s, client, packet)
+	request, err := requests.Decode(packet)
+	if err != nil {
+		handlers.SendProtocolError(client, packet, err)
 		return
 	}
 
-	// Logged in packet handlers...
-	if client.LoggedIn {
-		switch packet.ID {
-		case builders.PacketIDAudio:
-			go s.BroadcastPacket(&server.Packet{
-				ID:   builders.PacketIDAudio,
-				Data: packet.Data,
-			}, client)
+	// Heartbeats are answered whether or not the client is logged in, and don't count as user activity.
+	switch r := request.(type) {
+	case *requests.Ping:
+		handlers.HandlePing(s, client, r)
+		return
 
-		case builders.PacketIDChat:
-			if packet.Data != nil {
-				reader := bytes.NewReader(*packet.Data)
+	case *requests.Pong:
+		handlers.HandlePong(s, client, r)
+		return
+	}
 
-				userIDTo := utils.ReadInt32(reader)
-				msg := utils.ReadLenString(reader)
+	if !client.LoggedIn {
+		switch r := request.(type) {
+		case *requests.Login:
+			handlers.HandleLogin(s, client, r)
 
-				if msg != nil {
-					// TODO: Validate this client is the senders friend.
-					go s.BroadcastPacketToUserID(int(userIDTo), builders.NewChatFromPacket(client.UserID, *msg))
-				}
-			}
+		case *requests.TokenLogin:
+			handlers.HandleTokenLogin(s, client, r)
+		}
+		return
+	}
 
-		case builders.PacketIDAction:
-			if packet.Data != nil {
-				reader := bytes.NewReader(*packet.Data)
+	// Logged in packet handlers... They run on the clients reader goroutine, so shutting down waits for them before
+	// the store is closed.
+	handlers.TouchPresence(s, client)
 
-				userIDTo := utils.ReadInt32(reader)
-				action := utils.ReadLenString(reader)
+	switch r := request.(type) {
+	case *requests.Audio:
+		handlers.HandleAudio(s, client, r)
 
-				if action != nil {
-					// TODO: Validate this client is the senders friend.
-					go s.BroadcastPacketToUserID(int(userIDTo), builders.NewActionFromPacket(client.UserID, *action))
-				}
-			}
+	case *requests.CallInvite:
+		handlers.HandleCallInvite(s, client, r)
 
-		case builders.PacketIDNudge:
-			if packet.Data != nil {
-				reader := bytes.NewReader(*packet.Data)
+	case *requests.CallAccept:
+		handlers.HandleCallAccept(s, client, r)
 
-				userIDTo := utils.ReadInt32(reader)
+	case *requests.CallDecline:
+		handlers.HandleCallDecline(s, client, r)
 
-				// TODO: Validate this client is the senders friend.
+	case *requests.CallHangup:
+		handlers.HandleCallHangup(s, client, r)
 
-				go s.BroadcastPacketToUserID(int(userIDTo), builders.NewNudgeFromPacket(client.UserID))
-			}
+	case *requests.Chat:
+		if r.Message != nil && canDeliver(client, r.ToUserID, packet.ID) {
+			sendChat(s, client, r.ToUserID, *r.Message, r.MessageID)
+		}
 
-		case builders.PacketIDSetDisplayName:
-			if packet.Data == nil {
-				break
-			}
+	case *requests.Action:
+		if r.Action != nil && canDeliver(client, r.ToUserID, packet.ID) {
+			s.BroadcastPacketToUserID(r.ToUserID, builders.NewActionFromPacket(client.UserID, *r.Action))
+			recordMessage(client.UserID, r.ToUserID, dbaccess.MessageTypeAction, r.Action, time.Now())
+		}
 
-			name := string(*packet.Data)
-			client.DisplayName = &name
+	case *requests.Nudge:
+		if canDeliver(client, r.ToUserID, packet.ID) {
+			s.BroadcastPacketToUserID(r.ToUserID, builders.NewNudgeFromPacket(client.UserID))
+			recordMessage(client.UserID, r.ToUserID, dbaccess.MessageTypeNudge, nil, time.Now())
+		}
 
-		case builders.PacketIDAddContact:
-			handlers.HandleAddContact(s, client, packet)
+	case *requests.SetDisplayName:
+		handlers.HandleSetDisplayName(s, client, r)
 
-		case builders.PacketIDConfirmContact:
-			handlers.HandleConfirmContact(s, client, packet)
+	case *requests.SetStatusText:
+		handlers.HandleSetStatusText(s, client, r)
 
-		case builders.PacketIDRejectContact:
-			handlers.HandleRejectContact(s, client, packet)
+	case *requests.SetAvatar:
+		handlers.HandleSetAvatar(s, client, r)
 
-		case builders.PacketIDImage:
-			if packet.Data != nil {
-				reader := bytes.NewReader(*packet.Data)
+	case *requests.AddContact:
+		handlers.HandleAddContact(s, client, r)
 
-				userIDTo := utils.ReadInt32(reader)
-				imageData := utils.ReadLenString(reader)
+	case *requests.ConfirmContact:
+		handlers.HandleConfirmContact(s, client, r)
 
-				if imageData != nil {
-					// TODO: Validate this client is the senders friend.
-					go s.BroadcastPacketToUserID(int(userIDTo), builders.NewImageFromPacket(client.UserID, imageData))
-				}
-			}
-		case builders.PacketIDUserStatusChange:
-			if packet.Data == nil {
-				break
-			}
+	case *requests.RejectContact:
+		handlers.HandleRejectContact(s, client, r)
 
-			reader := bytes.NewReader(*packet.Data)
-			statusID := utils.ReadInt32(reader)
+	case *requests.RemoveContact:
+		handlers.HandleRemoveContact(s, client, r)
 
-			if statusID < 0 || statusID > 3 {
-				break
-			}
+	case *requests.BlockUser:
+		handlers.HandleBlockUser(s, client, r)
 
-			err := dbaccess.SetStatus(client.UserID, int(statusID))
+	case *requests.UnblockUser:
+		handlers.HandleUnblockUser(s, client, r)
 
-			if err != nil {
-				log.Printf("Failed to status for user id '%v': %v", client.UserID, err)
-			} else {
-				packet := builders.NewUserStatusChangePacket(client.UserID, int(statusID))
-				go utils.BroadcastPacketToContacts(s, client.UserID, packet)
-			}
+	case *requests.GetBlockedUsers:
+		handlers.HandleGetBlockedUsers(s, client, r)
+
+	case *requests.LogoutEverywhere:
+		handlers.HandleLogoutEverywhere(s, client, r)
+
+	case *requests.GetHistory:
+		handlers.HandleGetHistory(s, client, r)
+
+	case *requests.CreateRoom:
+		handlers.HandleCreateRoom(s, client, r)
+
+	case *requests.InviteToRoom:
+		handlers.HandleInviteToRoom(s, client, r)
+
+	case *requests.LeaveRoom:
+		handlers.HandleLeaveRoom(s, client, r)
+
+	case *requests.RenameRoom:
+		handlers.HandleRenameRoom(s, client, r)
+
+	case *requests.GetRoomMembers:
+		handlers.HandleGetRoomMembers(s, client, r)
+
+	case *requests.GetRooms:
+		handlers.HandleGetRooms(s, client, r)
+
+	case *requests.RoomMessage:
+		handlers.HandleRoomMessage(s, client, r)
+
+	case *requests.Image:
+		if r.ImageData != nil && canDeliver(client, r.ToUserID, packet.ID) {
+			s.BroadcastPacketToUserID(r.ToUserID, builders.NewImageFromPacket(client.UserID, r.ImageData))
+			recordMessage(client.UserID, r.ToUserID, dbaccess.MessageTypeImage, r.ImageData, time.Now())
+		}
+
+	case *requests.TypingStarted:
+		handlers.HandleTypingStarted(s, client, r)
+
+	case *requests.TypingStopped:
+		handlers.HandleTypingStopped(s, client, r)
+
+	case *requests.ReadReceipt:
+		handlers.HandleReadReceipt(s, client, r)
+
+	case *requests.UserStatusChange:
+		handlers.HandleUserStatusChange(s, client, r)
+	}
+}
+
+// Checks the client is allowed to send a direct packet to the specified user. If not, the client is told the packet
+// was not delivered, unless the recipient has blocked them.
+func canDeliver(client *server.Client, toUserID int, packetID int64) bool {
+	isContact, err := roster.IsContact(client.UserID, toUserID)
+	if err != nil {
+		log.Printf("Failed to check contact '%v' for user id '%v': %v", toUserID, client.UserID, err)
+		client.SendPacket(builders.NewDeliveryFailedPacket(toUserID, int(packetID), builders.DeliveryFailedReasonError))
+		return false
+	}
+
+	if !isContact {
+		client.SendPacket(builders.NewDeliveryFailedPacket(toUserID, int(packetID), builders.DeliveryFailedReasonNotContact))
+		return false
+	}
+
+	blocked, err := roster.IsBlocked(client.UserID, toUserID)
+	if err != nil {
+		log.Printf("Failed to check block of '%v' for user id '%v': %v", toUserID, client.UserID, err)
+		client.SendPacket(builders.NewDeliveryFailedPacket(toUserID, int(packetID), builders.DeliveryFailedReasonError))
+		return false
+	}
+
+	if blocked {
+		// Packets between blocked users are dropped. The sender is only told why if they are the one blocking.
+		rowID, err := store.GetBlock(client.UserID, toUserID)
+		if err == nil && rowID != nil {
+			client.SendPacket(builders.NewDeliveryFailedPacket(toUserID, int(packetID), builders.DeliveryFailedReasonBlocked))
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Sends a chat message to all of the users connected clients, or queues it for their next login if they are offline.
+// The sender is told once it is delivered or queued, unless their client didn't give the message an id.
+func sendChat(s *server.TCPServer, client *server.Client, toUserID int, msg string, messageID int) {
+	sentAt := time.Now()
+
+	recordMessage(client.UserID, toUserID, dbaccess.MessageTypeChat, &msg, sentAt)
+
+	handlers.StopTyping(s, client.UserID, toUserID)
+
+	// The sender is only told the message was delivered once it has been written to one of the recipients sessions.
+	// If they all drop it, such as when the recipient disconnects first, it is kept for their next login instead.
+	chatPacket := builders.NewChatFromPacket(client.UserID, msg, sentAt, messageID)
+	queued := s.DeliverPacketToUserID(toUserID, chatPacket, func(written bool) {
+		if !written {
+			queueOfflineChat(client, toUserID, msg, sentAt, messageID)
+			return
 		}
+
+		if messageID != 0 {
+			client.SendPacket(builders.NewMessageDeliveredPacket(toUserID, messageID, false))
+		}
+	})
+
+	if !queued {
+		queueOfflineChat(client, toUserID, msg, sentAt, messageID)
+	}
+}
+
+// Saves a chat message for a user that isn't online to receive it, and tells the sender it was queued.
+func queueOfflineChat(client *server.Client, toUserID int, msg string, sentAt time.Time, messageID int) {
+	err := store.AddOfflineMessage(toUserID, client.UserID, msg, sentAt, messageID)
+	if err != nil {
+		log.Printf("Failed to queue offline message for user id '%v': %v", toUserID, err)
+		client.SendPacket(builders.NewDeliveryFailedPacket(toUserID, builders.PacketIDChat, builders.DeliveryFailedReasonError))
+		return
+	}
+
+	if messageID != 0 {
+		client.SendPacket(builders.NewMessageDeliveredPacket(toUserID, messageID, true))
+	}
+}
+
+// Saves a message to the conversation history between two users.
+func recordMessage(fromUserID int, toUserID int, messageType int, content *string, sentAt time.Time) {
+	err := store.AddMessage(fromUserID, toUserID, messageType, content, sentAt)
+	if err != nil {
+		log.Printf("Failed to record message from user id '%v' to '%v': %v", fromUserID, toUserID, err)
 	}
 }
 
@@ -156,21 +415,30 @@ func onClientConnect(s *server.TCPServer, c *server.Client, addr string) {
 }
 
 func onClientDisconnect(s *server.TCPServer, c *server.Client, addr string) {
-	if c.LoggedIn && !s.IsClientMultiLogged(c.UserID) {
+	if c.LoggedIn {
+		handlers.EndClientCall(s, c)
+
 		// Only log out the user (set offline) if its the last client login instance.
+		if last, visible := handlers.EndClientPresence(c); last {
+			logoutUser(s, c.UserID, visible)
+		}
+	}
 
-		go func() {
-			err := dbaccess.LogoutUser(c.UserID)
-			if err != nil {
-				log.Print(err.Error())
-				log.Printf("Failed to logout user id '%v' due to error.", c.UserID)
-			}
-		}()
+	log.Printf("Client '%v' disconnected from %v (%v clients)", c.Username, addr, s.NumClients())
+}
 
-		// Notify this contacts friends, if they are logged in, that this user went offline.
-		statusPacket := builders.NewUserStatusChangePacket(c.UserID, dbaccess.StatusOffline)
-		go utils.BroadcastPacketToContacts(s, c.UserID, statusPacket)
+func logoutUser(s *server.TCPServer, userID int, visible bool) {
+	// Done before returning so the server doesn't finish shutting down until the user is logged out.
+	err := store.LogoutUser(userID)
+	if err != nil {
+		log.Print(err.Error())
+		log.Printf("Failed to logout user id '%v' due to error.", userID)
 	}
 
-	log.Printf("Client '%v' disconnected from %v (%v clients)", c.Username, addr, s.NumClients())
+	// Notify this contacts friends, if they are logged in and could see them, that this user went offline. Their
+	// contacts are no longer needed in the roster cache after that.
+	if visible {
+		utils.BroadcastPacketToContacts(s, userID, builders.NewUserStatusChangePacket(userID, dbaccess.StatusOffline))
+	}
+	roster.Invalidate(userID)
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // UserModel is a model of a user in the data store.
 type UserModel struct {
 	ID          int
@@ -28,3 +30,42 @@ type PendingContactModel struct {
 	ImageURL    *string
 	Message     *string
 }
+
+// OfflineMessageModel is a model of a chat message waiting to be delivered to an offline user.
+type OfflineMessageModel struct {
+	ID         int
+	FromUserID int
+	Message    string
+	SentAt     time.Time
+	MessageID  int
+}
+
+// MessageModel is a model of a message in a conversation history.
+type MessageModel struct {
+	ID          int
+	FromUserID  int
+	MessageType int
+	Content     *string
+	SentAt      time.Time
+}
+
+// RoomModel is a model of a group conversation.
+type RoomModel struct {
+	ID   int
+	Name string
+}
+
+// RoomMemberModel is a model of a user in a group conversation.
+type RoomMemberModel struct {
+	ID          int
+	Username    string
+	DisplayName *string
+	Status      int
+}
+
+// BlockedUserModel is a model of a user on another users block list.
+type BlockedUserModel struct {
+	ID          int
+	Username    string
+	DisplayName *string
+}
@@ -0,0 +1,209 @@
+// Package presence works out the status each user shows to their contacts from the status they chose and the
+// activity of all of their logged in sessions.
+package presence
+
+import (
+	"chatServer/dbaccess"
+	"chatServer/server"
+	"sync"
+	"time"
+)
+
+// IsValidStatus returns true if a user can choose the status.
+func IsValidStatus(status int) bool {
+	switch status {
+	case dbaccess.StatusOnline, dbaccess.StatusAway, dbaccess.StatusBusy, dbaccess.StatusInvisible:
+		return true
+	}
+
+	return false
+}
+
+// Visible returns the status contacts see for a users status. Invisible users appear offline.
+func Visible(status int) int {
+	if status == dbaccess.StatusInvisible {
+		return dbaccess.StatusOffline
+	}
+
+	return status
+}
+
+type session struct {
+	lastActivity time.Time
+	idle         bool
+}
+
+type user struct {
+	chosen    int
+	sessions  map[*server.Client]*session
+	published int
+}
+
+// Tracker holds the presence of every logged in user. It is safe to use from multiple goroutines.
+type Tracker struct {
+	mutex *sync.Mutex
+	users map[int]*user
+	now   func() time.Time
+}
+
+// NewTracker creates a new Tracker instance.
+func NewTracker() *Tracker {
+	return &Tracker{
+		mutex: &sync.Mutex{},
+		users: map[int]*user{},
+		now:   time.Now,
+	}
+}
+
+// Login adds a logged in session of a user. A user logging in on another client keeps the status they already
+// chose, otherwise they start online.
+func (t *Tracker) Login(client *server.Client) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	u, ok := t.users[client.UserID]
+	if !ok {
+		u = &user{
+			chosen:    dbaccess.StatusOnline,
+			sessions:  map[*server.Client]*session{},
+			published: dbaccess.StatusOffline,
+		}
+		t.users[client.UserID] = u
+	}
+
+	u.sessions[client] = &session{lastActivity: t.now()}
+}
+
+// Logout removes a session. Returns true if it was the users last session, and the status their contacts could
+// see before it was removed.
+func (t *Tracker) Logout(client *server.Client) (bool, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	u, ok := t.users[client.UserID]
+	if !ok {
+		return false, dbaccess.StatusOffline
+	}
+
+	delete(u.sessions, client)
+
+	if len(u.sessions) > 0 {
+		return false, u.published
+	}
+
+	delete(t.users, client.UserID)
+
+	return true, u.published
+}
+
+// SetStatus sets the status a user chose. It applies to all of their sessions.
+func (t *Tracker) SetStatus(userID int, status int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if u, ok := t.users[userID]; ok {
+		u.chosen = status
+	}
+}
+
+// Touch records activity on a session. Returns true if the session was idle, so the users status may have changed.
+func (t *Tracker) Touch(client *server.Client) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	u, ok := t.users[client.UserID]
+	if !ok {
+		return false
+	}
+
+	s, ok := u.sessions[client]
+	if !ok {
+		return false
+	}
+
+	s.lastActivity = t.now()
+
+	if s.idle {
+		s.idle = false
+		return true
+	}
+
+	return false
+}
+
+// CheckIdle marks sessions without activity for the specified duration as idle. Returns the ids of users that had
+// a session become idle, so their status may have changed.
+func (t *Tracker) CheckIdle(awayAfter time.Duration) []int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	userIDs := []int{}
+
+	for userID, u := range t.users {
+		changed := false
+
+		for _, s := range u.sessions {
+			if !s.idle && now.Sub(s.lastActivity) >= awayAfter {
+				s.idle = true
+				changed = true
+			}
+		}
+
+		if changed {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return userIDs
+}
+
+// Publish works out the status a users contacts should see. Returns true if it differs from the last published
+// status, in which case it is recorded as published and should be sent to the contacts.
+func (t *Tracker) Publish(userID int) (int, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	u, ok := t.users[userID]
+	if !ok {
+		return dbaccess.StatusOffline, false
+	}
+
+	visible := Visible(u.effective())
+
+	if visible == u.published {
+		return visible, false
+	}
+
+	u.published = visible
+
+	return visible, true
+}
+
+// Published returns the status a users contacts were last shown, or offline if the user isn't logged in.
+func (t *Tracker) Published(userID int) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	u, ok := t.users[userID]
+	if !ok {
+		return dbaccess.StatusOffline
+	}
+
+	return u.published
+}
+
+// A user that chose to be online is away once all of their sessions are idle.
+func (u *user) effective() int {
+	if u.chosen != dbaccess.StatusOnline {
+		return u.chosen
+	}
+
+	for _, s := range u.sessions {
+		if !s.idle {
+			return u.chosen
+		}
+	}
+
+	return dbaccess.StatusAway
+}
@@ -0,0 +1,88 @@
+package presence
+
+import (
+	"chatServer/dbaccess"
+	"chatServer/server"
+	"testing"
+	"time"
+)
+
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Now()
+	t := NewTracker()
+	t.now = func() time.Time { return now }
+
+	return t, &now
+}
+
+func assertPublish(t *testing.T, tracker *Tracker, userID int, expectStatus int, expectChanged bool) {
+	t.Helper()
+
+	status, changed := tracker.Publish(userID)
+	if status != expectStatus || changed != expectChanged {
+		t.Errorf("Expected (%v, %v), got (%v, %v).", expectStatus, expectChanged, status, changed)
+	}
+}
+
+func TestAutoAwayNeedsAllSessionsIdle(t *testing.T) {
+	tracker, now := newTestTracker()
+	first := &server.Client{UserID: 1}
+	second := &server.Client{UserID: 1}
+
+	tracker.Login(first)
+	assertPublish(t, tracker, 1, dbaccess.StatusOnline, true)
+
+	*now = now.Add(time.Minute)
+	tracker.Login(second)
+
+	*now = now.Add(9 * time.Minute)
+	tracker.CheckIdle(10 * time.Minute)
+	assertPublish(t, tracker, 1, dbaccess.StatusOnline, false)
+
+	*now = now.Add(time.Minute)
+	tracker.CheckIdle(10 * time.Minute)
+	assertPublish(t, tracker, 1, dbaccess.StatusAway, true)
+
+	if !tracker.Touch(first) {
+		t.Errorf("Expected touching an idle session to report a change.")
+	}
+	assertPublish(t, tracker, 1, dbaccess.StatusOnline, true)
+}
+
+func TestChosenStatusKeptAcrossSessions(t *testing.T) {
+	tracker, _ := newTestTracker()
+	first := &server.Client{UserID: 1}
+	second := &server.Client{UserID: 1}
+
+	tracker.Login(first)
+	tracker.SetStatus(1, dbaccess.StatusBusy)
+	assertPublish(t, tracker, 1, dbaccess.StatusBusy, true)
+
+	if status := tracker.Published(1); status != dbaccess.StatusBusy {
+		t.Errorf("Expected the published status to be busy, got %v.", status)
+	}
+
+	tracker.Login(second)
+	assertPublish(t, tracker, 1, dbaccess.StatusBusy, false)
+
+	last, published := tracker.Logout(first)
+	if last || published != dbaccess.StatusBusy {
+		t.Errorf("Expected (false, %v), got (%v, %v).", dbaccess.StatusBusy, last, published)
+	}
+
+	assertPublish(t, tracker, 1, dbaccess.StatusBusy, false)
+}
+
+func TestInvisibleAppearsOffline(t *testing.T) {
+	tracker, _ := newTestTracker()
+	client := &server.Client{UserID: 1}
+
+	tracker.Login(client)
+	tracker.SetStatus(1, dbaccess.StatusInvisible)
+	assertPublish(t, tracker, 1, dbaccess.StatusOffline, false)
+
+	last, published := tracker.Logout(client)
+	if !last || published != dbaccess.StatusOffline {
+		t.Errorf("Expected (true, %v), got (%v, %v).", dbaccess.StatusOffline, last, published)
+	}
+}
@@ -0,0 +1,127 @@
+package requests
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Errors returned when a packet can't be decoded.
+var (
+	ErrUnknownPacket = errors.New("unknown packet id")
+	ErrTruncated     = errors.New("data truncated")
+	ErrTooLong       = errors.New("field too long")
+	ErrInvalid       = errors.New("invalid field")
+)
+
+// Error is returned when a field of a packet can't be decoded. Err is one of the errors above.
+type Error struct {
+	PacketID int64
+	Field    string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("packet id %v: %v", e.PacketID, e.Err)
+	}
+
+	return fmt.Sprintf("packet id %v field %v: %v", e.PacketID, e.Field, e.Err)
+}
+
+// Unwrap returns the cause of the error, so it can be checked with errors.Is.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Reads the fields of packet data, checking each one fits in what is left. The first error is kept and every read
+// after it returns a zero value, so a request can be read in full and checked once at the end.
+type reader struct {
+	packetID int64
+	data     []byte
+	err      error
+}
+
+func newReader(packetID int64, data *[]byte) *reader {
+	r := &reader{packetID: packetID}
+	if data != nil {
+		r.data = *data
+	}
+
+	return r
+}
+
+func (r *reader) fail(field string, err error) {
+	if r.err == nil {
+		r.err = &Error{PacketID: r.packetID, Field: field, Err: err}
+	}
+}
+
+// Returns the next n bytes of the field, or nil if there aren't enough left.
+func (r *reader) next(field string, n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n > len(r.data) {
+		r.fail(field, ErrTruncated)
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+// Returns true if there is any data left, for optional fields at the end of a packet.
+func (r *reader) more() bool {
+	return r.err == nil && len(r.data) > 0
+}
+
+// Reads a little endian int32.
+func (r *reader) int32(field string) int {
+	b := r.next(field, 4)
+	if b == nil {
+		return 0
+	}
+
+	return int(int32(binary.LittleEndian.Uint32(b)))
+}
+
+// Reads a string prefixed by its int32 length, no longer than the maximum length. An empty string is returned as nil.
+func (r *reader) string(field string, maxLength int) *string {
+	length := r.int32(field)
+	if r.err != nil || length == 0 {
+		return nil
+	}
+
+	if length < 0 {
+		r.fail(field, ErrInvalid)
+		return nil
+	}
+
+	if length > maxLength {
+		r.fail(field, ErrTooLong)
+		return nil
+	}
+
+	b := r.next(field, length)
+	if b == nil {
+		return nil
+	}
+
+	str := string(b)
+
+	return &str
+}
+
+// Returns the rest of the data, for packets that are raw data.
+func (r *reader) rest(field string, maxLength int) []byte {
+	if len(r.data) > maxLength {
+		r.fail(field, ErrTooLong)
+		return nil
+	}
+
+	return r.next(field, len(r.data))
+}
@@ -0,0 +1,357 @@
+// Package requests decodes the packets clients send to the server into typed requests. Every field is checked to fit
+// in the packet data and within a maximum length, so malformed packets are refused instead of being read as garbage.
+package requests
+
+import (
+	"chatServer/builders"
+	"chatServer/server"
+	"math"
+	"strings"
+)
+
+// The maximum lengths of request fields. Handlers can validate them further.
+const (
+	maxNameLength           = 256   // Usernames and room names.
+	maxMessageLength        = 65535 // Chat, action and room messages. The most the TEXT message columns hold.
+	maxContactMessageLength = 100   // Contact request messages, stored in a varchar(100) column.
+	maxLoginLength          = 1024  // Login credentials and session tokens.
+	maxProfileLength        = 1024  // Display names and status texts.
+	unlimited               = math.MaxInt32
+)
+
+// Login logs in with a username and password.
+type Login struct {
+	Username string
+	Password string
+}
+
+// TokenLogin resumes a session with the token from an earlier login.
+type TokenLogin struct {
+	Token string
+}
+
+// Chat sends a chat message to a contact. A message id of 0 asks for no receipts, and is used by clients that send
+// no message id.
+type Chat struct {
+	ToUserID  int
+	Message   *string
+	MessageID int
+}
+
+// Nudge nudges a contact.
+type Nudge struct {
+	ToUserID int
+}
+
+// Audio is audio data for the other participant of a call.
+type Audio struct {
+	Data []byte
+}
+
+// Ping asks for a pong with the same sequence. Clients can send it without a sequence.
+type Ping struct {
+	Sequence int
+}
+
+// Pong answers a ping from the server.
+type Pong struct {
+	Sequence int
+}
+
+// Action sends an action to a contact.
+type Action struct {
+	ToUserID int
+	Action   *string
+}
+
+// SetDisplayName changes the users display name.
+type SetDisplayName struct {
+	DisplayName string
+}
+
+// UserStatusChange changes the users presence status.
+type UserStatusChange struct {
+	Status int
+}
+
+// AddContact asks a user to become a contact.
+type AddContact struct {
+	Username *string
+	Message  *string
+}
+
+// ConfirmContact accepts the contact request of a user.
+type ConfirmContact struct {
+	RequestedUserID int
+}
+
+// RejectContact rejects the contact request of a user.
+type RejectContact struct {
+	RequestedUserID int
+}
+
+// Image sends an image to a contact.
+type Image struct {
+	ToUserID  int
+	ImageData *string
+}
+
+// GetHistory fetches the page of a conversation before a message, or the newest page for message id 0.
+type GetHistory struct {
+	ContactUserID   int
+	BeforeMessageID int
+}
+
+// CreateRoom creates a room.
+type CreateRoom struct {
+	Name *string
+}
+
+// InviteToRoom adds a contact to a room.
+type InviteToRoom struct {
+	RoomID int
+	UserID int
+}
+
+// LeaveRoom leaves a room.
+type LeaveRoom struct {
+	RoomID int
+}
+
+// RenameRoom renames a room.
+type RenameRoom struct {
+	RoomID int
+	Name   *string
+}
+
+// GetRoomMembers fetches the members of a room.
+type GetRoomMembers struct {
+	RoomID int
+}
+
+// RoomMessage sends a message to a room.
+type RoomMessage struct {
+	RoomID  int
+	Message *string
+}
+
+// GetRooms fetches the rooms of the user.
+type GetRooms struct{}
+
+// CallInvite calls a contact.
+type CallInvite struct {
+	CalleeUserID int
+}
+
+// CallAccept accepts an incoming call.
+type CallAccept struct {
+	CallID int
+}
+
+// CallDecline declines an incoming call.
+type CallDecline struct {
+	CallID int
+}
+
+// CallHangup ends a call.
+type CallHangup struct {
+	CallID int
+}
+
+// LogoutEverywhere revokes every session of the user.
+type LogoutEverywhere struct{}
+
+// RemoveContact removes a contact.
+type RemoveContact struct {
+	ContactUserID int
+}
+
+// BlockUser blocks a user.
+type BlockUser struct {
+	UserID int
+}
+
+// UnblockUser unblocks a user.
+type UnblockUser struct {
+	UserID int
+}
+
+// GetBlockedUsers fetches the users the user has blocked.
+type GetBlockedUsers struct{}
+
+// SetStatusText changes the users status text, or clears it when nil.
+type SetStatusText struct {
+	StatusText *string
+}
+
+// SetAvatar uploads a new avatar image.
+type SetAvatar struct {
+	Image []byte
+}
+
+// ReadReceipt tells the sender of a chat message that it was read.
+type ReadReceipt struct {
+	FromUserID int
+	MessageID  int
+}
+
+// TypingStarted tells a contact the user started typing.
+type TypingStarted struct {
+	ToUserID int
+}
+
+// TypingStopped tells a contact the user stopped typing.
+type TypingStopped struct {
+	ToUserID int
+}
+
+// Decode decodes a packet sent by a client into its request type, such as *Chat for a chat packet. Data left over
+// after the fields of a request is ignored, so clients can send fields added in later versions. An *Error is
+// returned if the packet id is unknown or the data doesn't hold a valid request.
+func Decode(p *server.Packet) (interface{}, error) {
+	r := newReader(p.ID, p.Data)
+
+	var request interface{}
+
+	switch p.ID {
+	case builders.PacketIDLogin:
+		// The username and password separated by a newline.
+		credentials := string(r.rest("Credentials", 2*maxLoginLength))
+
+		parts := strings.SplitN(credentials, "\n", 2)
+		if len(parts) != 2 {
+			r.fail("Credentials", ErrInvalid)
+			break
+		}
+		request = &Login{Username: parts[0], Password: parts[1]}
+
+	case builders.PacketIDTokenLogin:
+		request = &TokenLogin{Token: string(r.rest("Token", maxLoginLength))}
+
+	case builders.PacketIDChat:
+		// The message id was added later, and is left out by older clients.
+		chat := &Chat{ToUserID: r.int32("ToUserId"), Message: r.string("Message", maxMessageLength)}
+		if r.more() {
+			chat.MessageID = r.int32("MessageId")
+		}
+		request = chat
+
+	case builders.PacketIDNudge:
+		request = &Nudge{ToUserID: r.int32("ToUserId")}
+
+	case builders.PacketIDAudio:
+		request = &Audio{Data: r.rest("Data", unlimited)}
+
+	case builders.PacketIDPing:
+		ping := &Ping{}
+		if r.more() {
+			ping.Sequence = r.int32("Sequence")
+		}
+		request = ping
+
+	case builders.PacketIDPong:
+		request = &Pong{Sequence: r.int32("Sequence")}
+
+	case builders.PacketIDAction:
+		request = &Action{ToUserID: r.int32("ToUserId"), Action: r.string("Action", maxMessageLength)}
+
+	case builders.PacketIDSetDisplayName:
+		request = &SetDisplayName{DisplayName: string(r.rest("DisplayName", maxProfileLength))}
+
+	case builders.PacketIDUserStatusChange:
+		request = &UserStatusChange{Status: r.int32("Status")}
+
+	case builders.PacketIDAddContact:
+		request = &AddContact{Username: r.string("Username", maxNameLength), Message: r.string("Message", maxContactMessageLength)}
+
+	case builders.PacketIDConfirmContact:
+		request = &ConfirmContact{RequestedUserID: r.int32("RequestedUserId")}
+
+	case builders.PacketIDRejectContact:
+		request = &RejectContact{RequestedUserID: r.int32("RequestedUserId")}
+
+	case builders.PacketIDImage:
+		request = &Image{ToUserID: r.int32("ToUserId"), ImageData: r.string("ImageData", unlimited)}
+
+	case builders.PacketIDGetHistory:
+		request = &GetHistory{ContactUserID: r.int32("ContactUserId"), BeforeMessageID: r.int32("BeforeMessageId")}
+
+	case builders.PacketIDCreateRoom:
+		request = &CreateRoom{Name: r.string("Name", maxNameLength)}
+
+	case builders.PacketIDInviteToRoom:
+		request = &InviteToRoom{RoomID: r.int32("RoomId"), UserID: r.int32("UserId")}
+
+	case builders.PacketIDLeaveRoom:
+		request = &LeaveRoom{RoomID: r.int32("RoomId")}
+
+	case builders.PacketIDRenameRoom:
+		request = &RenameRoom{RoomID: r.int32("RoomId"), Name: r.string("Name", maxNameLength)}
+
+	case builders.PacketIDGetRoomMembers:
+		request = &GetRoomMembers{RoomID: r.int32("RoomId")}
+
+	case builders.PacketIDRoomMessage:
+		request = &RoomMessage{RoomID: r.int32("RoomId"), Message: r.string("Message", maxMessageLength)}
+
+	case builders.PacketIDGetRooms:
+		request = &GetRooms{}
+
+	case builders.PacketIDCallInvite:
+		request = &CallInvite{CalleeUserID: r.int32("CalleeUserId")}
+
+	case builders.PacketIDCallAccept:
+		request = &CallAccept{CallID: r.int32("CallId")}
+
+	case builders.PacketIDCallDecline:
+		request = &CallDecline{CallID: r.int32("CallId")}
+
+	case builders.PacketIDCallHangup:
+		request = &CallHangup{CallID: r.int32("CallId")}
+
+	case builders.PacketIDLogoutEverywhere:
+		request = &LogoutEverywhere{}
+
+	case builders.PacketIDRemoveContact:
+		request = &RemoveContact{ContactUserID: r.int32("ContactUserId")}
+
+	case builders.PacketIDBlockUser:
+		request = &BlockUser{UserID: r.int32("UserId")}
+
+	case builders.PacketIDUnblockUser:
+		request = &UnblockUser{UserID: r.int32("UserId")}
+
+	case builders.PacketIDGetBlockedUsers:
+		request = &GetBlockedUsers{}
+
+	case builders.PacketIDSetStatusText:
+		// No data clears the status text.
+		setStatusText := &SetStatusText{}
+		if text := r.rest("StatusText", maxProfileLength); len(text) > 0 {
+			statusText := string(text)
+			setStatusText.StatusText = &statusText
+		}
+		request = setStatusText
+
+	case builders.PacketIDSetAvatar:
+		request = &SetAvatar{Image: r.rest("Image", unlimited)}
+
+	case builders.PacketIDReadReceipt:
+		request = &ReadReceipt{FromUserID: r.int32("FromUserId"), MessageID: r.int32("MessageId")}
+
+	case builders.PacketIDTypingStarted:
+		request = &TypingStarted{ToUserID: r.int32("ToUserId")}
+
+	case builders.PacketIDTypingStopped:
+		request = &TypingStopped{ToUserID: r.int32("ToUserId")}
+
+	default:
+		return nil, &Error{PacketID: p.ID, Err: ErrUnknownPacket}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return request, nil
+}
@@ -0,0 +1,104 @@
+package requests
+
+import (
+	"bytes"
+	"chatServer/builders"
+	"chatServer/server"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Builds a packet from ints encoded as int32, strings with their length and raw bytes.
+func packet(id int64, fields ...interface{}) *server.Packet {
+	buf := new(bytes.Buffer)
+
+	for _, f := range fields {
+		switch v := f.(type) {
+		case int:
+			binary.Write(buf, binary.LittleEndian, int32(v))
+		case string:
+			binary.Write(buf, binary.LittleEndian, int32(len(v)))
+			buf.WriteString(v)
+		case []byte:
+			buf.Write(v)
+		}
+	}
+
+	data := buf.Bytes()
+
+	return &server.Packet{ID: id, Data: &data}
+}
+
+func str(s string) *string {
+	return &s
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		packet  *server.Packet
+		request interface{}
+	}{
+		{packet(builders.PacketIDLogin, []byte("alice\npass\nword")), &Login{Username: "alice", Password: "pass\nword"}},
+		{packet(builders.PacketIDChat, 2, "hi", 7), &Chat{ToUserID: 2, Message: str("hi"), MessageID: 7}},
+		{packet(builders.PacketIDChat, 2, "hi"), &Chat{ToUserID: 2, Message: str("hi")}},
+		{packet(builders.PacketIDChat, 2, ""), &Chat{ToUserID: 2}},
+		{&server.Packet{ID: builders.PacketIDPing}, &Ping{}},
+		{packet(builders.PacketIDPing, 5), &Ping{Sequence: 5}},
+		{packet(builders.PacketIDAddContact, "bob", "add me"), &AddContact{Username: str("bob"), Message: str("add me")}},
+		{packet(builders.PacketIDGetHistory, 2, -1, 99), &GetHistory{ContactUserID: 2, BeforeMessageID: -1}},
+		{&server.Packet{ID: builders.PacketIDSetStatusText}, &SetStatusText{}},
+		{packet(builders.PacketIDSetStatusText, []byte("away")), &SetStatusText{StatusText: str("away")}},
+		{&server.Packet{ID: builders.PacketIDGetRooms}, &GetRooms{}},
+		{
+			packet(builders.PacketIDChat, 2, strings.Repeat("a", maxMessageLength)),
+			&Chat{ToUserID: 2, Message: str(strings.Repeat("a", maxMessageLength))},
+		},
+	}
+
+	for _, test := range tests {
+		request, err := Decode(test.packet)
+		if err != nil {
+			t.Errorf("Failed to decode packet %v: %v", test.packet.ID, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(request, test.request) {
+			t.Errorf("Packet %v decoded as %+v, expected %+v.", test.packet.ID, request, test.request)
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet *server.Packet
+		field  string
+		err    error
+	}{
+		{"no data", &server.Packet{ID: builders.PacketIDConfirmContact}, "RequestedUserId", ErrTruncated},
+		{"short int", packet(builders.PacketIDNudge, []byte{1, 0}), "ToUserId", ErrTruncated},
+		{"short string", packet(builders.PacketIDChat, 2, 10, []byte("hi")), "Message", ErrTruncated},
+		{"negative length", packet(builders.PacketIDChat, 2, -1), "Message", ErrInvalid},
+		{"long string", packet(builders.PacketIDAddContact, strings.Repeat("a", maxNameLength+1)), "Username", ErrTooLong},
+		{"long message", packet(builders.PacketIDChat, 2, strings.Repeat("a", maxMessageLength+1)), "Message", ErrTooLong},
+		{"long contact message", packet(builders.PacketIDAddContact, "bob", strings.Repeat("a", maxContactMessageLength+1)), "Message", ErrTooLong},
+		{"long data", packet(builders.PacketIDTokenLogin, []byte(strings.Repeat("a", maxLoginLength+1))), "Token", ErrTooLong},
+		{"login without newline", packet(builders.PacketIDLogin, []byte("alice")), "Credentials", ErrInvalid},
+		{"unknown packet", &server.Packet{ID: 1000}, "", ErrUnknownPacket},
+	}
+
+	for _, test := range tests {
+		request, err := Decode(test.packet)
+		if request != nil {
+			t.Errorf("%v: expected no request, got %+v.", test.name, request)
+		}
+
+		var decodeErr *Error
+		if !errors.As(err, &decodeErr) || !errors.Is(err, test.err) || decodeErr.Field != test.field {
+			t.Errorf("%v: expected %v for field '%v', got %v.", test.name, test.err, test.field, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package roster caches the contact lists and block relations of users so that presence broadcasts, contact checks
+// and block checks don't need to hit the database every time.
+package roster
+
+import (
+	"chatServer/dbaccess"
+	"sync"
+)
+
+// LoadRelated is used to load the ids related to a user from the data store when they are not cached.
+type LoadRelated func(userID int) ([]int, error)
+
+// Cache holds the ids of users related to each user, such as their contacts. Relations are always two way. Entries
+// are loaded on first use and kept up to date as relations are added and removed, so they stay consistent with the
+// data store without re-querying it.
+type Cache struct {
+	mutex    *sync.RWMutex
+	related  map[int]map[int]bool
+	versions map[int]int
+	load     LoadRelated
+}
+
+// NewCache creates a new Cache instance that uses the specified function to load related ids on a cache miss.
+func NewCache(load LoadRelated) *Cache {
+	return &Cache{
+		mutex:    &sync.RWMutex{},
+		related:  map[int]map[int]bool{},
+		versions: map[int]int{},
+		load:     load,
+	}
+}
+
+// Get returns the ids related to the specified user, loading them from the data store if not cached.
+func (c *Cache) Get(userID int) ([]int, error) {
+	c.mutex.RLock()
+	set, ok := c.related[userID]
+	if ok {
+		ids := setToSlice(set)
+		c.mutex.RUnlock()
+		return ids, nil
+	}
+	version := c.versions[userID]
+	c.mutex.RUnlock()
+
+	ids, err := c.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Only cache the result if the relations weren't changed while we were loading, otherwise the loaded list could
+	// be stale. The next call will load again.
+	if c.versions[userID] == version {
+		if _, ok := c.related[userID]; !ok {
+			c.related[userID] = sliceToSet(ids)
+		}
+	}
+
+	return ids, nil
+}
+
+// Has returns true if the other user is related to the specified user.
+func (c *Cache) Has(userID int, otherUserID int) (bool, error) {
+	c.mutex.RLock()
+	set, ok := c.related[userID]
+	if ok {
+		has := set[otherUserID]
+		c.mutex.RUnlock()
+		return has, nil
+	}
+	c.mutex.RUnlock()
+
+	ids, err := c.Get(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		if id == otherUserID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Set replaces the cached relations of a user with a list already loaded from the data store, such as at login.
+func (c *Cache) Set(userID int, userIDs []int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.versions[userID]++
+	c.related[userID] = sliceToSet(userIDs)
+}
+
+// Add records that two users are now related to each other.
+func (c *Cache) Add(userID int, otherUserID int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.versions[userID]++
+	c.versions[otherUserID]++
+
+	if set, ok := c.related[userID]; ok {
+		set[otherUserID] = true
+	}
+
+	if set, ok := c.related[otherUserID]; ok {
+		set[userID] = true
+	}
+}
+
+// Remove records that two users are no longer related to each other.
+func (c *Cache) Remove(userID int, otherUserID int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.versions[userID]++
+	c.versions[otherUserID]++
+
+	if set, ok := c.related[userID]; ok {
+		delete(set, otherUserID)
+	}
+
+	if set, ok := c.related[otherUserID]; ok {
+		delete(set, userID)
+	}
+}
+
+// Invalidate removes a users relations from the cache. They will be loaded from the data store on next use.
+func (c *Cache) Invalidate(userID int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.versions[userID]++
+	delete(c.related, userID)
+}
+
+func setToSlice(set map[int]bool) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func sliceToSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	return set
+}
+
+// The caches used by the server, backed by the data store set with SetStore.
+var (
+	contacts *Cache
+	blocks   *Cache
+)
+
+// SetStore sets the data store that contacts and block relations are loaded from, emptying the caches. It must be
+// called before any of the other functions are used.
+func SetStore(store dbaccess.Store) {
+	contacts = NewCache(contactsLoader(store))
+	blocks = NewCache(store.GetBlockRelations)
+}
+
+// Returns a loader of the contact ids of a user from the data store.
+func contactsLoader(store dbaccess.Store) func(userID int) ([]int, error) {
+	return func(userID int) ([]int, error) {
+		friends, err := store.GetFriends(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make([]int, 0, len(friends))
+		for _, f := range friends {
+			ids = append(ids, f.ID)
+		}
+
+		return ids, nil
+	}
+}
+
+// GetContacts returns the contact ids of the specified user.
+func GetContacts(userID int) ([]int, error) {
+	return contacts.Get(userID)
+}
+
+// IsContact returns true if the contact user is in the specified users contacts.
+func IsContact(userID int, contactUserID int) (bool, error) {
+	return contacts.Has(userID, contactUserID)
+}
+
+// SetContacts caches the contacts of a user that were loaded from the database.
+func SetContacts(userID int, contactUserIDs []int) {
+	contacts.Set(userID, contactUserIDs)
+}
+
+// AddContact records that two users are now contacts of each other.
+func AddContact(userID int, contactUserID int) {
+	contacts.Add(userID, contactUserID)
+}
+
+// RemoveContact records that two users are no longer contacts of each other.
+func RemoveContact(userID int, contactUserID int) {
+	contacts.Remove(userID, contactUserID)
+}
+
+// Invalidate removes a users contacts and block relations from the cache.
+func Invalidate(userID int) {
+	contacts.Invalidate(userID)
+	blocks.Invalidate(userID)
+}
+
+// IsBlocked returns true if either user has blocked the other.
+func IsBlocked(userID int, otherUserID int) (bool, error) {
+	return blocks.Has(userID, otherUserID)
+}
+
+// AddBlock records that one user has blocked the other.
+func AddBlock(userID int, blockedUserID int) {
+	blocks.Add(userID, blockedUserID)
+}
+
+// InvalidateBlocks removes the block relations of two users from the cache after one unblocks the other. The other
+// user may still have a block in place, so they are reloaded rather than removed.
+func InvalidateBlocks(userID int, otherUserID int) {
+	blocks.Invalidate(userID)
+	blocks.Invalidate(otherUserID)
+}
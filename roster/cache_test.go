@@ -0,0 +1,261 @@
+package roster
+
+import (
+	"chatServer/dbaccess"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// fakeStore stands in for the friends table in tests of how the cache loads, counting loads and failing on demand.
+type fakeStore struct {
+	mutex   sync.Mutex
+	friends map[int]map[int]bool
+	loads   int
+	fail    bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{friends: map[int]map[int]bool{}}
+}
+
+func (f *fakeStore) load(userID int) ([]int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.loads++
+
+	if f.fail {
+		return nil, errors.New("database unavailable")
+	}
+
+	return setToSlice(f.friends[userID]), nil
+}
+
+func (f *fakeStore) confirm(a int, b int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.friends[a] == nil {
+		f.friends[a] = map[int]bool{}
+	}
+	if f.friends[b] == nil {
+		f.friends[b] = map[int]bool{}
+	}
+
+	f.friends[a][b] = true
+	f.friends[b][a] = true
+}
+
+func sorted(ids []int) []int {
+	sort.Ints(ids)
+	return ids
+}
+
+// Runs a test against each data store that doesn't need an external database.
+func forEachStore(t *testing.T, test func(t *testing.T, store dbaccess.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, dbaccess.NewMemoryStore())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := dbaccess.NewSQLiteStore(":memory:")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer store.Close()
+
+		test(t, store)
+	})
+}
+
+// Creates the specified number of accounts and returns their user ids.
+func createUsers(t *testing.T, store dbaccess.Store, count int) []int {
+	t.Helper()
+
+	ids := make([]int, 0, count)
+
+	for i := 0; i < count; i++ {
+		username := fmt.Sprintf("user%v", i)
+
+		err := store.CreateAccount(username, "hash", username+"@example.com", username, "guid")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		user, err := store.GetUserByUsername(username)
+		if err != nil || user == nil {
+			t.Fatalf("Expected user '%v' to exist, got %v, %v.", username, user, err)
+		}
+
+		ids = append(ids, user.ID)
+	}
+
+	return ids
+}
+
+// Makes two users contacts of each other through a contact request.
+func confirm(t *testing.T, store dbaccess.Store, userID int, addingUserID int) {
+	t.Helper()
+
+	store.AddPendingContact(userID, addingUserID, nil)
+	if err := store.ConfirmContactRequest(userID, addingUserID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Checks the cached contacts of each user match what the loader returns.
+func assertConsistent(t *testing.T, c *Cache, load func(userID int) ([]int, error), userIDs ...int) {
+	t.Helper()
+
+	for _, userID := range userIDs {
+		cached, err := c.Get(userID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expect, _ := load(userID)
+
+		if !equal(sorted(cached), sorted(expect)) {
+			t.Errorf("User %v: expected %v, got %v.", userID, expect, cached)
+		}
+	}
+}
+
+func equal(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestContactsLoadedOnce(t *testing.T) {
+	f := newFakeStore()
+	f.confirm(1, 2)
+	c := NewCache(f.load)
+
+	c.Get(1)
+	c.Get(1)
+	c.Has(1, 2)
+
+	if f.loads != 1 {
+		t.Errorf("Expected 1 load, got %v.", f.loads)
+	}
+}
+
+func TestSetAvoidsLoad(t *testing.T) {
+	f := newFakeStore()
+	f.confirm(1, 2)
+	c := NewCache(f.load)
+
+	c.Set(1, []int{2})
+
+	isContact, _ := c.Has(1, 2)
+	if !isContact {
+		t.Errorf("Expected user 2 to be a contact of user 1.")
+	}
+
+	if f.loads != 0 {
+		t.Errorf("Expected 0 loads, got %v.", f.loads)
+	}
+}
+
+func TestConsistentWithStoreAfterConfirmAndRemove(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store dbaccess.Store) {
+		ids := createUsers(t, store, 3)
+		load := contactsLoader(store)
+
+		confirm(t, store, ids[0], ids[1])
+		c := NewCache(load)
+
+		// The first user cached, the third not cached yet.
+		c.Get(ids[0])
+
+		confirm(t, store, ids[2], ids[0])
+		c.Add(ids[0], ids[2])
+		assertConsistent(t, c, load, ids...)
+
+		if err := store.RemoveContact(ids[0], ids[1]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		c.Remove(ids[0], ids[1])
+		assertConsistent(t, c, load, ids...)
+
+		isContact, _ := c.Has(ids[1], ids[0])
+		if isContact {
+			t.Errorf("Expected the first user to no longer be a contact of the second.")
+		}
+	})
+}
+
+func TestInvalidateReloadsFromStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store dbaccess.Store) {
+		ids := createUsers(t, store, 3)
+		load := contactsLoader(store)
+
+		confirm(t, store, ids[0], ids[1])
+
+		loads := 0
+		c := NewCache(func(userID int) ([]int, error) {
+			loads++
+			return load(userID)
+		})
+
+		c.Get(ids[0])
+
+		// Changed behind the caches back.
+		confirm(t, store, ids[2], ids[0])
+		c.Invalidate(ids[0])
+
+		assertConsistent(t, c, load, ids[0])
+
+		if loads != 2 {
+			t.Errorf("Expected 2 loads, got %v.", loads)
+		}
+	})
+}
+
+func TestLoadErrorNotCached(t *testing.T) {
+	f := newFakeStore()
+	f.confirm(1, 2)
+	f.fail = true
+	c := NewCache(f.load)
+
+	if _, err := c.Get(1); err == nil {
+		t.Errorf("Expected an error.")
+	}
+
+	f.fail = false
+	assertConsistent(t, c, f.load, 1)
+}
+
+func TestStaleLoadNotCached(t *testing.T) {
+	f := newFakeStore()
+	f.confirm(1, 2)
+
+	var c *Cache
+
+	// Simulate a contact being confirmed while the users contacts are being loaded.
+	c = NewCache(func(userID int) ([]int, error) {
+		ids, err := f.load(userID)
+		f.confirm(1, 3)
+		c.Add(1, 3)
+		return ids, err
+	})
+
+	c.Get(1)
+
+	isContact, _ := c.Has(1, 3)
+	if !isContact {
+		t.Errorf("Expected user 3 to be a contact of user 1.")
+	}
+}
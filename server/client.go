@@ -2,32 +2,165 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
 	"time"
 )
 
 // Client represents a connected user.
 type Client struct {
 	conn        net.Conn
+	settings    *Settings
+	counters    *queueCounters
+	queue       chan queuedPacket
+	sending     *sync.RWMutex
+	stopped     bool
+	closing     chan struct{}
+	closeOnce   *sync.Once
+	heartbeat   *heartbeat
+	profile     *sync.RWMutex
+	displayName *string
+	statusText  *string
+	imageURL    *string
 	Username    string
-	DisplayName *string
 	LoggedIn    bool
 	UserID      int
-	Status      int
-	StatusText  *string
-	ImageURL    *string
 }
 
-// SendPacket sends the specified packet to the connected client.
+func newClient(conn net.Conn, settings *Settings, counters *queueCounters) *Client {
+	return &Client{
+		conn:      conn,
+		settings:  settings,
+		counters:  counters,
+		queue:     make(chan queuedPacket, settings.SendQueueLength),
+		sending:   &sync.RWMutex{},
+		closing:   make(chan struct{}),
+		closeOnce: &sync.Once{},
+		heartbeat: newHeartbeat(),
+		profile:   &sync.RWMutex{},
+	}
+}
+
+// DisplayName returns the display name of the clients user. The profile is shared by every session of the user and
+// can be changed from any of them, so it is only accessed through these methods.
+func (c *Client) DisplayName() *string {
+	c.profile.RLock()
+	defer c.profile.RUnlock()
+
+	return c.displayName
+}
+
+// SetDisplayName sets the display name of the clients user.
+func (c *Client) SetDisplayName(displayName *string) {
+	c.profile.Lock()
+	defer c.profile.Unlock()
+
+	c.displayName = displayName
+}
+
+// StatusText returns the status text of the clients user.
+func (c *Client) StatusText() *string {
+	c.profile.RLock()
+	defer c.profile.RUnlock()
+
+	return c.statusText
+}
+
+// SetStatusText sets the status text of the clients user.
+func (c *Client) SetStatusText(statusText *string) {
+	c.profile.Lock()
+	defer c.profile.Unlock()
+
+	c.statusText = statusText
+}
+
+// ImageURL returns the avatar image URL of the clients user.
+func (c *Client) ImageURL() *string {
+	c.profile.RLock()
+	defer c.profile.RUnlock()
+
+	return c.imageURL
+}
+
+// SetImageURL sets the avatar image URL of the clients user.
+func (c *Client) SetImageURL(imageURL *string) {
+	c.profile.Lock()
+	defer c.profile.Unlock()
+
+	c.imageURL = imageURL
+}
+
+// SendPacket queues the specified packet to be sent to the connected client. Packets are sent in the order they are
+// queued. It doesn't block, if the queue is full the servers slow consumer policy is applied and ErrQueueFull is
+// returned.
 func (c *Client) SendPacket(p *Packet) error {
+	return c.enqueue(queuedPacket{packet: p}, false)
+}
+
+// SendPacketNotify queues the specified packet like SendPacket. If it is queued, sent is called once from the clients
+// writer, with true after the packet has been written to the connection or false if it was dropped instead.
+func (c *Client) SendPacketNotify(p *Packet, sent func(written bool)) error {
+	return c.enqueue(queuedPacket{packet: p, sent: sent}, false)
+}
+
+// SendPacketWait queues the specified packet like SendPacket, but waits for room in the queue instead of applying
+// the slow consumer policy. Use it for sending many packets to a client from its own packet handler.
+func (c *Client) SendPacketWait(p *Packet) error {
+	return c.enqueue(queuedPacket{packet: p}, true)
+}
+
+// SendPacketWaitNotify queues the specified packet like SendPacketWait, and calls sent like SendPacketNotify.
+func (c *Client) SendPacketWaitNotify(p *Packet, sent func(written bool)) error {
+	return c.enqueue(queuedPacket{packet: p, sent: sent}, true)
+}
+
+// QueueDepth returns the number of packets waiting to be sent to the client.
+func (c *Client) QueueDepth() int {
+	return len(c.queue)
+}
+
+// Close disconnects the client once the packets already queued for it have been sent. The server removes it once
+// its pending read fails.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+
+	return nil
+}
+
+// CloseNow disconnects the client straight away, dropping any queued packets.
+func (c *Client) CloseNow() error {
+	c.abort()
+	return nil
+}
+
+// Disconnects the client straight away, dropping any queued packets. Returns true if the client wasn't already
+// closing.
+func (c *Client) abort() bool {
+	aborted := false
+
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		aborted = true
+	})
+
+	c.conn.Close()
+
+	return aborted
+}
+
+// Writes a packet to the connection.
+func (c *Client) write(p *Packet, deadline time.Time) error {
 	bytes := p.toBytes()
 
-	c.conn.SetWriteDeadline(time.Now().Add(writeTimeoutDuration))
+	c.conn.SetWriteDeadline(deadline)
 	numBytes, err := c.conn.Write(*bytes)
 
-	if numBytes != len(*bytes) {
+	if err == nil && numBytes != len(*bytes) {
 		log.Printf("Failed to write full packet length.")
 	}
 
@@ -42,7 +175,7 @@ func (c *Client) readPacket() (packet *Packet, err error) {
 	}
 
 	if packetType < 0 {
-		return nil, errors.New("invalid packet id " + string(packetType))
+		return nil, fmt.Errorf("invalid packet id %v", packetType)
 	}
 
 	packetLength, e := readFourBytes(c)
@@ -50,7 +183,7 @@ func (c *Client) readPacket() (packet *Packet, err error) {
 		return nil, e
 	}
 
-	if packetLength > maxPacketLength {
+	if packetLength > c.settings.MaxPacketLength {
 		return nil, errors.New("packet length above maximum allowed")
 	}
 
@@ -74,7 +207,7 @@ func (c *Client) readPacket() (packet *Packet, err error) {
 func readFourBytes(c *Client) (val int64, err error) {
 	bytes := make([]byte, 4)
 
-	c.conn.SetReadDeadline(time.Now().Add(readTimeoutDuration))
+	c.conn.SetReadDeadline(time.Now().Add(c.settings.ReadTimeout))
 
 	reader := io.LimitReader(c.conn, 4)
 
@@ -99,7 +232,7 @@ func readPacketData(c *Client, length int64) (data *[]byte, err error) {
 
 	bytes := make([]byte, length)
 
-	c.conn.SetReadDeadline(time.Now().Add(readTimeoutDuration))
+	c.conn.SetReadDeadline(time.Now().Add(c.settings.ReadTimeout))
 
 	reader := io.LimitReader(c.conn, length)
 
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Keeps track of the pings sent to a client and the round trip time of its replies. Any packet read from the client
+// shows it is alive, and clients that have never answered a ping, such as ones that don't know the pong packet, are
+// never timed out.
+type heartbeat struct {
+	mutex    *sync.Mutex
+	sequence int
+	sentAt   time.Time
+	waiting  bool
+	answers  bool
+	lastRead time.Time
+	rtt      time.Duration
+}
+
+func newHeartbeat() *heartbeat {
+	return &heartbeat{mutex: &sync.Mutex{}}
+}
+
+// NextPing records that a ping is being sent to the client and returns its sequence number. Returns false if the
+// previous ping hasn't been answered within the timeout and nothing else has been read from the client since it was
+// sent, in which case the connection should be considered dead.
+func (c *Client) NextPing(now time.Time, timeout time.Duration) (int, bool) {
+	h := c.heartbeat
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.waiting && now.Sub(h.sentAt) >= timeout {
+		if h.answers && !h.lastRead.After(h.sentAt) {
+			return 0, false
+		}
+
+		// The client is still there, it just didn't answer. Send it another ping.
+		h.waiting = false
+	}
+
+	// Wait for the reply to an outstanding ping rather than sending another.
+	if h.waiting {
+		return 0, true
+	}
+
+	h.sequence++
+	h.sentAt = now
+	h.waiting = true
+
+	return h.sequence, true
+}
+
+// PongReceived records the clients reply to a ping. Returns false if the reply isn't for the last ping sent.
+func (c *Client) PongReceived(sequence int, now time.Time) bool {
+	h := c.heartbeat
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.waiting || sequence != h.sequence {
+		return false
+	}
+
+	h.waiting = false
+	h.answers = true
+	h.rtt = now.Sub(h.sentAt)
+
+	return true
+}
+
+// Records that a packet was read from the client.
+func (c *Client) packetRead(now time.Time) {
+	h := c.heartbeat
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastRead = now
+}
+
+// RTT returns the round trip time of the last ping the client replied to, or 0 if it hasn't replied to any.
+func (c *Client) RTT() time.Duration {
+	h := c.heartbeat
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.rtt
+}
+
+// Clients returns all of the connected clients. This call is thread safe.
+func (s *TCPServer) Clients() []*Client {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]*Client{}, s.clients...)
+}
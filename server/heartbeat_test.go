@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	client, _, _ := newTestClient(SlowConsumerDisconnect)
+	now := time.Now()
+
+	sequence, alive := client.NextPing(now, 10*time.Second)
+	if sequence != 1 || !alive {
+		t.Fatalf("Expected (1, true), got (%v, %v).", sequence, alive)
+	}
+
+	// No new ping is sent while waiting for a reply.
+	sequence, alive = client.NextPing(now.Add(5*time.Second), 10*time.Second)
+	if sequence != 0 || !alive {
+		t.Errorf("Expected (0, true), got (%v, %v).", sequence, alive)
+	}
+
+	if client.PongReceived(2, now.Add(time.Second)) {
+		t.Errorf("Expected a pong for an unknown ping to be ignored.")
+	}
+
+	if !client.PongReceived(1, now.Add(200*time.Millisecond)) {
+		t.Errorf("Expected the pong to be accepted.")
+	}
+
+	if client.RTT() != 200*time.Millisecond {
+		t.Errorf("Expected %v, got %v.", 200*time.Millisecond, client.RTT())
+	}
+
+	sequence, _ = client.NextPing(now.Add(20*time.Second), 10*time.Second)
+	if sequence != 2 {
+		t.Fatalf("Expected 2, got %v.", sequence)
+	}
+
+	_, alive = client.NextPing(now.Add(30*time.Second), 10*time.Second)
+	if alive {
+		t.Errorf("Expected the client to be dead after missing the pong timeout.")
+	}
+}
+
+func TestHeartbeatCountsAnyPacket(t *testing.T) {
+	client, _, _ := newTestClient(SlowConsumerDisconnect)
+	now := time.Now()
+
+	// A client that has never answered a ping isn't timed out, it may not know the pong packet.
+	client.NextPing(now, 10*time.Second)
+
+	sequence, alive := client.NextPing(now.Add(20*time.Second), 10*time.Second)
+	if sequence != 2 || !alive {
+		t.Fatalf("Expected (2, true), got (%v, %v).", sequence, alive)
+	}
+
+	client.PongReceived(2, now.Add(21*time.Second))
+	client.NextPing(now.Add(30*time.Second), 10*time.Second)
+
+	// Once it has answered, any packet read after the ping keeps it alive.
+	client.packetRead(now.Add(35 * time.Second))
+
+	sequence, alive = client.NextPing(now.Add(40*time.Second), 10*time.Second)
+	if sequence != 4 || !alive {
+		t.Fatalf("Expected (4, true), got (%v, %v).", sequence, alive)
+	}
+
+	if _, alive = client.NextPing(now.Add(50*time.Second), 10*time.Second); alive {
+		t.Errorf("Expected the client to be dead after reading nothing within the timeout.")
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// SlowConsumerPolicy decides what happens to a client whose send queue is full.
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerDisconnect disconnects a client that can't keep up with the packets sent to it.
+	SlowConsumerDisconnect SlowConsumerPolicy = iota
+
+	// SlowConsumerDrop drops packets sent to a client while its queue is full.
+	SlowConsumerDrop
+)
+
+// Errors returned when a packet can't be queued for a client.
+var (
+	ErrQueueFull    = errors.New("client send queue is full")
+	ErrClientClosed = errors.New("client is closed")
+)
+
+// QueueStats is a snapshot of the send queues of all connected clients.
+type QueueStats struct {
+	Clients                 int
+	QueuedPackets           int
+	MaxQueueDepth           int
+	DroppedPackets          int64
+	SlowConsumerDisconnects int64
+}
+
+// Counters shared by all clients of a server, updated atomically.
+type queueCounters struct {
+	dropped     int64
+	disconnects int64
+}
+
+// QueueStats returns the current depth of the client send queues, and the number of packets dropped and clients
+// disconnected for being too slow since the server started.
+func (s *TCPServer) QueueStats() QueueStats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := QueueStats{
+		Clients:                 len(s.clients),
+		DroppedPackets:          atomic.LoadInt64(&s.counters.dropped),
+		SlowConsumerDisconnects: atomic.LoadInt64(&s.counters.disconnects),
+	}
+
+	for _, c := range s.clients {
+		depth := c.QueueDepth()
+
+		stats.QueuedPackets += depth
+		if depth > stats.MaxQueueDepth {
+			stats.MaxQueueDepth = depth
+		}
+	}
+
+	return stats
+}
+
+// A packet waiting in a clients send queue, and the function to call once it has been written or dropped.
+type queuedPacket struct {
+	packet *Packet
+	sent   func(written bool)
+}
+
+func (q queuedPacket) done(written bool) {
+	if q.sent != nil {
+		q.sent(written)
+	}
+}
+
+// Adds a packet to the send queue, waiting for room or applying the slow consumer policy if it is full. Packets are
+// only queued while the writer is running, so every queued packet is either written or dropped by it.
+func (c *Client) enqueue(q queuedPacket, wait bool) error {
+	c.sending.RLock()
+	defer c.sending.RUnlock()
+
+	if c.stopped {
+		return ErrClientClosed
+	}
+
+	select {
+	case <-c.closing:
+		return ErrClientClosed
+	default:
+	}
+
+	if wait {
+		select {
+		case c.queue <- q:
+			return nil
+		case <-c.closing:
+			return ErrClientClosed
+		}
+	}
+
+	select {
+	case c.queue <- q:
+		return nil
+	default:
+		return c.queueFull()
+	}
+}
+
+// Writes queued packets to the connection in order until the client is closed. Packets queued before a graceful
+// close are still written, as long as they can all be written within the write timeout. The rest are dropped.
+func (c *Client) writePackets() {
+	defer c.stopSending()
+
+	for {
+		select {
+		case q := <-c.queue:
+			err := c.write(q.packet, time.Now().Add(c.settings.WriteTimeout))
+			if err != nil {
+				c.abort()
+				q.done(false)
+				return
+			}
+
+			q.done(true)
+
+		case <-c.closing:
+			deadline := time.Now().Add(c.settings.WriteTimeout)
+
+			for {
+				select {
+				case q := <-c.queue:
+					err := c.write(q.packet, deadline)
+					if err != nil {
+						c.conn.Close()
+						q.done(false)
+						return
+					}
+
+					q.done(true)
+
+				default:
+					c.conn.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stops packets being queued once the writer has finished, and drops the ones it didn't write. The client is always
+// closing by then, so senders waiting for room in the queue have given up.
+func (c *Client) stopSending() {
+	c.sending.Lock()
+	c.stopped = true
+	c.sending.Unlock()
+
+	for {
+		select {
+		case q := <-c.queue:
+			q.done(false)
+		default:
+			return
+		}
+	}
+}
+
+// Applies the slow consumer policy to a client whose queue is full.
+func (c *Client) queueFull() error {
+	if c.settings.SlowConsumerPolicy == SlowConsumerDrop {
+		atomic.AddInt64(&c.counters.dropped, 1)
+		return ErrQueueFull
+	}
+
+	if c.abort() {
+		atomic.AddInt64(&c.counters.disconnects, 1)
+	}
+
+	return ErrQueueFull
+}
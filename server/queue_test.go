@@ -0,0 +1,116 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestClient(policy SlowConsumerPolicy) (*Client, net.Conn, *queueCounters) {
+	serverConn, clientConn := net.Pipe()
+
+	settings := DefaultSettings()
+	settings.SendQueueLength = 2
+	settings.SlowConsumerPolicy = policy
+
+	counters := &queueCounters{}
+
+	return newClient(serverConn, &settings, counters), clientConn, counters
+}
+
+func TestSlowConsumerDrop(t *testing.T) {
+	client, _, counters := newTestClient(SlowConsumerDrop)
+
+	for i := 0; i < 2; i++ {
+		if err := client.SendPacket(&Packet{ID: int64(i)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if err := client.SendPacket(&Packet{ID: 2}); err != ErrQueueFull {
+		t.Errorf("Expected %v, got %v.", ErrQueueFull, err)
+	}
+
+	if client.QueueDepth() != 2 || counters.dropped != 1 {
+		t.Errorf("Expected depth 2 with 1 dropped, got depth %v with %v dropped.", client.QueueDepth(), counters.dropped)
+	}
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	client, _, counters := newTestClient(SlowConsumerDisconnect)
+
+	client.SendPacket(&Packet{ID: 0})
+	client.SendPacket(&Packet{ID: 1})
+
+	if err := client.SendPacket(&Packet{ID: 2}); err != ErrQueueFull {
+		t.Errorf("Expected %v, got %v.", ErrQueueFull, err)
+	}
+
+	if err := client.SendPacket(&Packet{ID: 3}); err != ErrClientClosed {
+		t.Errorf("Expected %v, got %v.", ErrClientClosed, err)
+	}
+
+	if counters.disconnects != 1 {
+		t.Errorf("Expected 1 disconnect, got %v.", counters.disconnects)
+	}
+}
+
+func TestQueuedPacketsWrittenInOrderBeforeClose(t *testing.T) {
+	client, conn, _ := newTestClient(SlowConsumerDisconnect)
+
+	client.SendPacket(&Packet{ID: 1})
+	client.SendPacket(&Packet{ID: 2})
+	client.Close()
+
+	go client.writePackets()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	for _, expectID := range []int64{1, 2} {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if id := bytesToInt64(header[:4]); id != expectID {
+			t.Errorf("Expected packet %v, got %v.", expectID, id)
+		}
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v.", err)
+	}
+}
+
+func TestSendPacketNotify(t *testing.T) {
+	client, conn, _ := newTestClient(SlowConsumerDisconnect)
+
+	sent := make(chan bool, 2)
+	notify := func(written bool) { sent <- written }
+
+	client.SendPacketNotify(&Packet{ID: 1}, notify)
+	client.SendPacketNotify(&Packet{ID: 2}, notify)
+
+	go client.writePackets()
+
+	// The first packet is read, then the connection goes before the second can be written.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(conn, make([]byte, 8)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if written := <-sent; !written {
+		t.Errorf("Expected the first packet to be written.")
+	}
+
+	conn.Close()
+
+	if written := <-sent; written {
+		t.Errorf("Expected the second packet to be dropped.")
+	}
+
+	if err := client.SendPacketNotify(&Packet{ID: 3}, notify); err != ErrClientClosed {
+		t.Errorf("Expected %v, got %v.", ErrClientClosed, err)
+	}
+}
@@ -3,10 +3,13 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
 	"net"
 	"runtime/debug"
 	"sync"
+	"time"
 )
 
 // OnHandlePacket is used as an event for when a packet is received from a client.
@@ -21,23 +24,43 @@ type OnClientDisconnect func(s *TCPServer, c *Client, addr string)
 // TCPServer represents the listening socket and all connected clients.
 type TCPServer struct {
 	listener           net.Listener
+	settings           Settings
 	clients            []*Client
 	mutex              *sync.RWMutex
 	onHandlePacket     OnHandlePacket
 	onClientConnect    OnClientConnect
 	onClientDisconnect OnClientDisconnect
+	tlsConfig          *tls.Config
+	requireTLS         bool
+	shuttingDown       bool
+	serving            *sync.WaitGroup
+	counters           *queueCounters
 }
 
 // NewTCPServer creates a new TCPServer instance.
 func NewTCPServer(h OnHandlePacket, c OnClientConnect, d OnClientDisconnect) *TCPServer {
 	return &TCPServer{
+		settings:           DefaultSettings(),
 		mutex:              &sync.RWMutex{},
+		serving:            &sync.WaitGroup{},
+		counters:           &queueCounters{},
 		onHandlePacket:     h,
 		onClientConnect:    c,
 		onClientDisconnect: d,
 	}
 }
 
+// Configure changes the timeouts and limits used for clients. It must be called before the server starts accepting
+// connections.
+func (s *TCPServer) Configure(settings Settings) {
+	s.settings = settings
+}
+
+// Settings returns the timeouts and limits used for clients.
+func (s *TCPServer) Settings() Settings {
+	return s.settings
+}
+
 // Listen opens a port for connections on the specified address.
 func (s *TCPServer) Listen(address string) error {
 	l, err := net.Listen("tcp", address)
@@ -51,40 +74,115 @@ func (s *TCPServer) Listen(address string) error {
 	return err
 }
 
+// Addr returns the address the server is listening on, which has the chosen port when listening on port 0.
+func (s *TCPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
 // Close the listening server socket.
 func (s *TCPServer) Close() {
 	s.listener.Close()
 }
 
-// Run start an indefinite loop waiting for new clients to connect and serves them. Messages from clients
-// are sent through the TCPServer packet channel.
-func (s *TCPServer) Run() {
+// Run start a loop waiting for new clients to connect and serves them, until the context is done. Messages from
+// clients are sent through the TCPServer packet channel.
+func (s *TCPServer) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		s.listener.Close()
+	}()
+
 	for {
 		conn, err := s.listener.Accept()
 
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
 			log.Print(err)
 		} else {
-			client := s.accept(conn)
-			go s.serve(client)
+			go s.handleConnection(conn)
 		}
 	}
 }
 
+// Shutdown stops accepting clients, sends the specified packet to every connected client and disconnects them. It
+// waits until every client has been served and removed and its queued packets written or dropped, or the context is
+// done.
+func (s *TCPServer) Shutdown(ctx context.Context, p *Packet) error {
+	s.mutex.Lock()
+	s.shuttingDown = true
+	clients := append([]*Client{}, s.clients...)
+	s.mutex.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	for _, c := range clients {
+		if p != nil {
+			c.SendPacket(p)
+		}
+
+		c.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.serving.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // BroadcastPacket will send the specified packet to all connected clients except the specified client connection.
 func (s *TCPServer) BroadcastPacket(p *Packet, c *Client) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	for _, client := range s.clients {
 		if client != c {
-			go client.SendPacket(p)
+			client.SendPacket(p)
 		}
 	}
 }
 
 // NumClients returns the number of connected clients.
 func (s *TCPServer) NumClients() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	return len(s.clients)
 }
 
+func (s *TCPServer) handleConnection(conn net.Conn) {
+	if s.tlsConfig != nil {
+		secured, err := s.secure(conn)
+		if err != nil {
+			log.Printf("Connection from %v refused: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+
+		conn = secured
+	}
+
+	client := s.accept(conn)
+	if client == nil {
+		conn.Close()
+		return
+	}
+
+	s.serve(client)
+}
+
 func (s *TCPServer) accept(c net.Conn) *Client {
 	defer func() {
 		if err := recover(); err != nil {
@@ -92,14 +190,23 @@ func (s *TCPServer) accept(c net.Conn) *Client {
 		}
 	}()
 
-	client := &Client{
-		conn: c,
-	}
+	client := newClient(c, &s.settings, s.counters)
 
 	s.mutex.Lock()
+	if s.shuttingDown {
+		s.mutex.Unlock()
+		return nil
+	}
+
 	s.clients = append(s.clients, client)
+	s.serving.Add(2)
 	s.mutex.Unlock()
 
+	go func() {
+		defer s.serving.Done()
+		client.writePackets()
+	}()
+
 	s.onClientConnect(s, client, c.RemoteAddr().String())
 
 	return client
@@ -123,10 +230,12 @@ func (s *TCPServer) remove(client *Client) {
 
 	s.onClientDisconnect(s, client, client.conn.RemoteAddr().String())
 
-	client.conn.Close()
+	client.abort()
 }
 
 func (s *TCPServer) serve(client *Client) {
+	defer s.serving.Done()
+
 	defer func() {
 		if err := recover(); err != nil {
 			log.Println("Failed to handle packet - client disconnected:", err)
@@ -143,6 +252,8 @@ func (s *TCPServer) serve(client *Client) {
 			break
 		}
 
+		client.packetRead(time.Now())
+
 		s.onHandlePacket(s, client, packet)
 	}
 }
@@ -163,16 +274,89 @@ func (s *TCPServer) GetClientsByID(userID int) []*Client {
 	return clients
 }
 
-// BroadcastPacketToUserID sends the specified packet to all connected clients with the specified user id.
-func (s *TCPServer) BroadcastPacketToUserID(userID int, p *Packet) {
+// SetClientUserID sets the user id a client is logged in as. It is set under the server lock, as other goroutines
+// look clients up by their user id.
+func (s *TCPServer) SetClientUserID(c *Client, userID int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c.UserID = userID
+}
+
+// BroadcastPacketToUserID queues the specified packet for all connected clients with the specified user id. Returns
+// the number of clients the packet was queued for.
+func (s *TCPServer) BroadcastPacketToUserID(userID int, p *Packet) int {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	cnt := 0
+
 	for _, c := range s.clients {
-		if c.UserID == userID {
-			go c.SendPacket(p)
+		if c.UserID == userID && c.SendPacket(p) == nil {
+			cnt++
 		}
 	}
+
+	return cnt
+}
+
+// DeliverPacketToUserID sends the specified packet to every client with the user id, like BroadcastPacketToUserID.
+// Once each client has written or dropped it, delivered is called with true if any of them wrote it. Returns false
+// without calling delivered if no client queued the packet.
+func (s *TCPServer) DeliverPacketToUserID(userID int, p *Packet, delivered func(written bool)) bool {
+	// The delivery is held open until every client has been tried, so it can't finish early.
+	d := &delivery{pending: 1, delivered: delivered}
+	queued := false
+
+	s.mutex.RLock()
+	for _, c := range s.clients {
+		if c.UserID != userID {
+			continue
+		}
+
+		d.add()
+		if c.SendPacketNotify(p, d.sent) == nil {
+			queued = true
+		} else {
+			d.sent(false)
+		}
+	}
+	s.mutex.RUnlock()
+
+	if !queued {
+		return false
+	}
+
+	d.sent(false)
+
+	return true
+}
+
+// Collects whether a packet sent to several clients was written to any of them.
+type delivery struct {
+	mutex     sync.Mutex
+	pending   int
+	written   bool
+	delivered func(written bool)
+}
+
+func (d *delivery) add() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.pending++
+}
+
+func (d *delivery) sent(written bool) {
+	d.mutex.Lock()
+	d.written = d.written || written
+	d.pending--
+	done, written := d.pending == 0, d.written
+	d.mutex.Unlock()
+
+	if done {
+		d.delivered(written)
+	}
 }
 
 // IsClientMultiLogged returns true if the specified user ID has multiple logins.
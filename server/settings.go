@@ -2,13 +2,30 @@ package server
 
 import "time"
 
-const (
-	// The time in seconds to allow a socket read operation to complete.
-	readTimeoutDuration = 60 * time.Second
+// Settings are the socket timeouts and limits used by a TCPServer.
+type Settings struct {
+	// The time to allow a socket read operation to complete.
+	ReadTimeout time.Duration
 
-	// The time in seconds to allow a socket write operation to complete.
-	writeTimeoutDuration = 30 * time.Second
+	// The time to allow a socket write operation to complete.
+	WriteTimeout time.Duration
 
 	// Maximum packet length size allowed. Anything higher is considered a DDOS and a client will be forcefully disconnected.
-	maxPacketLength = 20000000 // 20mb - accounts for very large images.
-)
+	MaxPacketLength int64
+
+	// The number of packets that can wait to be written to a client.
+	SendQueueLength int
+
+	// What to do with a client whose send queue is full.
+	SlowConsumerPolicy SlowConsumerPolicy
+}
+
+// DefaultSettings returns the settings a TCPServer uses unless it is configured otherwise.
+func DefaultSettings() Settings {
+	return Settings{
+		ReadTimeout:     60 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		MaxPacketLength: 20000000, // 20mb - accounts for very large images.
+		SendQueueLength: 256,
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+// The first byte of a TLS handshake record.
+const tlsHandshakeRecordType = 0x16
+
+// ListenTLS opens a port for TLS connections on the specified address. Clients that connect without TLS are served
+// in plaintext on the same port, unless requireTLS is set in which case they are disconnected.
+func (s *TCPServer) ListenTLS(address string, certFile string, keyFile string, requireTLS bool) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	s.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	s.requireTLS = requireTLS
+
+	return s.Listen(address)
+}
+
+// sniffConn is a connection that has had its first bytes peeked to see if the client is starting a TLS handshake.
+type sniffConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// Wraps a newly accepted connection in TLS if the client started a handshake. Plaintext connections are returned
+// as they are, or rejected with an error if TLS is required.
+func (s *TCPServer) secure(conn net.Conn) (net.Conn, error) {
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(s.settings.ReadTimeout))
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	sniffed := &sniffConn{Conn: conn, reader: reader}
+
+	if first[0] == tlsHandshakeRecordType {
+		return tls.Server(sniffed, s.tlsConfig), nil
+	}
+
+	if s.requireTLS {
+		return nil, errors.New("plaintext connection rejected, TLS is required")
+	}
+
+	return sniffed, nil
+}
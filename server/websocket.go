@@ -0,0 +1,121 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+
+	// Clients authenticate with a login packet rather than cookies, so any origin can connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocketHandler returns a HTTP handler that upgrades requests to WebSocket connections and serves them like any
+// other client. Each binary message carries packets in the same TLV format as the TCP socket.
+func (s *TCPServer) WebSocketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+
+		ws.SetReadLimit(s.settings.MaxPacketLength + 8)
+
+		client := s.accept(&wsConn{ws: ws, writeMutex: &sync.Mutex{}})
+		if client == nil {
+			ws.Close()
+			return
+		}
+
+		s.serve(client)
+	})
+}
+
+// wsConn adapts a WebSocket connection to a net.Conn so it can be read and written as a stream of packets.
+type wsConn struct {
+	ws         *websocket.Conn
+	reader     io.Reader
+	writeMutex *sync.Mutex
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			messageType, r, err := c.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			// Packets can span messages, carry on with the next one.
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+// Write sends the bytes as a single binary message. Packets are always written whole, so each message holds one.
+func (c *wsConn) Write(b []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	err := c.ws.WriteMessage(websocket.BinaryMessage, b)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	err := c.ws.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"chatServer/builders"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// How long a test client waits for an expected packet.
+const testPacketTimeout = 3 * time.Second
+
+// The kinds of field that appear in packet data.
+const (
+	fieldInt32 = iota
+	fieldInt64
+	fieldBool
+	fieldString
+	fieldList
+	fieldBytes
+	fieldOptional
+)
+
+// A field in the layout of a packet. Lists are an int32 count followed by that many items made of the list fields.
+// Optional fields are a group at the end of the data that is either all there or all left out.
+type field struct {
+	name   string
+	kind   int
+	fields []field
+}
+
+func int32Field(name string) field  { return field{name: name, kind: fieldInt32} }
+func int64Field(name string) field  { return field{name: name, kind: fieldInt64} }
+func boolField(name string) field   { return field{name: name, kind: fieldBool} }
+func stringField(name string) field { return field{name: name, kind: fieldString} }
+func bytesField(name string) field  { return field{name: name, kind: fieldBytes} }
+
+func listField(name string, fields ...field) field {
+	return field{name: name, kind: fieldList, fields: fields}
+}
+
+func optionalFields(fields ...field) field {
+	return field{kind: fieldOptional, fields: fields}
+}
+
+// The layouts of every packet the server sends, matching the builders. Only optional fields can be left out, such as
+// the details of a failed login.
+var packetLayouts = map[int64][]field{
+	builders.PacketIDLoginResult: {
+		boolField("Success"),
+		optionalFields(
+			int32Field("UserID"),
+			stringField("DisplayName"),
+			stringField("StatusText"),
+			listField("Friends",
+				int32Field("ID"),
+				stringField("Username"),
+				stringField("DisplayName"),
+				int32Field("Status"),
+				stringField("ImageURL"),
+				stringField("StatusText")),
+			listField("PendingContacts",
+				int32Field("ID"),
+				stringField("Username"),
+				stringField("DisplayName"),
+				stringField("ImageURL"),
+				stringField("Message")),
+			stringField("SessionToken")),
+	},
+	builders.PacketIDUserStatusChange: {int32Field("UserID"), int32Field("Status")},
+	builders.PacketIDChatFrom: {
+		int32Field("FromUserID"), stringField("Message"), int64Field("SentAt"), int32Field("MessageID"),
+	},
+	builders.PacketIDActionFrom:         {int32Field("FromUserID"), stringField("Action")},
+	builders.PacketIDNudgeFrom:          {int32Field("FromUserID")},
+	builders.PacketIDAudio:              {bytesField("Data")},
+	builders.PacketIDAddContactResponse: {int32Field("ResultCode")},
+	builders.PacketIDNotifyAddRequest: {
+		int32Field("UserID"), stringField("Username"), stringField("DisplayName"), stringField("Message"),
+	},
+	builders.PacketIDConfirmContactResponse: {
+		int32Field("ResultCode"),
+		int32Field("UserID"),
+		optionalFields(
+			stringField("Username"),
+			stringField("DisplayName"),
+			int32Field("Status"),
+			stringField("ImageURL"),
+			stringField("StatusText")),
+	},
+	builders.PacketIDRejectContactResponse: {int32Field("ResultCode"), int32Field("UserID")},
+	builders.PacketIDAddContactAccepted: {
+		int32Field("UserID"),
+		stringField("Username"),
+		stringField("DisplayName"),
+		int32Field("Status"),
+		stringField("ImageURL"),
+		stringField("StatusText"),
+	},
+	builders.PacketIDImageFrom: {int32Field("FromUserID"), stringField("ImageData")},
+	builders.PacketIDHistoryResult: {
+		int32Field("ContactUserID"),
+		listField("Messages",
+			int32Field("ID"),
+			int32Field("FromUserID"),
+			int32Field("MessageType"),
+			stringField("Content"),
+			int64Field("SentAt")),
+	},
+	builders.PacketIDDeliveryFailed:       {int32Field("ToUserID"), int32Field("PacketID"), int32Field("Reason")},
+	builders.PacketIDCreateRoomResponse:   {int32Field("ResultCode"), int32Field("RoomID"), stringField("Name")},
+	builders.PacketIDInviteToRoomResponse: {int32Field("ResultCode"), int32Field("RoomID"), int32Field("UserID")},
+	builders.PacketIDAddedToRoom: {
+		int32Field("RoomID"), stringField("Name"), int32Field("InvitedByUserID"),
+	},
+	builders.PacketIDLeaveRoomResponse:  {int32Field("ResultCode"), int32Field("RoomID")},
+	builders.PacketIDRenameRoomResponse: {int32Field("ResultCode"), int32Field("RoomID")},
+	builders.PacketIDRoomRenamed:        {int32Field("RoomID"), int32Field("ByUserID"), stringField("Name")},
+	builders.PacketIDRoomMembers: {
+		int32Field("ResultCode"),
+		int32Field("RoomID"),
+		listField("Members",
+			int32Field("ID"),
+			stringField("Username"),
+			stringField("DisplayName"),
+			int32Field("Status")),
+	},
+	builders.PacketIDRoomMemberChange: {int32Field("RoomID"), int32Field("UserID"), int32Field("Change")},
+	builders.PacketIDRoomMessageFrom: {
+		int32Field("RoomID"), int32Field("FromUserID"), stringField("Message"), int64Field("SentAt"),
+	},
+	builders.PacketIDRooms:                  {listField("Rooms", int32Field("ID"), stringField("Name"))},
+	builders.PacketIDCallInviteResponse:     {int32Field("ResultCode"), int32Field("CallID"), int32Field("CalleeUserID")},
+	builders.PacketIDCallIncoming:           {int32Field("CallID"), int32Field("CallerUserID")},
+	builders.PacketIDCallStateChange:        {int32Field("CallID"), int32Field("ByUserID"), int32Field("State")},
+	builders.PacketIDLogoutEverywhereResult: {int32Field("ResultCode")},
+	builders.PacketIDSessionRevoked:         {},
+	builders.PacketIDServerShutdown:         {},
+	builders.PacketIDRemoveContactResponse:  {int32Field("ResultCode"), int32Field("UserID")},
+	builders.PacketIDContactRemoved:         {int32Field("UserID")},
+	builders.PacketIDBlockUserResponse:      {int32Field("ResultCode"), int32Field("UserID")},
+	builders.PacketIDUnblockUserResponse:    {int32Field("ResultCode"), int32Field("UserID")},
+	builders.PacketIDBlockedUsers: {
+		listField("Users", int32Field("ID"), stringField("Username"), stringField("DisplayName")),
+	},
+	builders.PacketIDSetDisplayNameResponse: {int32Field("ResultCode"), stringField("DisplayName")},
+	builders.PacketIDProfileChanged: {
+		int32Field("UserID"), stringField("DisplayName"), stringField("StatusText"), stringField("ImageURL"),
+	},
+	builders.PacketIDSetStatusTextResponse: {int32Field("ResultCode"), stringField("StatusText")},
+	builders.PacketIDSetAvatarResponse:     {int32Field("ResultCode"), stringField("ImageURL")},
+	builders.PacketIDMessageDelivered:      {int32Field("ToUserID"), int32Field("MessageID"), boolField("Queued")},
+	builders.PacketIDReadReceiptFrom:       {int32Field("ReaderUserID"), int32Field("MessageID")},
+	builders.PacketIDTypingStartedFrom:     {int32Field("FromUserID")},
+	builders.PacketIDTypingStoppedFrom:     {int32Field("FromUserID")},
+	builders.PacketIDPing:                  {int32Field("Sequence")},
+	builders.PacketIDPong:                  {int32Field("Sequence")},
+	builders.PacketIDProtocolError:         {int32Field("PacketID"), int32Field("Reason")},
+}
+
+// Values decoded from packet data by field name. Strings are nil when they were sent empty.
+type values map[string]interface{}
+
+func (v values) Int(name string) int {
+	return v[name].(int)
+}
+
+func (v values) Bool(name string) bool {
+	return v[name].(bool)
+}
+
+func (v values) String(name string) string {
+	s, _ := v[name].(*string)
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func (v values) List(name string) []values {
+	items, _ := v[name].([]values)
+	return items
+}
+
+// A packet received by a test client.
+type testPacket struct {
+	ID int64
+	values
+}
+
+// Decodes packet data with the layout of its packet id. Every field must be present and all of the data used.
+func decodePacket(id int64, data []byte) (*testPacket, error) {
+	layout, ok := packetLayouts[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown packet id %v", id)
+	}
+
+	reader := bytes.NewReader(data)
+
+	v, err := decodeFields(reader, layout)
+	if err != nil {
+		return nil, fmt.Errorf("packet id %v: %v", id, err)
+	}
+
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("packet id %v: %v bytes left after decoding", id, reader.Len())
+	}
+
+	return &testPacket{ID: id, values: v}, nil
+}
+
+func decodeFields(reader *bytes.Reader, fields []field) (values, error) {
+	v := values{}
+
+	for _, f := range fields {
+		switch f.kind {
+		case fieldInt32:
+			var n int32
+			if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+				return nil, fmt.Errorf("%v: %v", f.name, err)
+			}
+			v[f.name] = int(n)
+
+		case fieldInt64:
+			var n int64
+			if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+				return nil, fmt.Errorf("%v: %v", f.name, err)
+			}
+			v[f.name] = int(n)
+
+		case fieldBool:
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", f.name, err)
+			}
+			v[f.name] = b != 0
+
+		case fieldString:
+			var length int32
+			if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+				return nil, fmt.Errorf("%v: %v", f.name, err)
+			}
+
+			if length < 0 || int(length) > reader.Len() {
+				return nil, fmt.Errorf("%v: invalid length %v", f.name, length)
+			}
+
+			var s *string
+			if length > 0 {
+				str := make([]byte, length)
+				reader.Read(str)
+				decoded := string(str)
+				s = &decoded
+			}
+			v[f.name] = s
+
+		case fieldBytes:
+			rest := make([]byte, reader.Len())
+			reader.Read(rest)
+			v[f.name] = rest
+
+		case fieldList:
+			var count int32
+			if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+				return nil, fmt.Errorf("%v: %v", f.name, err)
+			}
+
+			items := []values{}
+			for i := 0; i < int(count); i++ {
+				item, err := decodeFields(reader, f.fields)
+				if err != nil {
+					return nil, fmt.Errorf("%v[%v]: %v", f.name, i, err)
+				}
+				items = append(items, item)
+			}
+			v[f.name] = items
+
+		case fieldOptional:
+			if reader.Len() == 0 {
+				continue
+			}
+
+			group, err := decodeFields(reader, f.fields)
+			if err != nil {
+				return nil, err
+			}
+
+			for name, value := range group {
+				v[name] = value
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// testClient speaks the TLV protocol to a test server and decodes everything it receives.
+type testClient struct {
+	t       *testing.T
+	conn    io.ReadWriteCloser
+	packets chan *testPacket
+	closed  chan struct{}
+	userID  int
+}
+
+func dialTestClient(t *testing.T, address string) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	return newTestClient(t, conn)
+}
+
+// Starts a test client on a connection that is already open, such as one over TLS or WebSocket.
+func newTestClient(t *testing.T, conn io.ReadWriteCloser) *testClient {
+	c := &testClient{
+		t:       t,
+		conn:    conn,
+		packets: make(chan *testPacket, 256),
+		closed:  make(chan struct{}),
+	}
+
+	go c.read()
+	t.Cleanup(func() { c.conn.Close() })
+
+	return c
+}
+
+// wsTestConn reads and writes the packet stream of a test client as binary WebSocket messages.
+type wsTestConn struct {
+	ws     *websocket.Conn
+	reader io.Reader
+}
+
+func (c *wsTestConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (c *wsTestConn) Write(b []byte) (int, error) {
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (c *wsTestConn) Close() error {
+	return c.ws.Close()
+}
+
+// Reads packets until the connection is closed. Packets that can't be decoded fail the test.
+func (c *testClient) read() {
+	defer close(c.closed)
+
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return
+		}
+
+		id := int64(binary.LittleEndian.Uint32(header[:4]))
+		data := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(c.conn, data); err != nil {
+			return
+		}
+
+		p, err := decodePacket(id, data)
+		if err != nil {
+			c.t.Errorf("Failed to decode packet: %v", err)
+			continue
+		}
+
+		c.packets <- p
+	}
+}
+
+// Sends a packet. Ints are encoded as int32, strings with their length and bools as a byte.
+func (c *testClient) send(id int64, fields ...interface{}) {
+	c.t.Helper()
+
+	buf := new(bytes.Buffer)
+
+	for _, f := range fields {
+		switch v := f.(type) {
+		case int:
+			binary.Write(buf, binary.LittleEndian, int32(v))
+		case string:
+			binary.Write(buf, binary.LittleEndian, int32(len(v)))
+			buf.WriteString(v)
+		case bool:
+			binary.Write(buf, binary.LittleEndian, v)
+		case []byte:
+			buf.Write(v)
+		default:
+			c.t.Fatalf("Can't encode %T.", f)
+		}
+	}
+
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header[:4], uint32(id))
+	binary.LittleEndian.PutUint32(header[4:], uint32(buf.Len()))
+
+	if _, err := c.conn.Write(append(header, buf.Bytes()...)); err != nil {
+		c.t.Fatalf("Failed to send packet %v: %v", id, err)
+	}
+}
+
+// Logs in and returns the login result.
+func (c *testClient) login(username string, password string) *testPacket {
+	c.t.Helper()
+
+	c.send(builders.PacketIDLogin, []byte(username+"\n"+password))
+
+	result := c.waitFor(builders.PacketIDLoginResult)
+	if result.Bool("Success") {
+		c.userID = result.Int("UserID")
+	}
+
+	return result
+}
+
+// Waits for a packet with the specified id, skipping any others received first.
+func (c *testClient) waitFor(id int64) *testPacket {
+	c.t.Helper()
+
+	p, err := c.next(id, testPacketTimeout)
+	if err != nil {
+		c.t.Fatalf("Waiting for packet %v: %v", id, err)
+	}
+
+	return p
+}
+
+// Fails the test if a packet with the specified id is received within the duration.
+func (c *testClient) expectNone(id int64, wait time.Duration) {
+	c.t.Helper()
+
+	if p, err := c.next(id, wait); err == nil {
+		c.t.Fatalf("Unexpected packet %v: %v", id, p.values)
+	}
+}
+
+func (c *testClient) next(id int64, wait time.Duration) (*testPacket, error) {
+	timeout := time.After(wait)
+
+	for {
+		select {
+		case p := <-c.packets:
+			if p.ID == id {
+				return p, nil
+			}
+		case <-c.closed:
+			return nil, errors.New("connection closed")
+		case <-timeout:
+			return nil, errors.New("timed out")
+		}
+	}
+}
+
+// Disconnects and waits for the connection to close.
+func (c *testClient) close() {
+	c.conn.Close()
+	<-c.closed
+}
@@ -0,0 +1,106 @@
+// Package typing keeps track of which users are typing to each other, so typing indicators can be throttled and
+// stopped automatically when a client stops sending them.
+package typing
+
+import (
+	"sync"
+	"time"
+)
+
+// OnTimeout is called when a user hasn't said they are still typing within the timeout.
+type OnTimeout func(fromUserID int, toUserID int)
+
+type key struct {
+	fromUserID int
+	toUserID   int
+}
+
+type state struct {
+	forwardedAt time.Time
+	timer       *time.Timer
+}
+
+// Tracker holds the users that are currently typing. It is safe to use from multiple goroutines.
+type Tracker struct {
+	mutex     *sync.Mutex
+	typing    map[key]*state
+	throttle  time.Duration
+	timeout   time.Duration
+	onTimeout OnTimeout
+	now       func() time.Time
+}
+
+// NewTracker creates a new Tracker instance. Repeated starts are only forwarded once per throttle duration, and a
+// user is stopped if they don't start again within the timeout.
+func NewTracker(throttle time.Duration, timeout time.Duration, onTimeout OnTimeout) *Tracker {
+	return &Tracker{
+		mutex:     &sync.Mutex{},
+		typing:    map[key]*state{},
+		throttle:  throttle,
+		timeout:   timeout,
+		onTimeout: onTimeout,
+		now:       time.Now,
+	}
+}
+
+// Start records that a user is typing to another user. Returns true if it should be forwarded to the recipient.
+func (t *Tracker) Start(fromUserID int, toUserID int) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	k := key{fromUserID, toUserID}
+	now := t.now()
+
+	s, ok := t.typing[k]
+	if !ok {
+		s = &state{forwardedAt: now}
+		s.timer = time.AfterFunc(t.timeout, func() { t.expire(k, s) })
+		t.typing[k] = s
+
+		return true
+	}
+
+	s.timer.Reset(t.timeout)
+
+	if now.Sub(s.forwardedAt) < t.throttle {
+		return false
+	}
+
+	s.forwardedAt = now
+
+	return true
+}
+
+// Stop records that a user stopped typing to another user. Returns true if they were typing, so it should be
+// forwarded to the recipient.
+func (t *Tracker) Stop(fromUserID int, toUserID int) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	k := key{fromUserID, toUserID}
+
+	s, ok := t.typing[k]
+	if !ok {
+		return false
+	}
+
+	s.timer.Stop()
+	delete(t.typing, k)
+
+	return true
+}
+
+func (t *Tracker) expire(k key, s *state) {
+	t.mutex.Lock()
+
+	// The user may have stopped, or stopped and started again, since the timer fired.
+	if t.typing[k] != s {
+		t.mutex.Unlock()
+		return
+	}
+
+	delete(t.typing, k)
+	t.mutex.Unlock()
+
+	t.onTimeout(k.fromUserID, k.toUserID)
+}
@@ -0,0 +1,60 @@
+package typing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartIsThrottled(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(3*time.Second, time.Minute, func(int, int) {})
+	tracker.now = func() time.Time { return now }
+
+	if !tracker.Start(1, 2) {
+		t.Errorf("Expected the first start to be forwarded.")
+	}
+
+	now = now.Add(time.Second)
+	if tracker.Start(1, 2) {
+		t.Errorf("Expected a start within the throttle duration to be dropped.")
+	}
+
+	if !tracker.Start(2, 1) {
+		t.Errorf("Expected a start in the other direction to be forwarded.")
+	}
+
+	now = now.Add(2 * time.Second)
+	if !tracker.Start(1, 2) {
+		t.Errorf("Expected a start after the throttle duration to be forwarded.")
+	}
+
+	if !tracker.Stop(1, 2) {
+		t.Errorf("Expected stopping a typing user to be forwarded.")
+	}
+
+	if tracker.Stop(1, 2) {
+		t.Errorf("Expected stopping a user that isn't typing to be dropped.")
+	}
+}
+
+func TestStopsAfterTimeout(t *testing.T) {
+	stopped := make(chan [2]int, 1)
+	tracker := NewTracker(time.Second, 10*time.Millisecond, func(fromUserID int, toUserID int) {
+		stopped <- [2]int{fromUserID, toUserID}
+	})
+
+	tracker.Start(1, 2)
+
+	select {
+	case users := <-stopped:
+		if users != [2]int{1, 2} {
+			t.Errorf("Expected %v, got %v.", [2]int{1, 2}, users)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the user to be stopped after the timeout.")
+	}
+
+	if tracker.Stop(1, 2) {
+		t.Errorf("Expected the timed out user to no longer be typing.")
+	}
+}
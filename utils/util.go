@@ -1,9 +1,11 @@
 package utils
 
 import (
-	"bytes"
-	"chatServer/dbaccess"
+	"chatServer/roster"
 	"chatServer/server"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 
 	uuid "github.com/satori/go.uuid"
@@ -32,16 +34,9 @@ func ComparePasswordHashes(password string, hashedPassword string) (equal bool)
 	return true
 }
 
-// Converts little endian bytes to an int64.
-func bytesToInt64(b []byte) int64 {
-	var val int64
-
-	val |= int64(b[0])
-	val |= int64(b[1]) << 8
-	val |= int64(b[2]) << 16
-	val |= int64(b[3]) << 24
-
-	return val
+// IsValidDisplayName returns true if the display name can be used for an account.
+func IsValidDisplayName(displayName string) bool {
+	return len(displayName) >= 1 && len(displayName) <= 20
 }
 
 // GenerateGUID returns a new random GUID in the string format of xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
@@ -50,42 +45,42 @@ func GenerateGUID() string {
 	return u1.String()
 }
 
-// ReadInt32 reads 4 byutes from the specified reader to make an int64.
-func ReadInt32(r *bytes.Reader) int64 {
-	numBytes := make([]byte, 4)
-	r.Read(numBytes)
-	num := bytesToInt64(numBytes)
+// GenerateSessionToken returns a new random session token as a hex string.
+func GenerateSessionToken() string {
+	tokenBytes := make([]byte, 32)
 
-	return num
-}
-
-// ReadLenString reads 4 bytes (int32) to determine a string length, and then the string data itself using the known length.
-func ReadLenString(r *bytes.Reader) *string {
-	len := ReadInt32(r)
-	if len == 0 {
-		return nil
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		panic(err)
 	}
 
-	strBytes := make([]byte, len)
-	r.Read(strBytes)
-	str := string(strBytes)
+	return hex.EncodeToString(tokenBytes)
+}
 
-	return &str
+// HashSessionToken returns the hash of a session token that is stored in place of the token itself.
+func HashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
-// BroadcastPacketToContacts sends the specified packet to a user contacts.
+// BroadcastPacketToContacts sends the specified packet to a user contacts. Contacts with a block between them and the
+// user are skipped, so blocked users appear offline to each other.
 func BroadcastPacketToContacts(s *server.TCPServer, userID int, packet *server.Packet) {
 
-	// Expensive to hit the DB for everytime we want to send to a known list of contacts.
-	// Future caching opportunity here to reduce DB load.
-	friends, err := dbaccess.GetFriends(userID)
+	contactIDs, err := roster.GetContacts(userID)
 
 	if err != nil {
 		log.Printf("Failed to get contacts for user id '%v'.", userID)
 	} else {
-		if friends != nil {
-			for _, f := range friends {
-				go s.BroadcastPacketToUserID(f.ID, packet)
+		for _, id := range contactIDs {
+			blocked, err := roster.IsBlocked(userID, id)
+			if err != nil {
+				log.Printf("Failed to check block of '%v' for user id '%v'.", id, userID)
+				continue
+			}
+
+			if !blocked {
+				s.BroadcastPacketToUserID(id, packet)
 			}
 		}
 	}